@@ -27,6 +27,16 @@ func main() {
 						Usage: "Hostname for the query",
 						Value: "google.com",
 					},
+					&cli.StringFlag{
+						Name:  "protocol",
+						Usage: "Protocol used to reach TLS: TLS, SMTP, SMTPS, IMAP, IMAPS, POP3, POP3S, FTP, XMPP, LDAP, LDAPS or POSTGRES",
+						Value: "TLS",
+					},
+					&cli.StringFlag{
+						Name:  "port",
+						Usage: "Port to scan, defaults to the protocol's port",
+						Value: "",
+					},
 					&cli.StringFlag{
 						Name:    "out-dir",
 						Aliases: []string{"o"},
@@ -37,6 +47,26 @@ func main() {
 						Aliases: []string{"f"},
 						Value:   "",
 					},
+					&cli.StringFlag{
+						Name:  "cert-dir",
+						Usage: "Directory to persist unique certificates (DER/PEM) keyed by SHA-256 fingerprint",
+						Value: "",
+					},
+					&cli.StringFlag{
+						Name:  "ev-registry",
+						Usage: "CCADB CSV report or JSON file mapping EV policy OIDs to entitled roots",
+						Value: "",
+					},
+					&cli.BoolFlag{
+						Name:  "check-revocation",
+						Usage: "Query OCSP responders and CRL distribution points for every presented certificate",
+						Value: false,
+					},
+					&cli.StringFlag{
+						Name:  "fingerprint-db",
+						Usage: "JSON signature database mapping JA3S/JA4S server fingerprints to product labels",
+						Value: "",
+					},
 					&cli.BoolFlag{
 						Name:  "json",
 						Value: false,
@@ -71,6 +101,40 @@ func main() {
 						Aliases: []string{"f"},
 						Value:   "",
 					},
+					&cli.StringFlag{
+						Name:  "cert-dir",
+						Usage: "Directory to persist unique certificates (DER/PEM) keyed by SHA-256 fingerprint",
+						Value: "",
+					},
+					&cli.StringFlag{
+						Name:  "ev-registry",
+						Usage: "CCADB CSV report or JSON file mapping EV policy OIDs to entitled roots",
+						Value: "",
+					},
+					&cli.BoolFlag{
+						Name:  "check-revocation",
+						Usage: "Query OCSP responders and CRL distribution points for every presented certificate",
+						Value: false,
+					},
+					&cli.StringFlag{
+						Name:  "fingerprint-db",
+						Usage: "JSON signature database mapping JA3S/JA4S server fingerprints to product labels",
+						Value: "",
+					},
+					&cli.StringFlag{
+						Name:  "dkim-selectors",
+						Usage: "File with one DKIM selector per line to probe instead of the built-in list",
+						Value: "",
+					},
+					&cli.StringSliceFlag{
+						Name:  "trust-anchor",
+						Usage: "IANA root-anchors.xml or DS/DNSKEY record file replacing the built-in root trust anchors, repeatable",
+					},
+					&cli.StringFlag{
+						Name:  "provider-rules",
+						Usage: "JSON rules attributing mail to providers by MX, banner and certificate, defaults to dataset/mail_providers.json in the working directory or beside the executable",
+						Value: "",
+					},
 					&cli.BoolFlag{
 						Name:  "no-cache-mx",
 						Value: false,
@@ -104,6 +168,20 @@ func main() {
 						Aliases: []string{"r"},
 						Value:   "A",
 					},
+					&cli.StringFlag{
+						Name:  "record-types",
+						Usage: "Comma separated record types collected with their TTLs and DNSSEC status",
+						Value: "A,AAAA,CNAME,NS,SOA,MX,TXT,CAA,HTTPS,SVCB,DNSKEY,DS,TLSA",
+					},
+					&cli.StringSliceFlag{
+						Name:  "trust-anchor",
+						Usage: "IANA root-anchors.xml or DS/DNSKEY record file replacing the built-in root trust anchors, repeatable",
+					},
+					&cli.StringFlag{
+						Name:  "tlsa-ports",
+						Usage: "Comma separated ports whose TLSA records are collected at _<port>._tcp.<hostname>",
+						Value: "443,25",
+					},
 					&cli.StringFlag{
 						Name:    "out-dir",
 						Aliases: []string{"o"},
@@ -3,10 +3,10 @@ package main
 import (
 	"Scanner/pkg/config"
 	pserver "Scanner/pkg/policy-cache-server"
-	"Scanner/pkg/scanner/structs"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"log"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,14 +17,16 @@ import (
 )
 
 const DNS_CACHE_REFRESH_MINUTE_COUNT = 10
+const MX_CACHE_EXPIRY_MINUTE_COUNT = 10
 const MX_LOCK_IN_COUNT = 5
 
 type Server struct {
 	mutex        sync.Mutex
 	Tree         pserver.IPPrefixTree
 	DNSCache     pserver.RecordCache
-	MXScanCache  pserver.RecordCache
+	MXScanCache  *pserver.MXCache
 	MXCacheCount int
+	MXConsensus  pserver.MXConsensusPolicy
 	TLDList      pserver.TLDList
 	Capabilities config.Capabilities
 }
@@ -71,20 +73,16 @@ func (s *Server) handleIPFilterCheck(c *gin.Context) {
 
 func (s *Server) handleGetMX(c *gin.Context) {
 	mx := c.Param("mx")
-	cachedMXBytes, err := s.MXScanCache.Cache.Get(mx)
+	entry, err := s.MXScanCache.Lookup(mx)
 	if err != nil {
 		c.String(400, "cache miss: no entry")
+	} else if !entry.Decision.LockedIn {
+		// Only 'locked in' results are served
+		c.String(400, fmt.Sprintf("cache miss: staged mx but not locked in (%d/%d)",
+			entry.Decision.Agreeing,
+			entry.Decision.Required))
 	} else {
-		// Check that cache result is 'locked in'
-		var cachedMX structs.MXSpecificData
-		_ = json.Unmarshal(cachedMXBytes, &cachedMX)
-		if cachedMX.SeenCount < MX_LOCK_IN_COUNT {
-			c.String(400, fmt.Sprintf("cache miss: staged mx but not locked in (%d/%d)",
-				cachedMX.SeenCount,
-				MX_LOCK_IN_COUNT))
-		} else {
-			c.Data(200, "binary", cachedMXBytes)
-		}
+		c.Data(200, "binary", entry.Data)
 	}
 }
 
@@ -93,50 +91,53 @@ func (s *Server) handlePutMX(c *gin.Context) {
 	newMXBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		c.String(500, fmt.Sprintf("could not read bytes: %s", err.Error()))
+		return
+	}
+	entry, lockedIn, err := s.MXScanCache.Submit(mx, newMXBytes, s.MXConsensus)
+	if entry.Decision.LockedIn && !lockedIn {
+		c.String(300, fmt.Sprintf("already cached: %s", mx))
+		return
+	}
+	if err != nil && len(entry.Evidence) == 0 {
+		c.String(400, fmt.Sprintf("invalid mx submission: %s", err.Error()))
+		return
 	}
-	var cachedMX structs.MXSpecificData
-	var newMX structs.MXSpecificData
-	// Err on unmarshaling public key, fix later
-	err = json.Unmarshal(newMXBytes, &newMX)
 	if err != nil {
-		panic(err)
-	}
-	if cachedMXBytes, err := s.MXScanCache.Cache.Get(mx); err != nil {
-		// Cache MX if not previously cached already
-		newMX.SeenCount = 1
-		newMXBytes, _ = json.Marshal(newMX)
-		s.MXScanCache.Cache.Set(mx, newMXBytes)
-		if newMX.SeenCount == MX_LOCK_IN_COUNT {
-			s.mutex.Lock()
-			s.MXCacheCount++
-			s.mutex.Unlock()
-		}
-		c.String(200, fmt.Sprintf("successfully cached: %s", mx))
-	} else {
-		_ = json.Unmarshal(cachedMXBytes, &cachedMX)
-		if cachedMX.SeenCount == MX_LOCK_IN_COUNT {
-			c.String(300, fmt.Sprintf("already cached: %s", mx))
-		} else {
-			// If cached MX is not 'locked in,' then compare incoming MX and cached MX
-			compareRes := cachedMX.CompareTo(newMX)
-			if compareRes < 0 {
-				// Replace current MX with new MX
-				s.MXScanCache.Cache.Set(mx, newMXBytes)
-				c.String(200, fmt.Sprintf("replaced cached: %s", mx))
-			} else {
-				cachedMX.SeenCount += 1
-				cachedMXBytes, _ = json.Marshal(cachedMX)
-				s.MXScanCache.Cache.Set(mx, cachedMXBytes)
-				// Increment total number of locked in cached records
-				if cachedMX.SeenCount == MX_LOCK_IN_COUNT {
-					s.mutex.Lock()
-					s.MXCacheCount++
-					s.mutex.Unlock()
-				}
-				c.String(200, fmt.Sprintf("incremented staged MX: %s (%d/%d)", mx, cachedMX.SeenCount, MX_LOCK_IN_COUNT))
-			}
-		}
+		// A failed snapshot write only costs the entry after a restart
+		log.Printf("[MXCache] %v\n", err)
+	}
+	if lockedIn {
+		// Increment total number of locked in cached records
+		s.mutex.Lock()
+		s.MXCacheCount++
+		s.mutex.Unlock()
+		c.String(200, fmt.Sprintf("locked in: %s", mx))
+		return
 	}
+	c.String(200, fmt.Sprintf("staged MX: %s (%d/%d)", mx, entry.Decision.Agreeing, entry.Decision.Required))
+}
+
+// mxExplanation is the evidence of an entry without the submitted scan data
+type mxExplanation struct {
+	MX        string                      `json:"mx"`
+	ExpiresAt time.Time                   `json:"expiresAt"`
+	Decision  pserver.MXConsensusDecision `json:"decision"`
+	Evidence  []pserver.MXSubmission      `json:"evidence"`
+}
+
+func (s *Server) handleExplainMX(c *gin.Context) {
+	mx := c.Param("mx")
+	entry, err := s.MXScanCache.Lookup(mx)
+	if err != nil {
+		c.String(404, "cache miss: no entry")
+		return
+	}
+	explanation := mxExplanation{MX: entry.MX, ExpiresAt: entry.ExpiresAt, Decision: entry.Decision, Evidence: make([]pserver.MXSubmission, 0, len(entry.Evidence))}
+	for _, submission := range entry.Evidence {
+		submission.Data = nil
+		explanation.Evidence = append(explanation.Evidence, submission)
+	}
+	c.JSON(200, explanation)
 }
 
 func (s *Server) handleCapabilitiesRequest(c *gin.Context) {
@@ -147,11 +148,17 @@ func (s *Server) handleGetMXCount(c *gin.Context) {
 	c.String(200, fmt.Sprintf("%d", s.MXCacheCount))
 }
 
-func (s *Server) cacheRemoveCallback(key string, entry []byte) {
-	var cachedMX structs.MXSpecificData
-	_ = json.Unmarshal(entry, &cachedMX)
-	if cachedMX.SeenCount == MX_LOCK_IN_COUNT {
+func (s *Server) cacheRemoveCallback(entry pserver.MXCacheEntry) {
+	if entry.Decision.LockedIn {
+		s.mutex.Lock()
 		s.MXCacheCount--
+		s.mutex.Unlock()
+	}
+}
+
+func (s *Server) PeriodicMXCacheExpiry(expiryDelay time.Duration) {
+	for range time.Tick(expiryDelay) {
+		s.MXScanCache.Expire()
 	}
 }
 
@@ -162,9 +169,26 @@ func (s *Server) PeriodicCacheRefresh(refreshDelay time.Duration) {
 }
 
 func main() {
-	serverState := Server{}
+	consensusName := flag.String("mx-consensus", pserver.DEFAULT_MX_CONSENSUS,
+		fmt.Sprintf("policy locking in MX scan results: %s or %s", pserver.MX_CONSENSUS_QUORUM, pserver.MX_CONSENSUS_UNANIMOUS))
+	flag.Parse()
+	consensus, err := pserver.NewMXConsensusPolicy(*consensusName, MX_LOCK_IN_COUNT)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	serverState := Server{MXConsensus: consensus}
 	dnsCache := pserver.NewCache(15*time.Minute, nil)
-	mxCache := pserver.NewCache(10*time.Hour, serverState.cacheRemoveCallback)
+	mxCache, err := pserver.NewMXCache("", serverState.cacheRemoveCallback)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load the MX cache: %v", err))
+	}
+	// Count the locked in MXs that survived the restart
+	mxCache.Range(func(entry pserver.MXCacheEntry) {
+		if entry.Decision.LockedIn {
+			serverState.MXCacheCount++
+		}
+	})
 	tree := pserver.NewTree("")
 	tldList := pserver.NewTLDList("")
 	capabilities := config.IdentifyCapabilities()
@@ -186,8 +210,10 @@ func main() {
 	server.GET("/get-mx/:mx", serverState.handleGetMX)
 	server.POST("/put-mx/:mx", serverState.handlePutMX)
 	server.GET("/get-mx-count", serverState.handleGetMXCount)
+	server.GET("/explain-mx/:mx", serverState.handleExplainMX)
 
 	go serverState.PeriodicCacheRefresh(DNS_CACHE_REFRESH_MINUTE_COUNT * time.Minute)
+	go serverState.PeriodicMXCacheExpiry(MX_CACHE_EXPIRY_MINUTE_COUNT * time.Minute)
 
 	if err := server.Run(); err != nil {
 		panic("failed to run a server at localhost")
@@ -6,6 +6,7 @@ require (
 	github.com/cheggaaa/pb/v3 v3.1.5
 	github.com/gin-gonic/gin v1.10.0
 	github.com/zmap/go-iptree v0.0.0-20210731043055-d4e632617837
+	golang.org/x/crypto v0.23.0
 	golang.org/x/net v0.25.0
 )
 
@@ -36,7 +37,6 @@ require (
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.12 // indirect
 	golang.org/x/arch v0.8.0 // indirect
-	golang.org/x/crypto v0.23.0 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,199 @@
+package localtls
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	RegistrySourceBuiltin = "builtin"
+	RegistrySourceCCADB   = "ccadb"
+	RegistrySourceJSON    = "json"
+)
+
+var ErrRegistryColumns = errors.New("ccadb report is missing the owner, SHA-256 fingerprint or EV policy OID column")
+
+// PolicyEntry binds a policy OID to the CA owner and the root certificates entitled to issue under it.
+type PolicyEntry struct {
+	ObjectIdentifier string   `json:"oid"`
+	CAOwner          string   `json:"caOwner"`
+	RootFingerprints []string `json:"rootFingerprints"` // SHA-256 of the root certificate, lowercase hex
+}
+
+// PolicyRegistry maps EV policy OIDs to the roots that are entitled to them. Registries loaded from
+// the CCADB or a local JSON file take precedence, the built-in EVObjectIdentifiers map is used for
+// OIDs they do not know about (without root entitlement information).
+type PolicyRegistry struct {
+	Source   string
+	Policies map[string][]PolicyEntry
+}
+
+// policyRegistryFile is the local JSON format, e.g.
+// {"policies": [{"oid": "2.23.140.1.1", "caOwner": "...", "rootFingerprints": ["..."]}]}
+type policyRegistryFile struct {
+	Policies []PolicyEntry `json:"policies"`
+}
+
+// EVPolicyRegistry is the registry consulted during scans, replaced at startup when a registry file is provided.
+var EVPolicyRegistry = NewBuiltinPolicyRegistry()
+
+func NewBuiltinPolicyRegistry() *PolicyRegistry {
+	registry := &PolicyRegistry{Source: RegistrySourceBuiltin, Policies: make(map[string][]PolicyEntry)}
+	for oid, org := range EVObjectIdentifiers {
+		registry.Policies[oid] = []PolicyEntry{{ObjectIdentifier: oid, CAOwner: org, RootFingerprints: make([]string, 0)}}
+	}
+	return registry
+}
+
+// LoadPolicyRegistry reads a CCADB CSV report (.csv) or a local JSON registry (any other extension).
+func LoadPolicyRegistry(path string) (*PolicyRegistry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseCCADBReport(f)
+	}
+	return ParsePolicyRegistryJSON(f)
+}
+
+func ParsePolicyRegistryJSON(r io.Reader) (*PolicyRegistry, error) {
+	var file policyRegistryFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	registry := &PolicyRegistry{Source: RegistrySourceJSON, Policies: make(map[string][]PolicyEntry)}
+	for _, entry := range file.Policies {
+		registry.add(entry.ObjectIdentifier, entry.CAOwner, entry.RootFingerprints)
+	}
+	return registry, nil
+}
+
+// ParseCCADBReport parses a CCADB root certificate report such as Mozilla's
+// IncludedCACertificateReport. Columns are located by header name since the
+// exact layout differs between the report variants.
+func ParseCCADBReport(r io.Reader) (*PolicyRegistry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	ownerColumn, fingerprintColumn, oidColumn := -1, -1, -1
+	for i, column := range header {
+		name := strings.ToLower(strings.TrimSpace(column))
+		switch {
+		case name == "owner" || name == "ca owner":
+			ownerColumn = i
+		case strings.Contains(name, "sha-256 fingerprint"):
+			fingerprintColumn = i
+		case strings.Contains(name, "ev policy oid") || strings.Contains(name, "ev oid"):
+			oidColumn = i
+		}
+	}
+	if ownerColumn < 0 || fingerprintColumn < 0 || oidColumn < 0 {
+		return nil, ErrRegistryColumns
+	}
+
+	registry := &PolicyRegistry{Source: RegistrySourceCCADB, Policies: make(map[string][]PolicyEntry)}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) <= ownerColumn || len(row) <= fingerprintColumn || len(row) <= oidColumn {
+			continue
+		}
+		oids := strings.FieldsFunc(row[oidColumn], func(r rune) bool {
+			return r == ';' || r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+		})
+		for _, oid := range oids {
+			// Reports list "Not EV" for roots without an EV policy.
+			if !isDottedOID(oid) {
+				continue
+			}
+			registry.add(oid, row[ownerColumn], []string{row[fingerprintColumn]})
+		}
+	}
+	return registry, nil
+}
+
+func isDottedOID(oid string) bool {
+	if len(oid) == 0 {
+		return false
+	}
+	for _, r := range oid {
+		if (r < '0' || r > '9') && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeFingerprint lowercases and strips the colon separators used by CCADB reports.
+func NormalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
+
+func (p *PolicyRegistry) add(oid string, owner string, fingerprints []string) {
+	oid = strings.TrimSpace(oid)
+	owner = strings.TrimSpace(owner)
+	normalized := make([]string, 0, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		normalized = append(normalized, NormalizeFingerprint(fingerprint))
+	}
+	for i, entry := range p.Policies[oid] {
+		if entry.CAOwner == owner {
+			p.Policies[oid][i].RootFingerprints = append(entry.RootFingerprints, normalized...)
+			return
+		}
+	}
+	p.Policies[oid] = append(p.Policies[oid], PolicyEntry{ObjectIdentifier: oid, CAOwner: owner, RootFingerprints: normalized})
+}
+
+// Lookup returns the entries for an OID and the source they were found in, falling
+// back to the built-in map for OIDs the loaded registry does not contain.
+func (p *PolicyRegistry) Lookup(oid string) ([]PolicyEntry, string, bool) {
+	if entries, ok := p.Policies[oid]; ok {
+		return entries, p.Source, true
+	}
+	if p.Source != RegistrySourceBuiltin {
+		if org, ok := EVObjectIdentifiers[oid]; ok {
+			return []PolicyEntry{{ObjectIdentifier: oid, CAOwner: org, RootFingerprints: make([]string, 0)}}, RegistrySourceBuiltin, true
+		}
+	}
+	return nil, "", false
+}
+
+// EntitledOwner returns the CA owner whose root, identified by its SHA-256 fingerprint, is entitled to the OID.
+func EntitledOwner(entries []PolicyEntry, rootFingerprint string) (string, bool) {
+	rootFingerprint = NormalizeFingerprint(rootFingerprint)
+	for _, entry := range entries {
+		for _, fingerprint := range entry.RootFingerprints {
+			if fingerprint == rootFingerprint {
+				return entry.CAOwner, true
+			}
+		}
+	}
+	return "", false
+}
+
+// HasRootData reports whether any entry carries root fingerprints, i.e. entitlement can be verified.
+func HasRootData(entries []PolicyEntry) bool {
+	for _, entry := range entries {
+		if len(entry.RootFingerprints) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package testing
+
+import (
+	"Scanner/localtls"
+	"strings"
+	"testing"
+)
+
+func TestParseCCADBReport(t *testing.T) {
+	report := `"Owner","Certificate Name","SHA-256 Fingerprint","EV Policy OID(s)"
+"Example CA","Example Root R1","AB:CD:EF","2.23.140.1.1; 1.2.3.4.5"
+"Other CA","Other Root","0123","Not EV"
+"Other CA","Other EV Root","4567","2.23.140.1.1"
+`
+	registry, err := localtls.ParseCCADBReport(strings.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Source != localtls.RegistrySourceCCADB {
+		t.Errorf("Unexpected registry source %v\n", registry.Source)
+	}
+
+	entries, source, ok := registry.Lookup("2.23.140.1.1")
+	if !ok || source != localtls.RegistrySourceCCADB || len(entries) != 2 {
+		t.Fatalf("Unexpected lookup result %v %v %v\n", entries, source, ok)
+	}
+	if owner, entitled := localtls.EntitledOwner(entries, "abcdef"); !entitled || owner != "Example CA" {
+		t.Errorf("Expected Example CA root to be entitled, got %v %v\n", owner, entitled)
+	}
+	if _, entitled := localtls.EntitledOwner(entries, "0123"); entitled {
+		t.Errorf("Root without an EV policy should not be entitled\n")
+	}
+
+	// OIDs unknown to the CCADB report fall back to the built-in map without root data.
+	entries, source, ok = registry.Lookup("2.16.756.1.89.1.2.1.1")
+	if !ok || source != localtls.RegistrySourceBuiltin || localtls.HasRootData(entries) {
+		t.Errorf("Expected a built-in fallback without root data, got %v %v %v\n", entries, source, ok)
+	}
+}
+
+func TestParseCCADBReportMissingColumns(t *testing.T) {
+	_, err := localtls.ParseCCADBReport(strings.NewReader("\"Owner\",\"Certificate Name\"\n"))
+	if err != localtls.ErrRegistryColumns {
+		t.Errorf("Expected ErrRegistryColumns, got %v\n", err)
+	}
+}
@@ -0,0 +1,242 @@
+package policy_cache_server
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MX_CACHE_PATH = "dataset/mx-cache"
+	MX_CACHE_MIN_LIFETIME = 1 * time.Hour
+	MX_CACHE_MAX_LIFETIME = 24 * time.Hour
+	mxSnapshotSuffix      = ".json"
+)
+
+var ErrMXCacheMiss = errors.New("mx cache miss")
+
+// MXCacheEntry is the snapshot written to disk for every cached MX
+type MXCacheEntry struct {
+	MX        string              `json:"mx"`
+	Data      json.RawMessage     `json:"data"` // structs.MXSpecificData of the accepted submission
+	ExpiresAt time.Time           `json:"expiresAt"`
+	Evidence  []MXSubmission      `json:"evidence"` // oldest first, at most MX_CONSENSUS_EVIDENCE_LIMIT
+	Decision  MXConsensusDecision `json:"decision"`
+}
+
+// MXCacheRemoveCallback is called for every entry that expires
+type MXCacheRemoveCallback func(entry MXCacheEntry)
+
+// MXCache keeps MX scan results in memory and one snapshot file per MX on disk, so a restart keeps
+// every locked in MX. Entries expire with the MX record TTL and the scan age, see MXSpecificData.ExpiresAt.
+type MXCache struct {
+	mutex       sync.Mutex
+	entries     map[string]MXCacheEntry
+	Directory   string
+	MinLifetime time.Duration
+	MaxLifetime time.Duration
+	onRemove    MXCacheRemoveCallback
+	now         func() time.Time
+}
+
+// NewMXCache loads the snapshots of directory, DEFAULT_MX_CACHE_PATH when empty. Expired snapshots are deleted.
+func NewMXCache(directory string, cb MXCacheRemoveCallback) (*MXCache, error) {
+	if len(strings.TrimSpace(directory)) == 0 {
+		log.Printf("Using the default MX cache directory since none was provided. %v\n", DEFAULT_MX_CACHE_PATH)
+		directory = DEFAULT_MX_CACHE_PATH
+	}
+	cache := &MXCache{
+		entries:     make(map[string]MXCacheEntry),
+		Directory:   directory,
+		MinLifetime: MX_CACHE_MIN_LIFETIME,
+		MaxLifetime: MX_CACHE_MAX_LIFETIME,
+		onRemove:    cb,
+		now:         time.Now,
+	}
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), mxSnapshotSuffix) {
+			continue
+		}
+		path := filepath.Join(directory, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var entry MXCacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil || len(entry.MX) == 0 {
+			log.Printf("[MXCache] ignoring unreadable snapshot %s: %v\n", path, err)
+			continue
+		}
+		if !cache.now().Before(entry.ExpiresAt) {
+			os.Remove(path)
+			continue
+		}
+		cache.entries[entry.MX] = entry
+	}
+	return cache, nil
+}
+
+func (c *MXCache) snapshotPath(mx string) string {
+	return filepath.Join(c.Directory, url.PathEscape(mx)+mxSnapshotSuffix)
+}
+
+// Get returns the submitted bytes of an unexpired entry
+func (c *MXCache) Get(mx string) ([]byte, error) {
+	entry, err := c.Lookup(mx)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Data, nil
+}
+
+// Lookup returns an unexpired entry with its evidence and consensus decision
+func (c *MXCache) Lookup(mx string) (MXCacheEntry, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[mx]
+	if !ok {
+		return MXCacheEntry{}, ErrMXCacheMiss
+	}
+	if !c.now().Before(entry.ExpiresAt) {
+		c.removeLocked(mx)
+		return MXCacheEntry{}, ErrMXCacheMiss
+	}
+	return entry, nil
+}
+
+// Set stores the MXSpecificData bytes and their snapshot, the expiry is derived from the data.
+func (c *MXCache) Set(mx string, data []byte) error {
+	var mxData structs.MXSpecificData
+	if err := json.Unmarshal(data, &mxData); err != nil {
+		return err
+	}
+	entry := MXCacheEntry{MX: mx, Data: data, ExpiresAt: mxData.ExpiresAt(c.now(), c.MinLifetime, c.MaxLifetime)}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.storeLocked(entry)
+}
+
+// Submit adds a submission to the evidence of mx and lets policy decide which submission the entry
+// serves. Once locked in, an entry ignores submissions until it expires. The returned bool reports
+// whether this submission locked the entry in.
+func (c *MXCache) Submit(mx string, data []byte, policy MXConsensusPolicy) (MXCacheEntry, bool, error) {
+	now := c.now()
+	submission, err := NewMXSubmission(data, now)
+	if err != nil {
+		return MXCacheEntry{}, false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[mx]
+	if ok && !now.Before(entry.ExpiresAt) {
+		c.removeLocked(mx)
+		ok = false
+	}
+	if ok && entry.Decision.LockedIn {
+		return entry, false, nil
+	}
+	if !ok {
+		entry = MXCacheEntry{MX: mx}
+	}
+	evidence := append(append(make([]MXSubmission, 0, len(entry.Evidence)+1), entry.Evidence...), submission)
+	if len(evidence) > MX_CONSENSUS_EVIDENCE_LIMIT {
+		evidence = evidence[len(evidence)-MX_CONSENSUS_EVIDENCE_LIMIT:]
+	}
+	entry.Evidence = evidence
+	entry.Decision = policy.Decide(evidence)
+
+	// Serve the latest submission of the accepted fingerprint, its scan time drives the expiry
+	for i := len(evidence) - 1; i >= 0; i-- {
+		if evidence[i].Digest != entry.Decision.Accepted {
+			continue
+		}
+		var mxData structs.MXSpecificData
+		if err := json.Unmarshal(evidence[i].Data, &mxData); err != nil {
+			return entry, false, err
+		}
+		mxData.SeenCount = entry.Decision.Agreeing
+		entry.Data, _ = json.Marshal(mxData)
+		entry.ExpiresAt = mxData.ExpiresAt(evidence[i].ReceivedAt, c.MinLifetime, c.MaxLifetime)
+		break
+	}
+	return entry, entry.Decision.LockedIn, c.storeLocked(entry)
+}
+
+func (c *MXCache) storeLocked(entry MXCacheEntry) error {
+	snapshot, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	c.entries[entry.MX] = entry
+	// Write then rename so a crash never leaves a truncated snapshot behind
+	path := c.snapshotPath(entry.MX)
+	if err := os.WriteFile(path+".tmp", snapshot, 0o644); err != nil {
+		return fmt.Errorf("unable to persist %s: %w", entry.MX, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Expire removes every expired entry, the server calls it periodically
+func (c *MXCache) Expire() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	removed := 0
+	now := c.now()
+	for mx, entry := range c.entries {
+		if !now.Before(entry.ExpiresAt) {
+			c.removeLocked(mx)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *MXCache) removeLocked(mx string) {
+	entry := c.entries[mx]
+	delete(c.entries, mx)
+	if err := os.Remove(c.snapshotPath(mx)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[MXCache] unable to delete the snapshot of %s: %v\n", mx, err)
+	}
+	if c.onRemove != nil {
+		c.onRemove(entry)
+	}
+}
+
+// Range calls fn for every entry, expired or not
+func (c *MXCache) Range(fn func(entry MXCacheEntry)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, entry := range c.entries {
+		fn(entry)
+	}
+}
+
+// ExpiresAt reports when the entry of mx expires
+func (c *MXCache) ExpiresAt(mx string) (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[mx]
+	return entry.ExpiresAt, ok
+}
+
+// SetClock replaces time.Now, for tests
+func (c *MXCache) SetClock(now func() time.Time) {
+	c.now = now
+}
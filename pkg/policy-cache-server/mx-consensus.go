@@ -0,0 +1,182 @@
+package policy_cache_server
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MX_CONSENSUS_QUORUM         = "quorum"
+	MX_CONSENSUS_UNANIMOUS      = "unanimous"
+	DEFAULT_MX_CONSENSUS        = MX_CONSENSUS_QUORUM
+	DEFAULT_MX_CONSENSUS_QUORUM = 0.8
+	MX_CONSENSUS_EVIDENCE_LIMIT = 20 // submissions kept per MX, oldest dropped first
+)
+
+// MXSubmission is the evidence kept for every scan submitted for an MX
+type MXSubmission struct {
+	ReceivedAt  time.Time             `json:"receivedAt"`
+	ScannedAt   time.Time             `json:"scannedAt"`
+	Digest      string                `json:"digest"` // MXFingerprint.Digest
+	Fingerprint structs.MXFingerprint `json:"fingerprint"`
+	Data        json.RawMessage       `json:"data,omitempty"` // the submitted MXSpecificData, left out of explanations
+}
+
+// NewMXSubmission fingerprints the submitted MXSpecificData bytes
+func NewMXSubmission(data []byte, received time.Time) (MXSubmission, error) {
+	var mxData structs.MXSpecificData
+	if err := json.Unmarshal(data, &mxData); err != nil {
+		return MXSubmission{}, err
+	}
+	fingerprint := mxData.Fingerprint()
+	return MXSubmission{
+		ReceivedAt:  received,
+		ScannedAt:   mxData.ScannedAt,
+		Digest:      fingerprint.Digest(),
+		Fingerprint: fingerprint,
+		Data:        data,
+	}, nil
+}
+
+// MXConsensusDecision is the outcome of a policy over the evidence of an MX
+type MXConsensusDecision struct {
+	Policy     string   `json:"policy"`
+	LockedIn   bool     `json:"lockedIn"`
+	Accepted   string   `json:"accepted"` // digest of the fingerprint the cache serves
+	Agreeing   int      `json:"agreeing"` // submissions counted towards the accepted fingerprint
+	Dissenting int      `json:"dissenting"`
+	Required   int      `json:"required"`
+	Reasons    []string `json:"reasons"`
+}
+
+// MXConsensusPolicy decides from the submissions of an MX, oldest first, which fingerprint
+// the cache serves and whether it is locked in.
+type MXConsensusPolicy interface {
+	Name() string
+	Decide(evidence []MXSubmission) MXConsensusDecision
+}
+
+// NewMXConsensusPolicy returns the policy called name, requiring required agreeing submissions
+func NewMXConsensusPolicy(name string, required int) (MXConsensusPolicy, error) {
+	if required < 1 {
+		return nil, fmt.Errorf("an MX consensus needs at least one submission, got %d", required)
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case MX_CONSENSUS_QUORUM, "":
+		return QuorumConsensus{Required: required, Quorum: DEFAULT_MX_CONSENSUS_QUORUM}, nil
+	case MX_CONSENSUS_UNANIMOUS:
+		return UnanimousConsensus{Required: required}, nil
+	default:
+		return nil, fmt.Errorf("unknown MX consensus policy %q, expected %s or %s", name, MX_CONSENSUS_QUORUM, MX_CONSENSUS_UNANIMOUS)
+	}
+}
+
+// QuorumConsensus locks in the most submitted fingerprint once it has Required submissions
+// and at least the Quorum share of the evidence. Ties go to the most recent fingerprint.
+type QuorumConsensus struct {
+	Required int
+	Quorum   float64
+}
+
+func (QuorumConsensus) Name() string { return MX_CONSENSUS_QUORUM }
+
+func (q QuorumConsensus) Decide(evidence []MXSubmission) MXConsensusDecision {
+	decision := MXConsensusDecision{Policy: q.Name(), Required: q.Required, Reasons: make([]string, 0)}
+	if len(evidence) == 0 {
+		decision.Reasons = append(decision.Reasons, "no submissions")
+		return decision
+	}
+	counts := make(map[string]int)
+	latest := make(map[string]int) // digest : index of its latest submission
+	for i, submission := range evidence {
+		counts[submission.Digest]++
+		latest[submission.Digest] = i
+	}
+	for digest, count := range counts {
+		if count > decision.Agreeing || (count == decision.Agreeing && latest[digest] > latest[decision.Accepted]) {
+			decision.Accepted, decision.Agreeing = digest, count
+		}
+	}
+	decision.Dissenting = len(evidence) - decision.Agreeing
+	share := float64(decision.Agreeing) / float64(len(evidence))
+	decision.LockedIn = decision.Agreeing >= q.Required && share >= q.Quorum
+
+	decision.Reasons = append(decision.Reasons, fmt.Sprintf("%d of %d submissions agree on %s (%.0f%%), %d and %.0f%% required",
+		decision.Agreeing, len(evidence), shortDigest(decision.Accepted), share*100, q.Required, q.Quorum*100))
+	decision.Reasons = append(decision.Reasons, dissentReasons(evidence, decision.Accepted, latest[decision.Accepted])...)
+	return decision
+}
+
+// UnanimousConsensus locks in once the latest Required submissions share one fingerprint,
+// any differing submission restarts the count.
+type UnanimousConsensus struct {
+	Required int
+}
+
+func (UnanimousConsensus) Name() string { return MX_CONSENSUS_UNANIMOUS }
+
+func (u UnanimousConsensus) Decide(evidence []MXSubmission) MXConsensusDecision {
+	decision := MXConsensusDecision{Policy: u.Name(), Required: u.Required, Reasons: make([]string, 0)}
+	if len(evidence) == 0 {
+		decision.Reasons = append(decision.Reasons, "no submissions")
+		return decision
+	}
+	last := len(evidence) - 1
+	decision.Accepted = evidence[last].Digest
+	for i := last; i >= 0 && evidence[i].Digest == decision.Accepted; i-- {
+		decision.Agreeing++
+	}
+	decision.Dissenting = len(evidence) - decision.Agreeing
+	decision.LockedIn = decision.Agreeing >= u.Required
+
+	decision.Reasons = append(decision.Reasons, fmt.Sprintf("the latest %d submissions agree on %s, %d required",
+		decision.Agreeing, shortDigest(decision.Accepted), u.Required))
+	if decision.Dissenting > 0 {
+		breaking := evidence[last-decision.Agreeing]
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf("the count restarted after the submission of %s",
+			breaking.ReceivedAt.UTC().Format(time.RFC3339)))
+	}
+	decision.Reasons = append(decision.Reasons, dissentReasons(evidence, decision.Accepted, last)...)
+	return decision
+}
+
+// dissentReasons describes every other fingerprint against the accepted one, most submitted first
+func dissentReasons(evidence []MXSubmission, accepted string, acceptedIndex int) []string {
+	counts := make(map[string]int)
+	latest := make(map[string]int)
+	for i, submission := range evidence {
+		if submission.Digest != accepted {
+			counts[submission.Digest]++
+			latest[submission.Digest] = i
+		}
+	}
+	digests := make([]string, 0, len(counts))
+	for digest := range counts {
+		digests = append(digests, digest)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		if counts[digests[i]] != counts[digests[j]] {
+			return counts[digests[i]] > counts[digests[j]]
+		}
+		return digests[i] < digests[j]
+	})
+
+	reasons := make([]string, 0, len(digests))
+	for _, digest := range digests {
+		differences := evidence[acceptedIndex].Fingerprint.Differences(evidence[latest[digest]].Fingerprint)
+		reasons = append(reasons, fmt.Sprintf("%d submissions of %s differ: %s",
+			counts[digest], shortDigest(digest), strings.Join(differences, "; ")))
+	}
+	return reasons
+}
+
+func shortDigest(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
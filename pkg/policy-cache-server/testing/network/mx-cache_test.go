@@ -0,0 +1,107 @@
+package network
+
+import (
+	server "Scanner/pkg/policy-cache-server"
+	"Scanner/pkg/scanner/structs"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mxEntry(t *testing.T, scannedAt time.Time, ttl uint32, seenCount int) []byte {
+	data, err := json.Marshal(structs.MXSpecificData{ScannedAt: scannedAt, MXRecordTTL: ttl, SeenCount: seenCount, PortCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMXCacheExpiry(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache, err := server.NewMXCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetClock(func() time.Time { return now })
+
+	// TTL inside the bounds, below the minimum, above the maximum and a submission without a scan time
+	cache.Set("mx1.example.gov.", mxEntry(t, now.Add(-time.Hour), 4*3600, 5))
+	cache.Set("mx2.example.gov.", mxEntry(t, now, 300, 5))
+	cache.Set("mx3.example.gov.", mxEntry(t, now, 7*86400, 5))
+	cache.Set("mx4.example.gov.", mxEntry(t, time.Time{}, 7200, 1))
+	expected := map[string]time.Time{
+		"mx1.example.gov.": now.Add(3 * time.Hour),
+		"mx2.example.gov.": now.Add(server.MX_CACHE_MIN_LIFETIME),
+		"mx3.example.gov.": now.Add(server.MX_CACHE_MAX_LIFETIME),
+		"mx4.example.gov.": now.Add(2 * time.Hour),
+	}
+	for mx, expiry := range expected {
+		if actual, ok := cache.ExpiresAt(mx); !ok || !actual.Equal(expiry) {
+			t.Errorf("%s: expected expiry %v, got %v\n", mx, expiry, actual)
+		}
+	}
+
+	now = now.Add(150 * time.Minute)
+	cache.SetClock(func() time.Time { return now })
+	if _, err := cache.Get("mx2.example.gov."); !errors.Is(err, server.ErrMXCacheMiss) {
+		t.Errorf("Expected mx2 to have expired, got %v\n", err)
+	}
+	if removed := cache.Expire(); removed != 1 {
+		t.Errorf("Expected mx4 to expire, %d removed\n", removed)
+	}
+	if _, err := cache.Get("mx1.example.gov."); err != nil {
+		t.Errorf("Expected mx1 to be cached: %v\n", err)
+	}
+}
+
+func TestMXCachePersistence(t *testing.T) {
+	directory := t.TempDir()
+	removed := make([]string, 0)
+	cache, err := server.NewMXCache(directory, func(entry server.MXCacheEntry) { removed = append(removed, entry.MX) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := mxEntry(t, time.Now().UTC(), 3600, 5)
+	if err := cache.Set("mx1.example.gov.", entry); err != nil {
+		t.Fatal(err)
+	}
+	cache.Set("mx2.example.gov.", mxEntry(t, time.Now().UTC(), 3600, 5))
+
+	// A snapshot that expired while the server was down is dropped on load
+	expired, _ := json.Marshal(server.MXCacheEntry{MX: "old.example.gov.", Data: mxEntry(t, time.Now().Add(-48*time.Hour), 3600, 5), ExpiresAt: time.Now().Add(-time.Hour)})
+	os.WriteFile(filepath.Join(directory, "old.example.gov..json"), expired, 0o644)
+	os.WriteFile(filepath.Join(directory, "garbage.json"), []byte("{"), 0o644)
+
+	reloaded, err := server.NewMXCache(directory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := reloaded.Get("mx1.example.gov.")
+	if err != nil || string(data) != string(entry) {
+		t.Errorf("Expected mx1 to survive the restart: %s %v\n", data, err)
+	}
+	if _, err := reloaded.Get("old.example.gov."); !errors.Is(err, server.ErrMXCacheMiss) {
+		t.Errorf("Expected the expired snapshot to be dropped, got %v\n", err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "old.example.gov..json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the expired snapshot to be deleted, got %v\n", err)
+	}
+	count := 0
+	reloaded.Range(func(server.MXCacheEntry) { count++ })
+	if count != 2 {
+		t.Errorf("Expected 2 entries after the restart, got %d\n", count)
+	}
+
+	// Expiry removes the snapshot and reports the entry
+	cache.SetClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	cache.Expire()
+	if len(removed) != 2 {
+		t.Errorf("Expected both entries to be reported as removed, got %v\n", removed)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "mx1.example.gov..json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the snapshot of mx1 to be deleted, got %v\n", err)
+	}
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	server "Scanner/pkg/policy-cache-server"
+	"Scanner/pkg/scanner/structs"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mxScan is a scan of mx.example.gov serving suites on port 25 and 465 with the given leaf
+func mxScan(t *testing.T, scannedAt time.Time, suites []uint16, leaf string) []byte {
+	record := func() structs.TLSCombinedRecord {
+		return structs.TLSCombinedRecord{
+			CipherSuites: map[string][]structs.VersionSuitesRecord{
+				"192.0.2.25": {
+					{TLSVersion: 0x0303, IsSupported: len(suites) > 0, SupportedCipherSuites: suites},
+					{TLSVersion: 0x0302, IsSupported: false},
+				},
+			},
+			CertificateChains: map[string][]string{"192.0.2.25": {leaf, "intermediate"}},
+		}
+	}
+	data, err := json.Marshal(structs.MXSpecificData{
+		MXTLSInformation: map[string]structs.TLSCombinedRecord{
+			"mx.example.gov.:25":  record(),
+			"mx.example.gov.:465": record(),
+		},
+		ScannedAt:   scannedAt,
+		MXRecordTTL: 3600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMXFingerprint(t *testing.T) {
+	var full, degraded structs.MXSpecificData
+	json.Unmarshal(mxScan(t, time.Time{}, []uint16{0xc02f, 0xc02b}, "leaf"), &full)
+	json.Unmarshal(mxScan(t, time.Time{}, []uint16{0xc02b}, "other-leaf"), &degraded)
+
+	fingerprint := full.Fingerprint()
+	if port := fingerprint["25"]; len(port.Suites) != 1 || len(port.Suites[0x0303]) != 2 || port.Suites[0x0303][0] != 0xc02b || len(port.Certificates) != 1 {
+		t.Errorf("Unexpected fingerprint %+v\n", fingerprint)
+	}
+	if fingerprint.Digest() != full.Fingerprint().Digest() || fingerprint.Digest() == degraded.Fingerprint().Digest() {
+		t.Errorf("Expected digests to follow the fingerprint\n")
+	}
+	differences := fingerprint.Differences(degraded.Fingerprint())
+	if len(differences) != 4 || differences[0] != "port 25: version 0x0303 suites differ (1 missing, 0 added)" || differences[1] != "port 25: leaf certificates differ" {
+		t.Errorf("Unexpected differences %v\n", differences)
+	}
+	if len(fingerprint.Differences(fingerprint)) != 0 {
+		t.Errorf("Expected no differences between equal fingerprints\n")
+	}
+}
+
+func TestMXConsensus(t *testing.T) {
+	now := time.Now().UTC()
+	full := mxScan(t, now, []uint16{0xc02f, 0xc02b}, "leaf")
+	degraded := mxScan(t, now, []uint16{0xc02b}, "leaf")
+
+	// A transient failure with lower counts never becomes the cached result under a quorum
+	quorum, err := server.NewMXConsensusPolicy(server.MX_CONSENSUS_QUORUM, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := server.NewMXCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, submission := range [][]byte{degraded, full, full, full, full} {
+		if entry, lockedIn, err := cache.Submit("mx.example.gov.", submission, quorum); err != nil || lockedIn {
+			t.Fatalf("Submission %d: unexpected lock in %+v %v\n", i, entry.Decision, err)
+		}
+	}
+	entry, lockedIn, err := cache.Submit("mx.example.gov.", full, quorum)
+	if err != nil || !lockedIn || entry.Decision.Agreeing != 5 || entry.Decision.Dissenting != 1 {
+		t.Fatalf("Expected 5 of 6 submissions to lock in, got %+v %v\n", entry.Decision, err)
+	}
+	var served structs.MXSpecificData
+	json.Unmarshal(entry.Data, &served)
+	if served.Fingerprint()["25"].Suites[0x0303][0] != 0xc02b || len(served.Fingerprint()["25"].Suites[0x0303]) != 2 || served.SeenCount != 5 {
+		t.Errorf("Expected the full scan to be served, got %+v\n", served)
+	}
+	if len(entry.Evidence) != 6 || !strings.Contains(strings.Join(entry.Decision.Reasons, "\n"), "1 submissions of") {
+		t.Errorf("Expected the dissenting submission to be explained, got %v\n", entry.Decision.Reasons)
+	}
+	if _, lockedIn, _ := cache.Submit("mx.example.gov.", degraded, quorum); lockedIn {
+		t.Errorf("Expected a locked in entry to ignore submissions\n")
+	}
+	if entry, _ := cache.Lookup("mx.example.gov."); len(entry.Evidence) != 6 {
+		t.Errorf("Expected the evidence to stay unchanged, got %d submissions\n", len(entry.Evidence))
+	}
+
+	// Unanimous restarts the count on every differing submission
+	unanimous, _ := server.NewMXConsensusPolicy(server.MX_CONSENSUS_UNANIMOUS, 3)
+	for i, submission := range [][]byte{full, full, degraded, full, full} {
+		if _, lockedIn, _ := cache.Submit("mx2.example.gov.", submission, unanimous); lockedIn {
+			t.Fatalf("Submission %d: unexpected lock in\n", i)
+		}
+	}
+	if entry, lockedIn, _ := cache.Submit("mx2.example.gov.", full, unanimous); !lockedIn || entry.Decision.Agreeing != 3 {
+		t.Errorf("Expected the latest 3 submissions to lock in, got %+v\n", entry.Decision)
+	}
+
+	if _, err := server.NewMXConsensusPolicy("majority", 5); err == nil {
+		t.Errorf("Expected an unknown policy to be rejected\n")
+	}
+	if _, _, err := cache.Submit("mx3.example.gov.", []byte("{"), quorum); err == nil {
+		t.Errorf("Expected an invalid submission to be rejected\n")
+	}
+}
@@ -1,10 +1,14 @@
 package scanner
 
 import (
+	"Scanner/localtls"
 	"Scanner/pkg/scanner/network"
 	"Scanner/pkg/scanner/storage"
 	"Scanner/pkg/scanner/structs"
+	"fmt"
+	"log"
 	"net"
+	"strings"
 
 	"github.com/miekg/dns"
 	"github.com/urfave/cli/v2"
@@ -14,7 +18,18 @@ func HandleTLSScanRequests(context *cli.Context) error {
 	hostname := context.String("hostname")
 	noserver := context.Bool("noserver")
 
-	records, err := PerformTLSScan(structs.Request{Hostname: hostname, NoServer: noserver})
+	options, err := newTLSScanOptions(context)
+	if err != nil {
+		return err
+	}
+
+	request := structs.Request{
+		Hostname: hostname,
+		NoServer: noserver,
+		Port:     strings.TrimSpace(context.String("port")),
+		Protocol: strings.TrimSpace(context.String("protocol")),
+	}
+	records, err := PerformTLSScan(request, options)
 	if err != nil {
 		mapError := make(map[string]string, 0)
 		mapError["error"] = err.Error()
@@ -29,12 +44,36 @@ func HandleMailScanRequests(context *cli.Context) error {
 	noserver := context.Bool("noserver")
 	nocachemx := context.Bool("no-cache-mx")
 
-	mailServers, mailServerPriority, err := network.ResolveMXRecords(hostname)
-	mailScanResponse := structs.MailScanCombinedRecord{}
+	options, err := newTLSScanOptions(context)
+	if err != nil {
+		return err
+	}
+	var dkimSelectors []string
+	if selectorPath := strings.TrimSpace(context.String("dkim-selectors")); len(selectorPath) > 0 {
+		dkimSelectors, err = network.LoadDKIMSelectors(selectorPath)
+		if err != nil {
+			return err
+		}
+	}
+	providerRules, err := loadProviderRules(context)
 	if err != nil {
-		mailScanResponse.MailHost = hostname
+		return err
+	}
+	if err := loadTrustAnchors(context); err != nil {
+		return err
 	}
 
+	// A Null MX or a domain without MX and address records leaves nothing to scan
+	resolution, err := network.ResolveMailHandling(hostname)
+	if err != nil {
+		log.Printf("[MX] unable to resolve the mail handling of %s: %v\n", hostname, err)
+	}
+	mailServers, mailServerPriority := resolution.Servers, resolution.Priority
+	mailScanResponse := structs.MailScanCombinedRecord{}
+	mailScanResponse.MailHost = hostname
+	mailScanResponse.MailHandling = resolution.MailHandling
+	mailScanResponse.MXRecordTTL = resolution.TTL
+
 	scannedRecords := make(chan map[string]structs.MXSpecificData, 1)
 	// Populuated by cache and eventually scanned MX records
 	allRecordsAndCertificates := make(map[string]structs.MXSpecificData)
@@ -72,10 +111,12 @@ func HandleMailScanRequests(context *cli.Context) error {
 		mailHostsToIPs,
 		filteredMailHostsToIPs,
 		cachedMXs,
+		options,
 		scannedRecords)
 
 	// Cache MX data & join scanned data with cached mx data
 	for hostname, data := range <-scannedRecords {
+		data.MXRecordTTL = resolution.TTL
 		if !(noserver || nocachemx) {
 			// populate PortCount, TLSVersionCount, TLSCipherSuiteCount
 			network.SetMXData(data, hostname)
@@ -86,7 +127,11 @@ func HandleMailScanRequests(context *cli.Context) error {
 	// Dump all data
 	mailRecords := make(map[string]structs.TLSCombinedRecord)
 	bannerAndCapabilties := make(map[string]structs.SMTPMetadata)
+	endpoints := make([]structs.MailEndpointRecord, 0)
 	for _, v := range allRecordsAndCertificates {
+		for _, endpoint := range v.MXEndpoints {
+			endpoints = append(endpoints, endpoint)
+		}
 		for ipPort, tlsData := range v.MXTLSInformation {
 			mailRecords[ipPort] = tlsData
 		}
@@ -101,14 +146,127 @@ func HandleMailScanRequests(context *cli.Context) error {
 	mailScanResponse.NumResolvedMX = len(mailServers)
 	mailScanResponse.MXTLSInformation = mailRecords
 	mailScanResponse.MailServerMetadata = bannerAndCapabilties
+	structs.SortMailEndpoints(endpoints)
+	mailScanResponse.Endpoints = endpoints
 	mailScanResponse.IdentifyReachableAndSecurePorts()
+	mtaSTS := network.NewMTASTSChecker(nil, nil).Check(hostname, mailServers, mailRecords)
+	mailScanResponse.MTASTS = &mtaSTS
+	mailScanResponse.DANE = make(map[string]structs.DANERecord)
+	for _, mx := range mailServers {
+		var port25 *structs.TLSCombinedRecord
+		if tlsRecord, ok := mailRecords[net.JoinHostPort(mx, "25")]; ok {
+			port25 = &tlsRecord
+		}
+		mailScanResponse.DANE[mx] = network.CheckDANE(mx, port25, allRecordsAndCertificates[mx].CertificateChains, noserver)
+	}
+	// Opted out IPs are not scanned but still send mail for the domain
+	mxIPs := make(map[string][]net.IP)
+	for _, mx := range mailServers {
+		mxIPs[mx] = append(append([]net.IP{}, scannableMailHostsToIPs[mx]...), filteredMailHostsToIPs[mx]...)
+	}
+	spf := network.NewSPFChecker(nil).Check(hostname, mxIPs)
+	mailScanResponse.SPF = &spf
+	mailPolicies := network.NewMailPolicyChecker(nil)
+	dmarc, tlsRPT, bimi := mailPolicies.DMARC(hostname), mailPolicies.TLSRPT(hostname), mailPolicies.BIMI(hostname)
+	mailScanResponse.DMARC, mailScanResponse.TLSRPT, mailScanResponse.BIMI = &dmarc, &tlsRPT, &bimi
+	dkim := network.NewDKIMProber(nil, dkimSelectors).Probe(hostname)
+	mailScanResponse.DKIM = &dkim
+	if providerRules != nil {
+		provider := providerRules.Classify(hostname, mailServers, bannerAndCapabilties, mailRecords)
+		mailScanResponse.MailProvider = &provider
+	}
+	mailScanResponse.ReverseDNS = network.MailReverseDNS(network.NewMailResolver(""), mailServers, mxIPs, mailRecords, endpoints)
 
 	return storage.GenerateOutputAndTeardown(context, mailScanResponse)
 }
 
+// loadProviderRules reads the --provider-rules file. Without the flag the rules shipped in the dataset
+// are used when found beside the working directory or the executable, a missing default file only
+// disables provider attribution.
+func loadProviderRules(context *cli.Context) (*network.MailProviderRules, error) {
+	if rulesPath := strings.TrimSpace(context.String("provider-rules")); len(rulesPath) > 0 {
+		return network.LoadMailProviderRules(rulesPath)
+	}
+	rulesPath := network.DefaultProviderRulesPath()
+	rules, err := network.LoadMailProviderRules(rulesPath)
+	if err != nil {
+		log.Printf("[Provider] mail provider attribution disabled, unable to load %s: %v\n", rulesPath, err)
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// loadTrustAnchors replaces the built-in root KSKs with the anchors of every --trust-anchor file
+func loadTrustAnchors(context *cli.Context) error {
+	anchors := make(network.TrustAnchors, 0)
+	for _, anchorPath := range context.StringSlice("trust-anchor") {
+		if len(strings.TrimSpace(anchorPath)) == 0 {
+			continue
+		}
+		loaded, err := network.LoadTrustAnchors(strings.TrimSpace(anchorPath))
+		if err != nil {
+			return err
+		}
+		anchors = append(anchors, loaded...)
+	}
+	if len(anchors) > 0 {
+		network.RootTrustAnchors = anchors
+	}
+	return nil
+}
+
+// newTLSScanOptions prepares the batch wide TLS scan collaborators requested on the command line
+func newTLSScanOptions(context *cli.Context) (network.TLSScanOptions, error) {
+	options := network.TLSScanOptions{}
+	certificateStore, err := openCertificateStore(context)
+	if err != nil {
+		return options, err
+	}
+	options.CertificateStore = certificateStore
+	if context.Bool("check-revocation") {
+		options.RevocationChecker = network.NewRevocationChecker(nil)
+	}
+	if signaturePath := strings.TrimSpace(context.String("fingerprint-db")); len(signaturePath) > 0 {
+		options.ServerSignatures, err = network.LoadServerSignatures(signaturePath)
+		if err != nil {
+			return options, err
+		}
+	}
+	if err := loadPolicyRegistry(context); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// openCertificateStore returns nil when no --cert-dir is provided, which disables certificate persistence.
+func openCertificateStore(context *cli.Context) (*storage.CertificateStore, error) {
+	certificateDirectory := strings.TrimSpace(context.String("cert-dir"))
+	if len(certificateDirectory) == 0 {
+		return nil, nil
+	}
+	return storage.NewCertificateStore(certificateDirectory)
+}
+
+// loadPolicyRegistry replaces the built-in EV registry with the --ev-registry file
+func loadPolicyRegistry(context *cli.Context) error {
+	registryPath := strings.TrimSpace(context.String("ev-registry"))
+	if len(registryPath) == 0 {
+		return nil
+	}
+	registry, err := localtls.LoadPolicyRegistry(registryPath)
+	if err != nil {
+		return fmt.Errorf("unable to load EV policy registry %v: %w", registryPath, err)
+	}
+	localtls.EVPolicyRegistry = registry
+	return nil
+}
+
 func HandleDNSScanRequests(context *cli.Context) error {
 	hostname := dns.Fqdn(context.String("hostname"))
 	noserver := context.Bool("noserver")
+	if err := loadTrustAnchors(context); err != nil {
+		return err
+	}
 
 	queryType := network.ConvertQueryTypeStringToDNSType(context.String("query-type"))
 	request := structs.DNSRequest{Hostname: hostname, QueryType: queryType, NoServer: noserver}
@@ -116,7 +274,7 @@ func HandleDNSScanRequests(context *cli.Context) error {
 	dnssec := PerformDNSSECScan(request)
 	resolved := false
 
-	if dnssec.Reason != network.ErrNoResult.Error() {
+	if dnssec.Reason != network.ErrNoResult.Error() && dnssec.Denial == nil {
 		resolved = true
 	}
 	ns, err := net.LookupNS(hostname)
@@ -126,10 +284,23 @@ func HandleDNSScanRequests(context *cli.Context) error {
 			nameServers = append(nameServers, n.Host)
 		}
 	}
+
+	recordTypes, err := network.ParseDNSRecordTypes(context.String("record-types"))
+	if err != nil {
+		return err
+	}
+	collector := network.NewDNSRecordCollector(nil, noserver)
+	if ports := strings.TrimSpace(context.String("tlsa-ports")); len(ports) > 0 {
+		collector.TLSAPorts = strings.Split(strings.ReplaceAll(ports, " ", ""), ",")
+	}
+	records, cnameChain := collector.Collect(hostname, recordTypes)
+
 	return storage.GenerateOutputAndTeardown(context, structs.CombinedDNSRecord{
 		Hostname:     hostname,
 		DNSSECRecord: dnssec,
 		NSRecords:    nameServers,
 		Resolved:     resolved,
+		CNAMEChain:   cnameChain,
+		Records:      records,
 	})
 }
@@ -6,8 +6,6 @@ import (
 	"Scanner/pkg/scanner/structs"
 	"crypto/tls"
 	"net"
-	"net/smtp"
-	"time"
 )
 
 type CipherSuiteRequest struct {
@@ -88,54 +86,14 @@ func CipherSuiteWorker(cipherSuiteRequests <-chan CipherSuiteRequest,
 			MinVersion:         v,
 			MaxVersion:         v,
 		}
-		switch connectionType {
-		case "SMTP":
-			dialer := &net.Dialer{
-				Timeout: config.TLS_CIPHER_SUITE_SECOND_TIMEOUT * time.Second,
-			}
-			conn, err := dialer.Dial("tcp", net.JoinHostPort(ip.String(), port))
-			if err != nil {
-				cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: false}
-				continue
-			}
-			conn.SetDeadline(time.Now().Add(time.Second * config.TLS_CIPHER_SUITE_SECOND_TIMEOUT))
-			smtpConn, err := smtp.NewClient(conn, hostname)
-			if err != nil {
-				cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: false}
-				continue
-			}
-			smtpConn.Hello(config.SMTPHELO_Introduction)
-
-			connErr := smtpConn.StartTLS(cfg)
-			if connErr != nil {
-				cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: false}
-				continue
-			}
-
-			clientState, ok := smtpConn.TLSConnectionState()
-
-			// Append supported cipher suite for
-			if ok && clientState.CipherSuite == c {
-				cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: true}
-				continue
-			}
-			cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: false}
-
-			conn.Close()
-		case "TLS":
-			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: config.TLS_CIPHER_SUITE_SECOND_TIMEOUT * time.Second}, "tcp", net.JoinHostPort(ip.String(), port), cfg)
-			if err != nil {
-				cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: false}
-				continue
-			}
-			// Append supported cipher suite for
-			if conn.ConnectionState().CipherSuite == c {
-				cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: true}
-				continue
-			}
-			conn.Close()
+		conn, err := dialTLS(ip, hostname, port, connectionType, cfg)
+		if err != nil {
 			cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: false}
+			continue
 		}
-
+		// Append supported cipher suite for
+		successful := conn.ConnectionState().CipherSuite == c
+		conn.Close()
+		cipherSuiteResponses <- CipherSuiteResponse{TLSVersion: v, TLSCipherSuite: c, Successful: successful}
 	}
 }
@@ -0,0 +1,159 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	TLSAUsageDANETA = 2
+	TLSAUsageDANEEE = 3
+)
+
+// daneBogusErrors are the validation failures that make signed TLSA records bogus
+var daneBogusErrors = []error{
+	ErrInvalidRRsig,
+	ErrRrsigValidationError,
+	ErrRrsigValidityPeriod,
+	ErrDsInvalid,
+	ErrUnknownDsDigestType,
+	ErrDnskeyNotAvailable,
+	ErrTrustAnchorMismatch,
+	ErrBogusDenial,
+	ErrDelegationChain,
+}
+
+// CheckDANE validates the TLSA records of an MX with the DNSSEC authentication chain and matches
+// them against what port 25 served. tlsRecord is the port 25 scan, or nil when it was not scanned,
+// chains holds the presented chains keyed ip:port and is empty for MX data taken from the cache.
+func CheckDANE(mx string, tlsRecord *structs.TLSCombinedRecord, chains map[string][]*x509.Certificate, noserver bool) structs.DANERecord {
+	name := "_25._tcp." + dns.Fqdn(mx)
+	record := structs.DANERecord{MX: mx, Name: name, TLSARecords: make([]structs.TLSARecord, 0), IPMatches: make(map[string]bool)}
+
+	rq, err := NewResolver()
+	if err != nil {
+		record.Status = structs.DANEError
+		record.Error = err.Error()
+		return record
+	}
+	rrSet, _, err := rq.StrictNSQuery(name, dns.TypeTLSA, noserver)
+	if err != nil {
+		record.Status = DANELookupStatus(err)
+		if record.Status != structs.DANEAbsent {
+			record.Error = err.Error()
+		}
+		return record
+	}
+
+	tlsa := make([]*dns.TLSA, 0)
+	for _, rr := range rrSet {
+		if t, ok := rr.(*dns.TLSA); ok {
+			tlsa = append(tlsa, t)
+		}
+	}
+	return EvaluateDANE(record, tlsa, tlsRecord, chains)
+}
+
+// DANELookupStatus classifies a failed TLSA lookup. Only a proven absence is absent, other failures
+// are errors since senders defer delivery when the TLSA lookup fails (RFC 7672 section 2.2).
+func DANELookupStatus(err error) structs.DANEStatus {
+	if errors.Is(err, ErrResourceNotSigned) {
+		return structs.DANEInsecure
+	}
+	for _, bogus := range daneBogusErrors {
+		if errors.Is(err, bogus) {
+			return structs.DANEBogus
+		}
+	}
+	if errors.Is(err, ErrNoResult) {
+		return structs.DANEAbsent
+	}
+	return structs.DANEError
+}
+
+// EvaluateDANE matches validated TLSA records against every IP of the port 25 scan. Presented
+// chains are used when available, cached MX data falls back to the recorded SHA-256 fingerprints.
+func EvaluateDANE(record structs.DANERecord, tlsa []*dns.TLSA, tlsRecord *structs.TLSCombinedRecord, chains map[string][]*x509.Certificate) structs.DANERecord {
+	usable := make([]*dns.TLSA, 0)
+	for _, t := range tlsa {
+		entry := structs.TLSARecord{Usage: t.Usage, Selector: t.Selector, MatchingType: t.MatchingType, Data: strings.ToLower(t.Certificate)}
+		entry.Usable = t.Usage == TLSAUsageDANETA || t.Usage == TLSAUsageDANEEE
+		if entry.Usable {
+			usable = append(usable, t)
+		}
+		record.TLSARecords = append(record.TLSARecords, entry)
+	}
+
+	if tlsRecord == nil || len(tlsRecord.Certificates) == 0 {
+		record.Status = structs.DANEUnchecked
+		return record
+	}
+	record.Status = structs.DANEValid
+	if len(usable) == 0 {
+		record.Error = "no usable DANE-TA or DANE-EE TLSA records"
+	}
+	for ip, certificate := range tlsRecord.Certificates {
+		var matched bool
+		if chain, ok := lookupChain(chains, ip); ok {
+			matched = matchTLSAChain(usable, chain)
+		} else {
+			matched = matchTLSARecorded(usable, certificate, tlsRecord.CertificateChains[ip])
+		}
+		record.IPMatches[ip] = matched
+		if !matched {
+			record.Status = structs.DANEMismatched
+		}
+	}
+	return record
+}
+
+func lookupChain(chains map[string][]*x509.Certificate, ip string) ([]*x509.Certificate, bool) {
+	chain, ok := chains[net.JoinHostPort(ip, "25")]
+	return chain, ok && len(chain) > 0
+}
+
+// matchTLSAChain compares DANE-EE records with the leaf and DANE-TA records with the rest of the chain.
+func matchTLSAChain(usable []*dns.TLSA, chain []*x509.Certificate) bool {
+	for _, t := range usable {
+		candidates := chain[:1]
+		if t.Usage == TLSAUsageDANETA {
+			candidates = chain[1:]
+		}
+		for _, cert := range candidates {
+			data, err := dns.CertificateToDANE(t.Selector, t.MatchingType, cert)
+			if err == nil && strings.EqualFold(data, t.Certificate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchTLSARecorded matches SHA-256 records against the recorded leaf fingerprint, leaf SPKI hash and
+// chain fingerprints. Other record forms cannot be compared without the certificates.
+func matchTLSARecorded(usable []*dns.TLSA, certificate structs.CertificateRecord, chainFingerprints []string) bool {
+	for _, t := range usable {
+		if t.MatchingType != 1 {
+			continue
+		}
+		data := strings.ToLower(t.Certificate)
+		switch {
+		case t.Usage == TLSAUsageDANEEE && t.Selector == 0 && data == certificate.SHA256Fingerprint:
+			return true
+		case t.Usage == TLSAUsageDANEEE && t.Selector == 1 && data == certificate.SPKISHA256Hash:
+			return true
+		case t.Usage == TLSAUsageDANETA && t.Selector == 0 && len(chainFingerprints) > 1:
+			for _, fingerprint := range chainFingerprints[1:] {
+				if data == fingerprint {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
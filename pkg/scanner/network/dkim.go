@@ -0,0 +1,94 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultDKIMSelectors are selectors commonly used by mail platforms and signing software
+var DefaultDKIMSelectors = []string{
+	"default", "dkim", "mail", "email", "smtp", "mx", "key1", "key2", "k1", "k2", "k3", "s1", "s2", "sig1", "mta",
+	// Microsoft 365, Google Workspace, Amazon SES
+	"selector1", "selector2", "google", "amazonses",
+	// Mailchimp, Postmark, Fastmail, Proton, Zoho, SendGrid, Everlytic
+	"mandrill", "mte1", "pm", "pm-bounces", "fm1", "fm2", "fm3", "protonmail", "protonmail2", "zoho", "zmail",
+	"smtpapi", "s1024", "s2048", "everlytickey1", "everlytickey2", "mxvault", "dkim1024",
+}
+
+// LoadDKIMSelectors reads one selector per line, blank lines and lines starting with # are skipped.
+func LoadDKIMSelectors(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	selectors := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			selectors = append(selectors, line)
+		}
+	}
+	return selectors, scanner.Err()
+}
+
+// DKIMProber looks for DKIM keys under a list of selectors, selectors cannot be enumerated in DNS.
+type DKIMProber struct {
+	Resolver  TXTResolver
+	Selectors []string
+}
+
+// NewDKIMProber uses the default mail resolver and DefaultDKIMSelectors when nil.
+func NewDKIMProber(resolver TXTResolver, selectors []string) *DKIMProber {
+	if resolver == nil {
+		resolver = NewMailResolver("")
+	}
+	if selectors == nil {
+		selectors = DefaultDKIMSelectors
+	}
+	return &DKIMProber{Resolver: resolver, Selectors: selectors}
+}
+
+func (p *DKIMProber) Probe(domain string) structs.DKIMRecord {
+	domain = normalizeMailDomain(domain)
+	record := structs.DKIMRecord{Domain: domain, Keys: make(map[string]structs.DKIMKey), Errors: make([]string, 0)}
+	probed := make(map[string]bool)
+	for _, selector := range p.Selectors {
+		selector = strings.ToLower(selector)
+		if probed[selector] {
+			continue
+		}
+		probed[selector] = true
+		record.SelectorsProbed++
+
+		name := fmt.Sprintf("%s._domainkey.%s", selector, domain)
+		txtRecords, err := p.Resolver.LookupTXT(name)
+		if errors.Is(err, ErrNXDomain) {
+			continue
+		}
+		if err != nil {
+			record.Errors = append(record.Errors, err.Error())
+			continue
+		}
+		keyRecords := make([]string, 0)
+		for _, txt := range txtRecords {
+			if structs.IsDKIMKeyRecord(txt) {
+				keyRecords = append(keyRecords, txt)
+			}
+		}
+		if len(keyRecords) == 0 {
+			continue
+		}
+		key := structs.ParseDKIMKey(structs.DKIMKey{Selector: selector, Name: name}, keyRecords[0])
+		if len(keyRecords) > 1 {
+			key.Errors = append(key.Errors, fmt.Sprintf("%d key records, verifiers may use any of them", len(keyRecords)))
+		}
+		record.Keys[selector] = key
+	}
+	return record
+}
@@ -0,0 +1,161 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// MaxCNAMEChainLength bounds the aliases followed from a hostname, a loop stops at the first repeated name
+const MaxCNAMEChainLength = 16
+
+// DefaultDNSRecordTypes are collected by the dns scan unless --record-types is given
+var DefaultDNSRecordTypes = []uint16{
+	dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeNS, dns.TypeSOA, dns.TypeMX, dns.TypeTXT,
+	dns.TypeCAA, dns.TypeHTTPS, dns.TypeSVCB, dns.TypeDNSKEY, dns.TypeDS, dns.TypeTLSA,
+}
+
+// DefaultTLSAPorts are the ports TLSA records are looked up for, at _<port>._tcp.<hostname>
+var DefaultTLSAPorts = []string{"443", "25"}
+
+// DNSQueryResolver sends a recursive query with the DO bit set, NXDOMAIN returns the reply and ErrNXDomain
+type DNSQueryResolver interface {
+	QueryDNSSEC(name string, queryType uint16) (*dns.Msg, error)
+}
+
+// DNSRecordCollector looks up a set of record types of a hostname and validates each reply with DNSSEC
+type DNSRecordCollector struct {
+	Resolver  DNSQueryResolver
+	Validate  func(name string, queryType uint16, reply *dns.Msg) structs.DNSSECRecord
+	TLSAPorts []string
+}
+
+// NewDNSRecordCollector uses the default mail resolver when nil. Every reply is validated against the
+// authentication chain of its signer, populated once per zone for the collector.
+func NewDNSRecordCollector(resolver DNSQueryResolver, noserver bool) *DNSRecordCollector {
+	if resolver == nil {
+		resolver = NewMailResolver("")
+	}
+	return &DNSRecordCollector{
+		Resolver:  resolver,
+		Validate:  NewDNSSECReplyValidator(noserver).Validate,
+		TLSAPorts: DefaultTLSAPorts,
+	}
+}
+
+// ParseDNSRecordTypes parses a comma separated list of type mnemonics, an empty list is DefaultDNSRecordTypes
+func ParseDNSRecordTypes(list string) ([]uint16, error) {
+	if len(strings.TrimSpace(list)) == 0 {
+		return DefaultDNSRecordTypes, nil
+	}
+	types := make([]uint16, 0)
+	seen := make(map[uint16]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+		queryType, ok := dns.StringToType[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown DNS record type %q", name)
+		}
+		if !seen[queryType] {
+			seen[queryType] = true
+			types = append(types, queryType)
+		}
+	}
+	return types, nil
+}
+
+// Collect returns one record set per type, in the given order, and the CNAME chain when CNAME is
+// among the types. TLSA is looked up once per TLSAPorts entry.
+func (c *DNSRecordCollector) Collect(hostname string, types []uint16) ([]structs.DNSRecordSet, []structs.CNAMELink) {
+	hostname = dns.Fqdn(hostname)
+	recordSets := make([]structs.DNSRecordSet, 0, len(types))
+	chain := make([]structs.CNAMELink, 0)
+	for _, queryType := range types {
+		switch queryType {
+		case dns.TypeTLSA:
+			for _, port := range c.TLSAPorts {
+				if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+					continue
+				}
+				recordSets = append(recordSets, c.lookup(fmt.Sprintf("_%s._tcp.%s", port, hostname), queryType))
+			}
+		case dns.TypeCNAME:
+			recordSets = append(recordSets, c.lookup(hostname, queryType))
+			chain = c.cnameChain(hostname)
+		default:
+			recordSets = append(recordSets, c.lookup(hostname, queryType))
+		}
+	}
+	return recordSets, chain
+}
+
+func (c *DNSRecordCollector) lookup(name string, queryType uint16) structs.DNSRecordSet {
+	recordSet := structs.DNSRecordSet{Name: name, Type: dns.TypeToString[queryType], Records: make([]structs.DNSResourceRecord, 0)}
+	reply, err := c.Resolver.QueryDNSSEC(name, queryType)
+	if err != nil {
+		recordSet.Error = err.Error()
+		if errors.Is(err, ErrNXDomain) {
+			recordSet.Error = dns.RcodeToString[dns.RcodeNameError]
+		}
+	}
+	if reply != nil {
+		for _, rr := range reply.Answer {
+			// Aliases and signatures of the answer are not part of the set
+			if rr.Header().Rrtype != queryType {
+				continue
+			}
+			header := rr.Header()
+			recordSet.Records = append(recordSet.Records, structs.DNSResourceRecord{
+				Name: header.Name,
+				TTL:  header.Ttl,
+				Data: strings.TrimSpace(strings.TrimPrefix(rr.String(), header.String())),
+			})
+			if recordSet.TTL == 0 || header.Ttl < recordSet.TTL {
+				recordSet.TTL = header.Ttl
+			}
+		}
+	}
+	switch {
+	case reply == nil:
+		recordSet.DNSSEC.Reason = recordSet.Error
+	case c.Validate != nil:
+		recordSet.DNSSEC = c.Validate(name, queryType, reply)
+	}
+	return recordSet
+}
+
+// cnameChain follows CNAME records from name until a name without one
+func (c *DNSRecordCollector) cnameChain(name string) []structs.CNAMELink {
+	chain := make([]structs.CNAMELink, 0)
+	visited := map[string]bool{strings.ToLower(name): true}
+	for len(chain) < MaxCNAMEChainLength {
+		reply, err := c.Resolver.QueryDNSSEC(name, dns.TypeCNAME)
+		if err != nil || reply == nil {
+			return chain
+		}
+		var link *structs.CNAMELink
+		for _, rr := range reply.Answer {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				link = &structs.CNAMELink{Name: cname.Hdr.Name, Target: cname.Target, TTL: cname.Hdr.Ttl}
+				break
+			}
+		}
+		if link == nil {
+			return chain
+		}
+		chain = append(chain, *link)
+		if visited[strings.ToLower(link.Target)] {
+			return chain
+		}
+		visited[strings.ToLower(link.Target)] = true
+		name = link.Target
+	}
+	return chain
+}
@@ -2,12 +2,14 @@ package network
 
 import (
 	"Scanner/pkg/config"
+	"Scanner/pkg/scanner/structs"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/miekg/dns"
@@ -19,20 +21,9 @@ var (
 	ErrHTTPStatus  = errors.New("http status code is not 200")
 	ErrHTTPConnect = errors.New("unable to connect to http server")
 	ErrIPOptedOut  = errors.New("ip on opt out list")
+	ErrNXDomain    = errors.New("domain does not exist")
 )
 
-func exchange(hostname string, queryType uint16) (*dns.Msg, error) {
-	query := new(dns.Msg)
-	query.SetQuestion(hostname, queryType)
-
-	reply, err := dns.Exchange(query, config.DefaultResolver)
-	if err != nil {
-		return nil, err
-	}
-
-	return reply, nil
-}
-
 func ResolveIPAddresses(domainName string) ([]net.IP, error) {
 	asciiDomainName, err := idna.ToASCII(domainName)
 	if err != nil {
@@ -48,22 +39,103 @@ func ResolveIPAddresses(domainName string) ([]net.IP, error) {
 	return IPs, nil
 }
 
-func ResolveMXRecords(mailHost string) ([]string, map[string]uint16, error) {
+// MXResolution describes how mail for a domain is handled
+type MXResolution struct {
+	Servers      []string          // explicit MX hosts, or the domain itself for implicit MX
+	Priority     map[string]uint16 // mx : preference
+	MailHandling string            // explicit-mx, implicit-mx, null-mx or none
+	TTL          uint32            // MX RRset TTL, or the negative caching TTL when there is no MX
+}
+
+// ParseMXReply extracts the MX hosts of a reply ordered by preference, ties keep their wire order.
+// A single "0 ." record is a Null MX (RFC 7505), "." targets next to real MX hosts are skipped. ttl is the lowest MX TTL, or the SOA negative
+// caching TTL (RFC 2308) when the reply has no MX records.
+func ParseMXReply(reply *dns.Msg) (servers []string, priority map[string]uint16, nullMX bool, ttl uint32) {
+	servers = make([]string, 0)
+	priority = make(map[string]uint16)
+	records := make([]*dns.MX, 0)
+	for _, rr := range reply.Answer {
+		if mx, ok := rr.(*dns.MX); ok {
+			records = append(records, mx)
+		}
+	}
+	if len(records) == 1 && records[0].Mx == "." {
+		return servers, priority, true, records[0].Hdr.Ttl
+	}
+	for i, mx := range records {
+		if i == 0 || mx.Hdr.Ttl < ttl {
+			ttl = mx.Hdr.Ttl
+		}
+		if mx.Mx == "." {
+			continue
+		}
+		if _, ok := priority[mx.Mx]; !ok {
+			servers = append(servers, mx.Mx)
+		}
+		priority[mx.Mx] = mx.Preference
+	}
+	sort.SliceStable(servers, func(i, j int) bool { return priority[servers[i]] < priority[servers[j]] })
+	if len(records) == 0 {
+		for _, rr := range reply.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl = soa.Minttl
+				if soa.Hdr.Ttl < ttl {
+					ttl = soa.Hdr.Ttl
+				}
+			}
+		}
+	}
+	return servers, priority, false, ttl
+}
+
+// ResolveMailHandling looks up the MX records of a domain and falls back to the domain's own A/AAAA
+// records when it has none (implicit MX, RFC 5321 section 5.1).
+func ResolveMailHandling(mailHost string) (MXResolution, error) {
+	return NewMailResolver("").ResolveMailHandling(mailHost)
+}
+
+// ResolveMailHandling resolves the mail handling of a domain through r, see ResolveMailHandling.
+func (r *MailResolver) ResolveMailHandling(mailHost string) (MXResolution, error) {
+	resolution := MXResolution{Servers: make([]string, 0), Priority: make(map[string]uint16), MailHandling: structs.MailHandlingNone}
 	asciiMailHostName, err := idna.ToASCII(mailHost)
 	if err != nil {
-		return nil, nil, err
+		return resolution, err
 	}
-	mailServerList, err := net.LookupMX(asciiMailHostName)
+	domain := dns.Fqdn(asciiMailHostName)
+	reply, err := r.Query(domain, dns.TypeMX)
 	if err != nil {
-		return nil, nil, err
+		return resolution, err
 	}
-	mailServers := make([]string, 0)
-	mailServerPriority := make(map[string]uint16)
-	for _, mx := range mailServerList {
-		mailServers = append(mailServers, mx.Host)
-		mailServerPriority[mx.Host] = mx.Pref
+
+	servers, priority, nullMX, ttl := ParseMXReply(reply)
+	resolution.TTL = ttl
+	switch {
+	case nullMX:
+		resolution.MailHandling = structs.MailHandlingNullMX
+	case len(servers) > 0:
+		resolution.MailHandling = structs.MailHandlingExplicitMX
+		resolution.Servers = servers
+		resolution.Priority = priority
+	default:
+		for _, queryType := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			if addresses, err := r.LookupIP(domain, queryType); err == nil && len(addresses) > 0 {
+				resolution.MailHandling = structs.MailHandlingImplicitMX
+				resolution.Servers = []string{domain}
+				resolution.Priority[domain] = 0
+				break
+			}
+		}
+	}
+	return resolution, nil
+}
+
+// ResolveMXRecords returns the hosts that receive mail for the domain, including the implicit MX.
+func ResolveMXRecords(mailHost string) ([]string, map[string]uint16, error) {
+	resolution, err := ResolveMailHandling(mailHost)
+	if err != nil {
+		return nil, nil, err
 	}
-	return mailServers, mailServerPriority, err
+	return resolution.Servers, resolution.Priority, nil
 }
 
 func ResolveIPAddressesForHostnames(hostnames []string) map[string][]net.IP {
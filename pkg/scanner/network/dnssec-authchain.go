@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -132,7 +133,33 @@ func (authChain *AuthenticationChain) Verify(answerRRset *RRSet) error {
 			}
 		}
 	}
-	return nil
+
+	// The chain is only as trustworthy as the root key it ends in
+	return RootTrustAnchors.Verify(zones[len(zones)-1], time.Now())
+}
+
+// authChainCache populates the authentication chain of each signer zone once, a failed
+// Populate is kept along with the partial chain.
+type authChainCache struct {
+	noserver bool
+	chains   map[string]*AuthenticationChain // zone : chain
+	errors   map[string]error                // zone : Populate error
+}
+
+func newAuthChainCache(noserver bool) *authChainCache {
+	return &authChainCache{noserver: noserver, chains: make(map[string]*AuthenticationChain), errors: make(map[string]error)}
+}
+
+func (c *authChainCache) get(zone string) (*AuthenticationChain, error) {
+	zone = dns.Fqdn(strings.ToLower(zone))
+	if chain, ok := c.chains[zone]; ok {
+		return chain, c.errors[zone]
+	}
+	chain := NewAuthenticationChain()
+	err := chain.Populate(zone, c.noserver)
+	c.chains[zone] = chain
+	c.errors[zone] = err
+	return chain, err
 }
 
 // NewAuthenticationChain initializes an AuthenticationChain object and
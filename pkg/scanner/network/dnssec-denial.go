@@ -0,0 +1,384 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// MaxDenialDepth bounds the parent zones asked for a missing DS when an unsigned negative answer is confirmed
+const MaxDenialDepth = 16
+
+var ErrBogusDenial = errors.New("negative answer is not provably signed")
+
+// DenialError is returned by StrictNSQuery for names or types that do not exist. It unwraps to
+// ErrNoResult, or ErrBogusDenial when the denial could not be proven.
+type DenialError struct {
+	Denial structs.DenialRecord
+	Err    error
+}
+
+func (e *DenialError) Error() string { return e.Err.Error() }
+func (e *DenialError) Unwrap() error { return e.Err }
+
+func newDenialError(denial structs.DenialRecord) *DenialError {
+	if denial.Status == structs.DenialBogus {
+		return &DenialError{Denial: denial, Err: ErrBogusDenial}
+	}
+	return &DenialError{Denial: denial, Err: ErrNoResult}
+}
+
+// denialProof is one NSEC or NSEC3 record of the authority section with its signature
+type denialProof struct {
+	rr  dns.RR
+	sig *dns.RRSIG
+}
+
+// EvaluateDenial checks the NSEC or NSEC3 records of a negative answer to qname/qtype. verify validates
+// a signed proof record, its signer's authentication chain included. A negative answer without any proof
+// from an unsigned zone is reported as an insecure delegation, the caller confirms it through the parent's DS.
+func EvaluateDenial(qname string, qtype uint16, msg *dns.Msg, verify func(rrSet *RRSet) error) structs.DenialRecord {
+	qname = dns.Fqdn(strings.ToLower(qname))
+	denial := structs.DenialRecord{Type: structs.DenialNoData, Proof: structs.DenialNone}
+	if msg.Rcode == dns.RcodeNameError {
+		denial.Type = structs.DenialNXDomain
+	}
+
+	proofs := make([]denialProof, 0)
+	signatures := make([]*dns.RRSIG, 0)
+	var soa *dns.SOA
+	for _, rr := range msg.Ns {
+		switch record := rr.(type) {
+		case *dns.NSEC, *dns.NSEC3:
+			proofs = append(proofs, denialProof{rr: rr})
+		case *dns.RRSIG:
+			signatures = append(signatures, record)
+		case *dns.SOA:
+			soa = record
+		}
+	}
+	soaSigned := false
+	for _, sig := range signatures {
+		if soa != nil && sig.TypeCovered == dns.TypeSOA && strings.EqualFold(sig.Hdr.Name, soa.Hdr.Name) {
+			soaSigned = true
+		}
+		for i := range proofs {
+			header := proofs[i].rr.Header()
+			if sig.TypeCovered == header.Rrtype && strings.EqualFold(sig.Hdr.Name, header.Name) {
+				proofs[i].sig = sig
+			}
+		}
+	}
+
+	if len(proofs) == 0 {
+		if soa == nil {
+			return bogusDenial(denial, "no SOA, NSEC or NSEC3 record in the authority section")
+		}
+		denial.Zone = strings.ToLower(soa.Hdr.Name)
+		if soaSigned {
+			return bogusDenial(denial, fmt.Sprintf("signed zone %s returned no NSEC or NSEC3 proof", denial.Zone))
+		}
+		denial.Status = structs.DenialInsecureDelegation
+		denial.Reason = fmt.Sprintf("zone %s is unsigned", denial.Zone)
+		return denial
+	}
+
+	// Every proof must be signed by the same zone, which encloses the queried name
+	for _, proof := range proofs {
+		if proof.sig == nil {
+			return bogusDenial(denial, fmt.Sprintf("%s %s is not signed", dns.TypeToString[proof.rr.Header().Rrtype], proof.rr.Header().Name))
+		}
+		signer := strings.ToLower(proof.sig.SignerName)
+		if len(denial.Zone) == 0 {
+			denial.Zone = signer
+		}
+		if signer != denial.Zone || !dns.IsSubDomain(signer, qname) || !dns.IsSubDomain(signer, strings.ToLower(proof.rr.Header().Name)) {
+			return bogusDenial(denial, fmt.Sprintf("proof signed by %s does not belong to the zone of %s", signer, qname))
+		}
+		if err := verify(&RRSet{RrSet: []dns.RR{proof.rr}, RrSig: proof.sig}); err != nil {
+			return bogusDenial(denial, fmt.Sprintf("%s %s: %v", dns.TypeToString[proof.rr.Header().Rrtype], proof.rr.Header().Name, err))
+		}
+	}
+
+	nsecs := make([]*dns.NSEC, 0)
+	nsec3s := make([]*dns.NSEC3, 0)
+	for _, proof := range proofs {
+		switch record := proof.rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, record)
+		case *dns.NSEC3:
+			nsec3s = append(nsec3s, record)
+		}
+	}
+	if len(nsec3s) > 0 {
+		denial.Proof = structs.DenialNSEC3
+		return evaluateNSEC3(denial, qname, qtype, nsec3s)
+	}
+	denial.Proof = structs.DenialNSEC
+	return evaluateNSEC(denial, qname, qtype, nsecs)
+}
+
+func bogusDenial(denial structs.DenialRecord, reason string) structs.DenialRecord {
+	denial.Status = structs.DenialBogus
+	denial.Reason = reason
+	return denial
+}
+
+func secureDenial(denial structs.DenialRecord, reason string) structs.DenialRecord {
+	denial.Status = structs.DenialSecure
+	denial.Reason = reason
+	return denial
+}
+
+func hasType(bitmap []uint16, qtype uint16) bool {
+	for _, t := range bitmap {
+		if t == qtype {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateNSEC follows RFC 4035 section 5.4 and the wildcard rules of RFC 4035 section 3.1.3
+func evaluateNSEC(denial structs.DenialRecord, qname string, qtype uint16, nsecs []*dns.NSEC) structs.DenialRecord {
+	if denial.Type == structs.DenialNoData {
+		for _, nsec := range nsecs {
+			if !strings.EqualFold(nsec.Hdr.Name, qname) {
+				continue
+			}
+			if hasType(nsec.TypeBitMap, qtype) || hasType(nsec.TypeBitMap, dns.TypeCNAME) {
+				return bogusDenial(denial, fmt.Sprintf("NSEC at %s lists %s or CNAME", qname, dns.TypeToString[qtype]))
+			}
+			return secureDenial(denial, fmt.Sprintf("NSEC at %s does not list %s", qname, dns.TypeToString[qtype]))
+		}
+	}
+
+	covering := nsecCovering(nsecs, qname)
+	if covering == nil {
+		return bogusDenial(denial, fmt.Sprintf("no NSEC matches or covers %s", qname))
+	}
+	// An empty non-terminal has no NSEC of its own, the covering NSEC leads into its subtree
+	if denial.Type == structs.DenialNoData && dns.IsSubDomain(qname, strings.ToLower(covering.NextDomain)) {
+		return secureDenial(denial, fmt.Sprintf("%s is an empty non-terminal", qname))
+	}
+
+	denial.ClosestEncloser = commonAncestor(qname, covering.Hdr.Name)
+	if next := commonAncestor(qname, covering.NextDomain); dns.CountLabel(next) > dns.CountLabel(denial.ClosestEncloser) {
+		denial.ClosestEncloser = next
+	}
+	wildcard := "*." + strings.TrimPrefix(denial.ClosestEncloser, ".")
+	if denial.ClosestEncloser == "." {
+		wildcard = "*."
+	}
+	for _, nsec := range nsecs {
+		if !strings.EqualFold(nsec.Hdr.Name, wildcard) {
+			continue
+		}
+		if denial.Type == structs.DenialNoData && !hasType(nsec.TypeBitMap, qtype) && !hasType(nsec.TypeBitMap, dns.TypeCNAME) {
+			return secureDenial(denial, fmt.Sprintf("wildcard %s does not list %s", wildcard, dns.TypeToString[qtype]))
+		}
+		return bogusDenial(denial, fmt.Sprintf("wildcard %s exists and should have answered", wildcard))
+	}
+	if nsecCovering(nsecs, wildcard) == nil {
+		return bogusDenial(denial, fmt.Sprintf("no NSEC covers the wildcard %s", wildcard))
+	}
+	if denial.Type == structs.DenialNoData {
+		return bogusDenial(denial, fmt.Sprintf("NSEC proves %s does not exist but the answer is NODATA", qname))
+	}
+	return secureDenial(denial, fmt.Sprintf("NSEC covers %s and the wildcard %s", qname, wildcard))
+}
+
+// nsecCovering returns the NSEC whose span strictly contains name, the last NSEC of a zone wraps to the apex
+func nsecCovering(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, nsec := range nsecs {
+		afterOwner := canonicalCompare(nsec.Hdr.Name, name) < 0
+		beforeNext := canonicalCompare(name, nsec.NextDomain) < 0
+		wraps := canonicalCompare(nsec.NextDomain, nsec.Hdr.Name) <= 0
+		if (afterOwner && beforeNext) || (wraps && (afterOwner || beforeNext)) {
+			return nsec
+		}
+	}
+	return nil
+}
+
+// evaluateNSEC3 follows RFC 5155 section 8 with its closest encloser proof
+func evaluateNSEC3(denial structs.DenialRecord, qname string, qtype uint16, nsec3s []*dns.NSEC3) structs.DenialRecord {
+	parameters := nsec3s[0]
+	denial.NSEC3Algorithm = parameters.Hash
+	denial.NSEC3Iterations = parameters.Iterations
+	if parameters.Salt != "-" {
+		denial.NSEC3Salt = strings.ToLower(parameters.Salt)
+	}
+	for _, nsec3 := range nsec3s {
+		if nsec3.Hash != parameters.Hash || nsec3.Iterations != parameters.Iterations || !strings.EqualFold(nsec3.Salt, parameters.Salt) {
+			return bogusDenial(denial, "NSEC3 records use different hash parameters")
+		}
+	}
+	// Unknown hash algorithms make the zone insecure for this resolver (RFC 5155 section 8.1)
+	if parameters.Hash != dns.SHA1 {
+		denial.Status = structs.DenialInsecureDelegation
+		denial.Reason = fmt.Sprintf("unsupported NSEC3 hash algorithm %d", parameters.Hash)
+		return denial
+	}
+
+	if denial.Type == structs.DenialNoData {
+		if match := nsec3Matching(nsec3s, qname); match != nil {
+			if hasType(match.TypeBitMap, qtype) || hasType(match.TypeBitMap, dns.TypeCNAME) {
+				return bogusDenial(denial, fmt.Sprintf("NSEC3 of %s lists %s or CNAME", qname, dns.TypeToString[qtype]))
+			}
+			denial.ClosestEncloser = qname
+			return secureDenial(denial, fmt.Sprintf("NSEC3 of %s does not list %s", qname, dns.TypeToString[qtype]))
+		}
+	}
+
+	closestEncloser, nextCloser, covering := nsec3ClosestEncloser(nsec3s, qname)
+	if len(closestEncloser) == 0 {
+		return bogusDenial(denial, fmt.Sprintf("no closest encloser proof for %s", qname))
+	}
+	denial.ClosestEncloser = closestEncloser
+	denial.OptOut = covering.Flags&1 == 1
+	wildcard := "*." + strings.TrimPrefix(closestEncloser, ".")
+	if closestEncloser == "." {
+		wildcard = "*."
+	}
+
+	if denial.Type == structs.DenialNoData {
+		if match := nsec3Matching(nsec3s, wildcard); match != nil && !hasType(match.TypeBitMap, qtype) && !hasType(match.TypeBitMap, dns.TypeCNAME) {
+			return secureDenial(denial, fmt.Sprintf("wildcard %s does not list %s", wildcard, dns.TypeToString[qtype]))
+		}
+		// A DS query for an unsigned delegation inside an opt-out span (RFC 5155 section 8.6)
+		if qtype == dns.TypeDS && denial.OptOut {
+			denial.Status = structs.DenialInsecureDelegation
+			denial.Reason = fmt.Sprintf("%s falls in an NSEC3 opt-out span", nextCloser)
+			return denial
+		}
+		return bogusDenial(denial, fmt.Sprintf("no NSEC3 matches %s or its wildcard", qname))
+	}
+
+	if nsec3Matching(nsec3s, wildcard) != nil {
+		return bogusDenial(denial, fmt.Sprintf("wildcard %s exists and should have answered", wildcard))
+	}
+	if !nsec3Covers(nsec3s, wildcard) {
+		return bogusDenial(denial, fmt.Sprintf("no NSEC3 covers the wildcard %s", wildcard))
+	}
+	if denial.OptOut {
+		denial.Status = structs.DenialInsecureDelegation
+		denial.Reason = fmt.Sprintf("%s falls in an NSEC3 opt-out span, an unsigned delegation may exist", nextCloser)
+		return denial
+	}
+	return secureDenial(denial, fmt.Sprintf("closest encloser %s, NSEC3 covers %s and the wildcard %s", closestEncloser, nextCloser, wildcard))
+}
+
+func nsec3Matching(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Match(name) {
+			return nsec3
+		}
+	}
+	return nil
+}
+
+func nsec3Covers(nsec3s []*dns.NSEC3, name string) bool {
+	return nsec3Covering(nsec3s, name) != nil
+}
+
+func nsec3Covering(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Cover(name) {
+			return nsec3
+		}
+	}
+	return nil
+}
+
+// nsec3ClosestEncloser finds the longest ancestor of qname with a matching NSEC3 whose next closer
+// name is covered (RFC 5155 section 8.3). It returns empty names without such a proof.
+func nsec3ClosestEncloser(nsec3s []*dns.NSEC3, qname string) (string, string, *dns.NSEC3) {
+	labels := dns.SplitDomainName(qname)
+	for i := 1; i <= len(labels); i++ {
+		candidate := dns.Fqdn(strings.Join(labels[i:], "."))
+		if nsec3Matching(nsec3s, candidate) == nil {
+			continue
+		}
+		nextCloser := dns.Fqdn(strings.Join(labels[i-1:], "."))
+		if covering := nsec3Covering(nsec3s, nextCloser); covering != nil {
+			return candidate, nextCloser, covering
+		}
+		return "", "", nil
+	}
+	return "", "", nil
+}
+
+// canonicalCompare orders names as RFC 4034 section 6.1, label by label from the root
+func canonicalCompare(a string, b string) int {
+	aLabels := dns.SplitDomainName(strings.ToLower(a))
+	bLabels := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(aLabels) && i <= len(bLabels); i++ {
+		if comparison := strings.Compare(aLabels[len(aLabels)-i], bLabels[len(bLabels)-i]); comparison != 0 {
+			return comparison
+		}
+	}
+	return len(aLabels) - len(bLabels)
+}
+
+// commonAncestor returns the longest name both a and b are subdomains of
+func commonAncestor(a string, b string) string {
+	aLabels := dns.SplitDomainName(strings.ToLower(a))
+	bLabels := dns.SplitDomainName(strings.ToLower(b))
+	shared := 0
+	for shared < len(aLabels) && shared < len(bLabels) && aLabels[len(aLabels)-1-shared] == bLabels[len(bLabels)-1-shared] {
+		shared++
+	}
+	return dns.Fqdn(strings.Join(aLabels[len(aLabels)-shared:], "."))
+}
+
+// verifyDenial evaluates a negative answer, validating the proofs through the signer's authentication
+// chain and confirming unsigned answers by proving the DS of the zone absent at its parent.
+func verifyDenial(qname string, qtype uint16, msg *dns.Msg, chains *authChainCache, depth int) structs.DenialRecord {
+	denial := EvaluateDenial(qname, qtype, msg, func(rrSet *RRSet) error {
+		chain, err := chains.get(rrSet.SignerName())
+		if err != nil {
+			return err
+		}
+		return chain.Verify(rrSet)
+	})
+	if denial.Status != structs.DenialInsecureDelegation || denial.Proof != structs.DenialNone {
+		return denial
+	}
+
+	zone := denial.Zone
+	// A DS lives in the parent, a child answering for its own DS says nothing about the delegation
+	if qtype == dns.TypeDS && strings.EqualFold(zone, dns.Fqdn(qname)) {
+		zone = parentZone(zone)
+	}
+	if zone == "." {
+		return bogusDenial(denial, "the root zone is signed")
+	}
+	if depth >= MaxDenialDepth {
+		return bogusDenial(denial, fmt.Sprintf("unable to prove %s unsigned within %d parent zones", zone, MaxDenialDepth))
+	}
+	dsMsg, err := queryMsg(zone, dns.TypeDS, chains.noserver)
+	if err != nil {
+		return bogusDenial(denial, fmt.Sprintf("DS lookup of %s: %v", zone, err))
+	}
+	if dsMsg.Rcode == dns.RcodeSuccess && !rrSetFromMsg(dsMsg).IsEmpty() {
+		return bogusDenial(denial, fmt.Sprintf("%s is a signed delegation but returned an unsigned negative answer", zone))
+	}
+	parentDenial := verifyDenial(zone, dns.TypeDS, dsMsg, chains, depth+1)
+	if parentDenial.Status == structs.DenialBogus {
+		return bogusDenial(denial, fmt.Sprintf("the missing DS of %s is not proven: %s", zone, parentDenial.Reason))
+	}
+	denial.Reason = fmt.Sprintf("zone %s is unsigned, its parent has no DS for %s (%s)", denial.Zone, zone, parentDenial.Reason)
+	return denial
+}
+
+func parentZone(zone string) string {
+	labels := dns.SplitDomainName(zone)
+	if len(labels) <= 1 {
+		return "."
+	}
+	return dns.Fqdn(strings.Join(labels[1:], "."))
+}
@@ -13,11 +13,21 @@ func (resolver *Resolver) StrictNSQuery(qname string, qtype uint16, noserver boo
 		return nil, nil, ErrInvalidQuery
 	}
 
-	answer, err := queryRRset(qname, qtype, noserver)
+	reply, err := queryMsg(qname, qtype, noserver)
 	if err != nil {
 		return nil, nil, err
 	}
+	return verifyReply(qname, qtype, reply, newAuthChainCache(noserver))
+}
 
+// verifyReply validates the answer of reply, or the proof that it does not exist, through the
+// authentication chain of its signer taken from chains.
+func verifyReply(qname string, qtype uint16, reply *dns.Msg, chains *authChainCache) ([]dns.RR, *AuthenticationChain, error) {
+	answer := rrSetFromMsg(reply)
+	if reply.Rcode == dns.RcodeNameError || (reply.Rcode == dns.RcodeSuccess && answer.IsEmpty()) {
+		// The name or type does not exist, check that the denial is proven
+		return nil, nil, newDenialError(verifyDenial(qname, qtype, reply, chains, 0))
+	}
 	if answer.IsEmpty() {
 		return nil, nil, ErrNoResult
 	}
@@ -26,10 +36,7 @@ func (resolver *Resolver) StrictNSQuery(qname string, qtype uint16, noserver boo
 		return nil, nil, ErrResourceNotSigned
 	}
 
-	signerName := answer.SignerName()
-
-	authChain := NewAuthenticationChain()
-	err = authChain.Populate(signerName, noserver)
+	authChain, err := chains.get(answer.SignerName())
 
 	if err == ErrNoResult {
 		return nil, nil, err
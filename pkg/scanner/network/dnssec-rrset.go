@@ -7,29 +7,45 @@ import (
 )
 
 type RRSet struct {
-	RrSet []dns.RR   `json:"RrSet"`
-	RrSig *dns.RRSIG `json:"RrSig"`
+	RrSet  []dns.RR     `json:"RrSet"`
+	RrSig  *dns.RRSIG   `json:"RrSig"`
+	RrSigs []*dns.RRSIG `json:"-"` // every RRSIG of the answer, a rollover signs the root DNSKEY RRset twice
 }
 
 func queryRRset(qname string, qtype uint16, noserver bool) (*RRSet, error) {
+	r, err := queryMsg(qname, qtype, noserver)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Rcode == dns.RcodeNameError {
+		return nil, ErrNoResult
+	}
+	return rrSetFromMsg(r), nil
+}
+
+// queryMsg returns the whole reply, the authority section carries the proofs of negative answers
+func queryMsg(qname string, qtype uint16, noserver bool) (*dns.Msg, error) {
 	r, err := cachedExchange(qname, qtype, noserver)
 	if err != nil {
 		r, err = resolver.queryFn(qname, qtype)
 	}
+	if err == nil && r == nil {
+		err = ErrNsNotAvailable
+	}
 
 	if err != nil {
 		log.Printf("cannot lookup %v", err)
 		return nil, err
 	}
+	return r, nil
+}
 
-	if r.Rcode == dns.RcodeNameError {
-		return nil, ErrNoResult
-	}
-
+func rrSetFromMsg(r *dns.Msg) *RRSet {
 	result := NewSignedRRSet()
 
 	if r.Answer == nil {
-		return result, nil
+		return result
 	}
 
 	result.RrSet = make([]dns.RR, 0, len(r.Answer))
@@ -38,19 +54,31 @@ func queryRRset(qname string, qtype uint16, noserver bool) (*RRSet, error) {
 		switch t := rr.(type) {
 		case *dns.RRSIG:
 			result.RrSig = t
+			result.RrSigs = append(result.RrSigs, t)
 		default:
 			if rr != nil {
 				result.RrSet = append(result.RrSet, rr)
 			}
 		}
 	}
-	return result, nil
+	return result
 }
 
 func (sRRset *RRSet) IsSigned() bool {
 	return sRRset.RrSig != nil
 }
 
+// Signatures returns every RRSIG of the set, RrSig alone when the set was built without RrSigs
+func (sRRset *RRSet) Signatures() []*dns.RRSIG {
+	if len(sRRset.RrSigs) > 0 {
+		return sRRset.RrSigs
+	}
+	if sRRset.RrSig != nil {
+		return []*dns.RRSIG{sRRset.RrSig}
+	}
+	return nil
+}
+
 func (sRRset *RRSet) IsEmpty() bool {
 	return len(sRRset.RrSet) < 1
 }
@@ -30,27 +30,39 @@ func (z SignedZone) addPubKey(k *dns.DNSKEY) {
 // RRSET, and checks the validity period on the RRSIG.
 // It returns nil if the RRSIG verifies and the signature
 // is valid, and the appropriate error value in case
-// of validation failure.
+// of validation failure. An RRset signed by several keys
+// verifies when any one of its RRSIGs does.
 func (z SignedZone) verifyRRSIG(signedRRset *RRSet) (err error) {
 
 	if !signedRRset.IsSigned() {
 		return ErrRRSigNotAvailable
 	}
 
-	// Verify the RRSIG of the DNSKEY RRset
-	key := z.lookupPubKey(signedRRset.RrSig.KeyTag)
+	for i, signature := range signedRRset.Signatures() {
+		signatureErr := z.verifySignature(signature, signedRRset.RrSet)
+		if signatureErr == nil {
+			return nil
+		}
+		if i == 0 {
+			err = signatureErr
+		}
+	}
+	return err
+}
+
+func (z SignedZone) verifySignature(signature *dns.RRSIG, rrSet []dns.RR) error {
+	key := z.lookupPubKey(signature.KeyTag)
 	if key == nil {
-		//log.Printf("DNSKEY keytag %d not found", signedRRset.RrSig.KeyTag)
+		//log.Printf("DNSKEY keytag %d not found", signature.KeyTag)
 		return ErrDnskeyNotAvailable
 	}
 
-	err = signedRRset.RrSig.Verify(key, signedRRset.RrSet)
-	if err != nil {
+	if err := signature.Verify(key, rrSet); err != nil {
 		//log.Println("DNSKEY verification", err)
 		return err
 	}
 
-	if !signedRRset.RrSig.ValidityPeriod(time.Now()) {
+	if !signature.ValidityPeriod(time.Now()) {
 		//log.Println("invalid validity period", err)
 		return ErrRrsigValidityPeriod
 	}
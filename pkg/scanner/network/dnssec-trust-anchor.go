@@ -0,0 +1,180 @@
+package network
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var ErrTrustAnchorMismatch = errors.New("root DNSKEY does not match a configured trust anchor")
+
+// TrustAnchor is a root key the authentication chain must end in, given as a DS digest
+// (root-anchors.xml or a DS record) or as the DNSKEY itself.
+type TrustAnchor struct {
+	Zone       string
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     string
+	DNSKEY     *dns.DNSKEY // set for DNSKEY anchors, the digest fields are then unused
+	ValidFrom  time.Time   // zero when unbounded
+	ValidUntil time.Time
+}
+
+type TrustAnchors []TrustAnchor
+
+// RootTrustAnchors are enforced by AuthenticationChain.Verify, --trust-anchor replaces them
+var RootTrustAnchors = DefaultRootTrustAnchors()
+
+// DefaultRootTrustAnchors are the root KSKs published by IANA, KSK-2017 and KSK-2024
+func DefaultRootTrustAnchors() TrustAnchors {
+	return TrustAnchors{
+		{Zone: ".", KeyTag: 20326, Algorithm: dns.RSASHA256, DigestType: dns.SHA256, Digest: "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"},
+		{Zone: ".", KeyTag: 38696, Algorithm: dns.RSASHA256, DigestType: dns.SHA256, Digest: "683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16"},
+	}
+}
+
+// LoadTrustAnchors reads an IANA root-anchors.xml file or a file of DS and DNSKEY records in zone file format
+func LoadTrustAnchors(path string) (TrustAnchors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var anchors TrustAnchors
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		anchors, err = ParseRootAnchorsXML(data)
+	} else {
+		anchors, err = ParseTrustAnchorRecords(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("%s: no trust anchors", path)
+	}
+	return anchors, nil
+}
+
+type rootAnchorsXML struct {
+	Zone       string `xml:"Zone"`
+	KeyDigests []struct {
+		ValidFrom  string `xml:"validFrom,attr"`
+		ValidUntil string `xml:"validUntil,attr"`
+		KeyTag     uint16 `xml:"KeyTag"`
+		Algorithm  uint8  `xml:"Algorithm"`
+		DigestType uint8  `xml:"DigestType"`
+		Digest     string `xml:"Digest"`
+	} `xml:"KeyDigest"`
+}
+
+// ParseRootAnchorsXML parses the TrustAnchor document of https://data.iana.org/root-anchors/root-anchors.xml (RFC 9718)
+func ParseRootAnchorsXML(data []byte) (TrustAnchors, error) {
+	var document rootAnchorsXML
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	zone := dns.Fqdn(strings.TrimSpace(document.Zone))
+	anchors := make(TrustAnchors, 0, len(document.KeyDigests))
+	for _, digest := range document.KeyDigests {
+		anchor := TrustAnchor{
+			Zone:       zone,
+			KeyTag:     digest.KeyTag,
+			Algorithm:  digest.Algorithm,
+			DigestType: digest.DigestType,
+			Digest:     strings.ToUpper(strings.TrimSpace(digest.Digest)),
+		}
+		var err error
+		if anchor.ValidFrom, err = parseAnchorTime(digest.ValidFrom); err != nil {
+			return nil, err
+		}
+		if anchor.ValidUntil, err = parseAnchorTime(digest.ValidUntil); err != nil {
+			return nil, err
+		}
+		anchors = append(anchors, anchor)
+	}
+	return anchors, nil
+}
+
+func parseAnchorTime(value string) (time.Time, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(value))
+}
+
+// ParseTrustAnchorRecords parses DS and DNSKEY records in zone file format, other record types are rejected
+func ParseTrustAnchorRecords(data []byte) (TrustAnchors, error) {
+	anchors := make(TrustAnchors, 0)
+	parser := dns.NewZoneParser(bytes.NewReader(data), ".", "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		switch record := rr.(type) {
+		case *dns.DS:
+			anchors = append(anchors, TrustAnchor{
+				Zone:       dns.Fqdn(record.Hdr.Name),
+				KeyTag:     record.KeyTag,
+				Algorithm:  record.Algorithm,
+				DigestType: record.DigestType,
+				Digest:     strings.ToUpper(record.Digest),
+			})
+		case *dns.DNSKEY:
+			anchors = append(anchors, TrustAnchor{
+				Zone:      dns.Fqdn(record.Hdr.Name),
+				KeyTag:    record.KeyTag(),
+				Algorithm: record.Algorithm,
+				DNSKEY:    record,
+			})
+		default:
+			return nil, fmt.Errorf("%s records are not trust anchors", dns.TypeToString[rr.Header().Rrtype])
+		}
+	}
+	if err := parser.Err(); err != nil {
+		return nil, err
+	}
+	return anchors, nil
+}
+
+// Matches reports whether key is the anchored key and the anchor is valid at now
+func (anchor TrustAnchor) Matches(key *dns.DNSKEY, now time.Time) bool {
+	if key == nil || !strings.EqualFold(anchor.Zone, key.Hdr.Name) {
+		return false
+	}
+	if (!anchor.ValidFrom.IsZero() && now.Before(anchor.ValidFrom)) || (!anchor.ValidUntil.IsZero() && !now.Before(anchor.ValidUntil)) {
+		return false
+	}
+	if anchor.DNSKEY != nil {
+		return anchor.DNSKEY.Flags == key.Flags && anchor.DNSKEY.Protocol == key.Protocol &&
+			anchor.DNSKEY.Algorithm == key.Algorithm && anchor.DNSKEY.PublicKey == key.PublicKey
+	}
+	if anchor.KeyTag != key.KeyTag() || anchor.Algorithm != key.Algorithm {
+		return false
+	}
+	ds := key.ToDS(anchor.DigestType)
+	return ds != nil && strings.EqualFold(ds.Digest, anchor.Digest)
+}
+
+// Verify checks that the DNSKEY RRset of the root zone carries a valid signature by an anchored key.
+// Every RRSIG is tried and any one of several anchors is enough, which keeps validation working through
+// a KSK rollover when the RRset is signed by both the old and the new key.
+func (anchors TrustAnchors) Verify(root SignedZone, now time.Time) error {
+	if root.Zone != "." || root.Dnskey == nil || !root.Dnskey.IsSigned() {
+		return ErrTrustAnchorMismatch
+	}
+	for _, signature := range root.Dnskey.Signatures() {
+		key := root.lookupPubKey(signature.KeyTag)
+		if key == nil || signature.Verify(key, root.Dnskey.RrSet) != nil || !signature.ValidityPeriod(now) {
+			continue
+		}
+		for _, anchor := range anchors {
+			if anchor.Matches(key, now) {
+				return nil
+			}
+		}
+	}
+	return ErrTrustAnchorMismatch
+}
@@ -2,7 +2,10 @@ package network
 
 import (
 	"Scanner/pkg/scanner/structs"
+	"errors"
 	"log"
+
+	"github.com/miekg/dns"
 )
 
 type DNSSEC struct {
@@ -12,17 +15,37 @@ type DNSSEC struct {
 }
 
 func singleMeasure(query DNSSEC) structs.DNSSECRecord {
-	r := structs.DNSSECRecord{}
 	rq, err := NewResolver()
 	if err != nil {
 		log.Fatalf("[ERROR] %v", err)
-		r.Reason = err.Error()
-		return r
+		return structs.DNSSECRecord{Reason: err.Error()}
 	}
 	_, chain, err := rq.StrictNSQuery(query.Hostname, query.QueryType, query.NoServer)
+	return dnssecRecord(chain, err)
+}
+
+// dnssecRecord reports the outcome of a validation, err is the error of StrictNSQuery or verifyReply
+func dnssecRecord(chain *AuthenticationChain, err error) structs.DNSSECRecord {
+	r := structs.DNSSECRecord{}
 	if chain != nil {
 		r.SignedZones = chain.ExportAuthChain()
 	}
+	var denialErr *DenialError
+	if errors.As(err, &denialErr) {
+		denial := denialErr.Denial
+		r.Denial = &denial
+		r.Reason = err.Error()
+		switch denial.Status {
+		case structs.DenialSecure:
+			// The absence is signed and proven
+			r.DNSSECExists = true
+			r.DNSSECValid = true
+		case structs.DenialBogus:
+			r.DNSSECExists = true
+			r.DNSSECValid = false
+		}
+		return r
+	}
 	if err != nil {
 		if err == ErrInvalidQuery {
 			r.Reason = err.Error()
@@ -42,6 +65,7 @@ func singleMeasure(query DNSSEC) structs.DNSSECRecord {
 			err == ErrDsInvalid || // Delegation is invalid
 			err == ErrUnknownDsDigestType || // DigestType is unknown for DS
 			err == ErrDnskeyNotAvailable || // DNSKEY was hinted but not available
+			err == ErrTrustAnchorMismatch || // The chain does not end in a configured root key
 			err == ErrDelegationChain { // Verify was called but with an empty delegation chain. Should not have happened.
 			r.Reason = err.Error()
 			r.DNSSECExists = true
@@ -63,3 +87,22 @@ func singleMeasure(query DNSSEC) structs.DNSSECRecord {
 func (d DNSSEC) Query() structs.DNSSECRecord {
 	return singleMeasure(d)
 }
+
+// DNSSECReplyValidator validates replies that were already fetched with the DO bit set. The authentication
+// chain of each signer zone is populated once and shared by every reply of the validator.
+type DNSSECReplyValidator struct {
+	chains *authChainCache
+}
+
+func NewDNSSECReplyValidator(noserver bool) *DNSSECReplyValidator {
+	if _, err := NewResolver(); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	return &DNSSECReplyValidator{chains: newAuthChainCache(noserver)}
+}
+
+// Validate checks the answer to name/queryType in reply, or the proof of its absence
+func (v *DNSSECReplyValidator) Validate(name string, queryType uint16, reply *dns.Msg) structs.DNSSECRecord {
+	_, chain, err := verifyReply(dns.Fqdn(name), queryType, reply, v.chains)
+	return dnssecRecord(chain, err)
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// ImplicitTLSPorts start TLS immediately after connecting (RFC 8314), every other SMTP port is expected to use STARTTLS.
+var ImplicitTLSPorts = map[int]bool{
+	465: true,
+}
+
+// ConnectionTypeForMode maps a connection mode to the TLSRequest type that scans it.
+func ConnectionTypeForMode(mode string) string {
+	if mode == structs.ModeImplicitTLS {
+		return "TLS"
+	}
+	return "SMTP"
+}
+
+// PreferredModes orders the modes to try on a port. Trying STARTTLS against an implicit TLS port
+// only fails after the banner read times out, so the expected mode is always tried first.
+func PreferredModes(port string) []string {
+	portNumber, _ := strconv.Atoi(port)
+	if ImplicitTLSPorts[portNumber] {
+		return []string{structs.ModeImplicitTLS, structs.ModeSTARTTLS}
+	}
+	return []string{structs.ModeSTARTTLS, structs.ModeImplicitTLS}
+}
+
+// DetectSMTPConnectionMode completes a TLS handshake in each mode and returns the first mode that
+// succeeds, or ModeUnknown if no IP of the host accepted either mode.
+func DetectSMTPConnectionMode(ips []net.IP, hostname string, port string) string {
+	cfg := &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS13,
+	}
+	for _, ip := range ips {
+		for _, mode := range PreferredModes(port) {
+			conn, err := dialTLS(ip, hostname, port, ConnectionTypeForMode(mode), cfg)
+			if err != nil {
+				continue
+			}
+			conn.Close()
+			return mode
+		}
+	}
+	return structs.ModeUnknown
+}
+
+// DetectMailEndpoints scans the SMTP ports of every IP of an MX and detects the connection mode of
+// each open port, returning one record per IP and port sorted by IP and port.
+func DetectMailEndpoints(hostname string, ips []net.IP) []structs.MailEndpointRecord {
+	openPorts := PerformPerIPPortScan(ips)
+	endpoints := make([]structs.MailEndpointRecord, 0, len(ips)*len(SMTPPorts))
+	for _, ip := range ips {
+		open := make(map[int]bool)
+		for _, port := range openPorts[ip.String()] {
+			open[port] = true
+		}
+		for _, port := range SMTPPorts {
+			endpoints = append(endpoints, structs.MailEndpointRecord{MX: hostname, IP: ip.String(), Port: port, Open: open[port]})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range endpoints {
+		if !endpoints[i].Open {
+			continue
+		}
+		wg.Add(1)
+		go func(endpoint *structs.MailEndpointRecord) {
+			defer wg.Done()
+			ip := net.ParseIP(endpoint.IP)
+			endpoint.Mode = DetectSMTPConnectionMode([]net.IP{ip}, hostname, strconv.Itoa(endpoint.Port))
+		}(&endpoints[i])
+	}
+	wg.Wait()
+
+	structs.SortMailEndpoints(endpoints)
+	return endpoints
+}
@@ -0,0 +1,140 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// MailPolicyChecker looks up the anti-spoofing and reporting policies a domain publishes in DNS:
+// DMARC (RFC 7489), SMTP TLS reporting (RFC 8460) and BIMI.
+type MailPolicyChecker struct {
+	Resolver TXTResolver
+}
+
+// NewMailPolicyChecker uses the default mail resolver when nil, tests may inject one.
+func NewMailPolicyChecker(resolver TXTResolver) *MailPolicyChecker {
+	if resolver == nil {
+		resolver = NewMailResolver("")
+	}
+	return &MailPolicyChecker{Resolver: resolver}
+}
+
+// lookupPolicyTXT returns the single TXT record at name accepted by isRecord. Receivers ignore
+// every record of the kind when more than one is published.
+func (c *MailPolicyChecker) lookupPolicyTXT(name string, kind string, isRecord func(string) bool) (string, bool, error) {
+	txtRecords, err := c.Resolver.LookupTXT(name)
+	if errors.Is(err, ErrNXDomain) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	policyRecords := make([]string, 0)
+	for _, txt := range txtRecords {
+		if isRecord(txt) {
+			policyRecords = append(policyRecords, txt)
+		}
+	}
+	switch len(policyRecords) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return policyRecords[0], true, nil
+	default:
+		return "", false, fmt.Errorf("multiple %s records at %s, receivers ignore all of them", kind, name)
+	}
+}
+
+func organizationalDomain(domain string) string {
+	organizational, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+	return organizational
+}
+
+// DMARC looks up _dmarc.<domain>, falling back to the organizational domain, and checks that
+// report destinations outside the organization authorized the domain.
+func (c *MailPolicyChecker) DMARC(domain string) structs.DMARCRecord {
+	domain = normalizeMailDomain(domain)
+	record := structs.DMARCRecord{Domain: domain, OrganizationalDomain: organizationalDomain(domain), Errors: make([]string, 0)}
+	candidates := []string{domain}
+	if record.OrganizationalDomain != domain {
+		candidates = append(candidates, record.OrganizationalDomain)
+	}
+	for _, candidate := range candidates {
+		txt, found, err := c.lookupPolicyTXT("_dmarc."+candidate, "DMARC", structs.IsDMARCRecord)
+		if err != nil {
+			record.Errors = append(record.Errors, err.Error())
+			return record
+		}
+		if found {
+			record.Found = true
+			record.RecordDomain = candidate
+			record = structs.ParseDMARC(record, txt)
+			break
+		}
+	}
+	if !record.Found {
+		return record
+	}
+
+	for i := range record.AggregateReports {
+		c.authorizeReportURI(&record.AggregateReports[i], record.RecordDomain, record.OrganizationalDomain)
+	}
+	for i := range record.FailureReports {
+		c.authorizeReportURI(&record.FailureReports[i], record.RecordDomain, record.OrganizationalDomain)
+	}
+	return record
+}
+
+// authorizeReportURI checks external destination verification (RFC 7489 section 7.1)
+func (c *MailPolicyChecker) authorizeReportURI(uri *structs.DMARCReportURI, domain string, organizational string) {
+	if organizationalDomain(uri.Domain) == organizational {
+		uri.Authorized = true
+		return
+	}
+	uri.External = true
+	uri.AuthorizationRecord = fmt.Sprintf("%s._report._dmarc.%s", domain, uri.Domain)
+	txtRecords, err := c.Resolver.LookupTXT(uri.AuthorizationRecord)
+	if err != nil {
+		return
+	}
+	for _, txt := range txtRecords {
+		if strings.HasPrefix(txt, "v=DMARC1") {
+			uri.Authorized = true
+		}
+	}
+}
+
+func (c *MailPolicyChecker) TLSRPT(domain string) structs.TLSRPTRecord {
+	domain = normalizeMailDomain(domain)
+	record := structs.TLSRPTRecord{Domain: domain, RUA: make([]string, 0), Errors: make([]string, 0)}
+	txt, found, err := c.lookupPolicyTXT("_smtp._tls."+domain, "TLS-RPT", structs.IsTLSRPTRecord)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+	}
+	if !found {
+		return record
+	}
+	record.Found = true
+	return structs.ParseTLSRPT(record, txt)
+}
+
+func (c *MailPolicyChecker) BIMI(domain string) structs.BIMIRecord {
+	domain = normalizeMailDomain(domain)
+	record := structs.BIMIRecord{Domain: domain, Errors: make([]string, 0)}
+	txt, found, err := c.lookupPolicyTXT("default._bimi."+domain, "BIMI", structs.IsBIMIRecord)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+	}
+	if !found {
+		return record
+	}
+	record.Found = true
+	return structs.ParseBIMI(record, txt)
+}
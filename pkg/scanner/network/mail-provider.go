@@ -0,0 +1,175 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DEFAULT_PROVIDER_RULES_PATH = "dataset/mail_providers.json"
+
+// DefaultProviderRulesPath resolves DEFAULT_PROVIDER_RULES_PATH against the working directory, then
+// against the directory of the executable, so an installed scanner finds the dataset shipped beside it.
+// The relative path is returned when neither exists.
+func DefaultProviderRulesPath() string {
+	if _, err := os.Stat(DEFAULT_PROVIDER_RULES_PATH); err == nil {
+		return DEFAULT_PROVIDER_RULES_PATH
+	}
+	if executable, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		path := filepath.Join(filepath.Dir(executable), DEFAULT_PROVIDER_RULES_PATH)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return DEFAULT_PROVIDER_RULES_PATH
+}
+
+// providerAnchorSources can attribute mail on their own. Banners and issuers are shared with on-premises
+// products and public CAs, so they only corroborate a provider matched by MX or certificate name.
+var providerAnchorSources = map[string]bool{
+	structs.ProviderEvidenceMX:              true,
+	structs.ProviderEvidenceCertificateName: true,
+}
+
+// providerEvidenceWeights favour MX names, a filtering gateway in front of a mailbox provider owns the MX
+var providerEvidenceWeights = map[string]int{
+	structs.ProviderEvidenceMX:                3,
+	structs.ProviderEvidenceBanner:            2,
+	structs.ProviderEvidenceCertificateName:   2,
+	structs.ProviderEvidenceCertificateIssuer: 1,
+}
+
+type MailProviderRule struct {
+	Name             string   `json:"name"`
+	MX               []string `json:"mx"`               // domain suffixes of MX hosts
+	Banners          []string `json:"banners"`          // case-insensitive substrings of SMTP banners
+	CertificateNames []string `json:"certificateNames"` // domain suffixes of certificate CNs and SANs
+	Issuers          []string `json:"issuers"`          // case-insensitive substrings of certificate issuers
+}
+
+type MailProviderRules struct {
+	Providers []MailProviderRule `json:"providers"`
+}
+
+func LoadMailProviderRules(path string) (*MailProviderRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := &MailProviderRules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// matchDomainSuffix matches a name, or a wildcard certificate name, against a domain suffix on label boundaries
+func matchDomainSuffix(name string, suffix string) bool {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(name, ".")), "*.")
+	suffix = strings.ToLower(strings.TrimSuffix(suffix, "."))
+	return name == suffix || strings.HasSuffix(name, "."+suffix)
+}
+
+// Classify attributes the mail of domain to a provider from the MX hosts, the banners in metadata and
+// the port certificates in mxTLS. metadata and mxTLS are keyed host:port. A provider is only listed
+// when its MX or certificate names match, banner and issuer evidence alone is recorded but not attributed.
+func (r *MailProviderRules) Classify(domain string, mxHosts []string, metadata map[string]structs.SMTPMetadata, mxTLS map[string]structs.TLSCombinedRecord) structs.MailProviderRecord {
+	record := structs.MailProviderRecord{Providers: make([]string, 0), Scores: make(map[string]int), Evidence: make([]structs.ProviderEvidence, 0)}
+	seen := make(map[string]bool)
+	anchored := make(map[string]bool)
+	addEvidence := func(evidence structs.ProviderEvidence) {
+		key := fmt.Sprintf("%s|%s|%s|%s", evidence.Provider, evidence.Source, evidence.Subject, evidence.Value)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		record.Evidence = append(record.Evidence, evidence)
+		record.Scores[evidence.Provider] += providerEvidenceWeights[evidence.Source]
+		if providerAnchorSources[evidence.Source] {
+			anchored[evidence.Provider] = true
+		}
+	}
+
+	for _, rule := range r.Providers {
+		for _, mx := range mxHosts {
+			for _, suffix := range rule.MX {
+				if matchDomainSuffix(mx, suffix) {
+					addEvidence(structs.ProviderEvidence{Provider: rule.Name, Source: structs.ProviderEvidenceMX, Subject: mx, Value: mx, Rule: suffix})
+				}
+			}
+		}
+		for hostPort, smtpMetadata := range metadata {
+			banner := strings.ToLower(smtpMetadata.Banner)
+			for _, pattern := range rule.Banners {
+				if len(pattern) > 0 && strings.Contains(banner, strings.ToLower(pattern)) {
+					addEvidence(structs.ProviderEvidence{Provider: rule.Name, Source: structs.ProviderEvidenceBanner, Subject: hostPort, Value: smtpMetadata.Banner, Rule: pattern})
+				}
+			}
+		}
+		for hostPort, tlsRecord := range mxTLS {
+			for _, certificate := range tlsRecord.Certificates {
+				names := append([]string{certificate.CommonName}, certificate.AlternateNames...)
+				for _, suffix := range rule.CertificateNames {
+					for _, name := range names {
+						if len(name) > 0 && matchDomainSuffix(name, suffix) {
+							addEvidence(structs.ProviderEvidence{Provider: rule.Name, Source: structs.ProviderEvidenceCertificateName, Subject: hostPort, Value: name, Rule: suffix})
+						}
+					}
+				}
+				issuer := strings.ToLower(certificate.Issuer)
+				for _, pattern := range rule.Issuers {
+					if len(pattern) > 0 && strings.Contains(issuer, strings.ToLower(pattern)) {
+						addEvidence(structs.ProviderEvidence{Provider: rule.Name, Source: structs.ProviderEvidenceCertificateIssuer, Subject: hostPort, Value: certificate.Issuer, Rule: pattern})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(record.Evidence, func(i, j int) bool {
+		a, b := record.Evidence[i], record.Evidence[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Value < b.Value
+	})
+	for provider := range anchored {
+		record.Providers = append(record.Providers, provider)
+	}
+	sort.Slice(record.Providers, func(i, j int) bool {
+		a, b := record.Providers[i], record.Providers[j]
+		if record.Scores[a] != record.Scores[b] {
+			return record.Scores[a] > record.Scores[b]
+		}
+		return a < b
+	})
+
+	switch {
+	case len(record.Providers) > 0:
+		record.Provider = record.Providers[0]
+	case len(mxHosts) == 0:
+		record.Provider = structs.ProviderUnknown
+	default:
+		record.Provider = structs.ProviderSelfHosted
+		organization := organizationalDomain(normalizeMailDomain(domain))
+		for _, mx := range mxHosts {
+			if organizationalDomain(normalizeMailDomain(mx)) != organization {
+				record.Provider = structs.ProviderUnknown
+			}
+		}
+	}
+	return record
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"Scanner/pkg/config"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TXTResolver looks up the TXT records of a name. NODATA returns no records and no error,
+// a name that does not exist returns ErrNXDomain.
+type TXTResolver interface {
+	LookupTXT(name string) ([]string, error)
+}
+
+// MailResolver queries a single recursive resolver for the records of the mail policy checks.
+// Tests point Server at an in-process DNS server.
+type MailResolver struct {
+	Server string
+	Client *dns.Client
+}
+
+func NewMailResolver(server string) *MailResolver {
+	if len(server) == 0 {
+		server = config.DefaultResolver
+	}
+	return &MailResolver{Server: server, Client: &dns.Client{Timeout: HOSTNAME_SECOND_TIMEOUT * time.Second}}
+}
+
+// Query sends a recursive query, retrying over TCP when the UDP answer is truncated.
+func (r *MailResolver) Query(name string, queryType uint16) (*dns.Msg, error) {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), queryType)
+	query.SetEdns0(4096, false)
+	return r.exchange(query)
+}
+
+// QueryDNSSEC is Query with the DO bit set so the reply carries its RRSIGs and NSEC/NSEC3 proofs, and
+// CD set so a validating resolver hands over bogus answers for the caller to validate.
+func (r *MailResolver) QueryDNSSEC(name string, queryType uint16) (*dns.Msg, error) {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), queryType)
+	query.SetEdns0(4096, true)
+	query.CheckingDisabled = true
+	return r.exchange(query)
+}
+
+func (r *MailResolver) exchange(query *dns.Msg) (*dns.Msg, error) {
+	reply, _, err := r.Client.Exchange(query, r.Server)
+	if err == nil && reply.Truncated {
+		tcpClient := *r.Client
+		tcpClient.Net = "tcp"
+		reply, _, err = tcpClient.Exchange(query, r.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch reply.Rcode {
+	case dns.RcodeSuccess:
+		return reply, nil
+	case dns.RcodeNameError:
+		return reply, ErrNXDomain
+	default:
+		question := query.Question[0]
+		return reply, fmt.Errorf("%s %s lookup failed: %s", strings.TrimSuffix(question.Name, "."), dns.TypeToString[question.Qtype], dns.RcodeToString[reply.Rcode])
+	}
+}
+
+func (r *MailResolver) LookupTXT(name string) ([]string, error) {
+	reply, err := r.Query(name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]string, 0)
+	for _, rr := range reply.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			// A TXT record is split into 255 byte strings, which are concatenated without spaces
+			records = append(records, strings.Join(txt.Txt, ""))
+		}
+	}
+	return records, nil
+}
+
+// LookupIP returns the addresses of an A or AAAA query
+func (r *MailResolver) LookupIP(name string, queryType uint16) ([]net.IP, error) {
+	reply, err := r.Query(name, queryType)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0)
+	for _, rr := range reply.Answer {
+		switch record := rr.(type) {
+		case *dns.A:
+			ips = append(ips, record.A)
+		case *dns.AAAA:
+			ips = append(ips, record.AAAA)
+		}
+	}
+	return ips, nil
+}
+
+// LookupMX returns the exchanges of name, a Null MX returns none
+func (r *MailResolver) LookupMX(name string) ([]string, error) {
+	reply, err := r.Query(name, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	hosts, _, _, _ := ParseMXReply(reply)
+	return hosts, nil
+}
+
+func (r *MailResolver) LookupPTR(ip net.IP) ([]string, error) {
+	name, err := dns.ReverseAddr(ip.String())
+	if err != nil {
+		return nil, err
+	}
+	reply, err := r.Query(name, dns.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, rr := range reply.Answer {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			names = append(names, ptr.Ptr)
+		}
+	}
+	return names, nil
+}
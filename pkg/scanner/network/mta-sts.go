@@ -0,0 +1,148 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	MTASTS_SECOND_TIMEOUT = 10
+	MaxMTASTSPolicyBytes  = 64 << 10 // RFC 8461 allows senders to reject larger policies
+)
+
+// MTASTSChecker discovers and evaluates the MTA-STS policy of a mail domain (RFC 8461).
+type MTASTSChecker struct {
+	Resolver TXTResolver
+	Client   *http.Client
+}
+
+// NewMTASTSChecker uses the default mail resolver and a client with a timeout when nil, tests may inject both.
+func NewMTASTSChecker(resolver TXTResolver, client *http.Client) *MTASTSChecker {
+	if resolver == nil {
+		resolver = NewMailResolver("")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: MTASTS_SECOND_TIMEOUT * time.Second}
+	}
+	return &MTASTSChecker{Resolver: resolver, Client: client}
+}
+
+// Check looks up the policy of domain and evaluates every MX against the mx patterns and against
+// the certificates recorded for port 25 in mxTLS (keyed host:port).
+func (c *MTASTSChecker) Check(domain string, mxHosts []string, mxTLS map[string]structs.TLSCombinedRecord) structs.MTASTSRecord {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	record := structs.MTASTSRecord{Domain: domain, MXResults: make(map[string]structs.MTASTSMXResult), Errors: make([]string, 0)}
+
+	txtRecords, err := c.Resolver.LookupTXT("_mta-sts." + domain)
+	if err != nil && !errors.Is(err, ErrNXDomain) {
+		record.Errors = append(record.Errors, err.Error())
+		return record
+	}
+	stsRecords := make([]string, 0)
+	for _, txt := range txtRecords {
+		if strings.HasPrefix(txt, "v=STSv1") {
+			stsRecords = append(stsRecords, txt)
+		}
+	}
+	if len(stsRecords) == 0 {
+		return record
+	}
+	if len(stsRecords) > 1 {
+		record.Errors = append(record.Errors, "multiple STSv1 TXT records, senders treat the domain as having no policy")
+		return record
+	}
+	record.TXTRecord = stsRecords[0]
+	record.ID, err = structs.ParseMTASTSTXT(record.TXTRecord)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+		return record
+	}
+	record.Enabled = true
+
+	record.PolicyURL = fmt.Sprintf("https://mta-sts.%s/.well-known/mta-sts.txt", domain)
+	body, err := c.fetchPolicy(record.PolicyURL)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+		return record
+	}
+	policy, problems := structs.ParseMTASTSPolicy(body)
+	record.Policy = &policy
+	record.Errors = append(record.Errors, problems...)
+
+	for _, mx := range mxHosts {
+		result := structs.MTASTSMXResult{}
+		for _, pattern := range policy.MX {
+			if structs.MatchMTASTSPattern(pattern, mx) {
+				result.MatchedPattern = pattern
+				result.PatternMatch = true
+				break
+			}
+		}
+		result.CertificateChecked, result.CertificateValid = mxCertificateValid(mx, mxTLS)
+		result.Compliant = result.PatternMatch && result.CertificateValid
+		if policy.Mode == structs.MTASTSModeEnforce || policy.Mode == structs.MTASTSModeTesting {
+			if !result.PatternMatch {
+				record.Errors = append(record.Errors, fmt.Sprintf("mx %s does not match any policy mx pattern", mx))
+			}
+			if result.CertificateChecked && !result.CertificateValid {
+				record.Errors = append(record.Errors, fmt.Sprintf("mx %s does not present a valid certificate for its name", mx))
+			}
+		}
+		record.MXResults[mx] = result
+	}
+	return record
+}
+
+// fetchPolicy retrieves the policy without following redirects, which RFC 8461 forbids.
+func (c *MTASTSChecker) fetchPolicy(url string) (string, error) {
+	client := *c.Client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: policy fetch returned %d", ErrHTTPStatus, resp.StatusCode)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return "", fmt.Errorf("policy media type %q is not text/plain", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxMTASTSPolicyBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > MaxMTASTSPolicyBytes {
+		return "", fmt.Errorf("policy is larger than %d bytes", MaxMTASTSPolicyBytes)
+	}
+	return string(body), nil
+}
+
+// mxCertificateValid reports whether port 25 of the MX was scanned and every IP presented a valid certificate.
+func mxCertificateValid(mx string, mxTLS map[string]structs.TLSCombinedRecord) (bool, bool) {
+	for _, host := range []string{mx, strings.TrimSuffix(mx, "."), mx + "."} {
+		tlsRecord, ok := mxTLS[net.JoinHostPort(host, "25")]
+		if !ok {
+			continue
+		}
+		if len(tlsRecord.Certificates) == 0 {
+			return true, false
+		}
+		for _, certificate := range tlsRecord.Certificates {
+			if !certificate.Status.Valid {
+				return true, false
+			}
+		}
+		return true, true
+	}
+	return false, false
+}
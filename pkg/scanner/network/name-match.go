@@ -0,0 +1,91 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"crypto/x509"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// matchesWildcard follows RFC 6125, the wildcard may only be the complete left-most label
+// and matches exactly one label.
+func matchesWildcard(pattern string, hostname string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	_, hostSuffix, found := strings.Cut(hostname, ".")
+	return found && hostSuffix == pattern[2:]
+}
+
+// nameCoveredBy returns the SAN covering the hostname and whether it was a wildcard match.
+func nameCoveredBy(hostname string, sans []string) (string, structs.NameMatchType) {
+	for _, san := range sans {
+		if san == hostname {
+			return san, structs.NameMatchExactSAN
+		}
+	}
+	for _, san := range sans {
+		if matchesWildcard(san, hostname) {
+			return san, structs.NameMatchWildcardSAN
+		}
+	}
+	return "", structs.NameMatchNone
+}
+
+// MatchHostname reports how the scanned hostname is covered by the certificate, whether the
+// www. variant and the registrable apex are covered, and which other registrable domains share it.
+func MatchHostname(hostname string, c *x509.Certificate) structs.NameMatchRecord {
+	hostname = normalizeName(hostname)
+	sans := make([]string, 0, len(c.DNSNames))
+	for _, san := range c.DNSNames {
+		sans = append(sans, normalizeName(san))
+	}
+	commonName := normalizeName(c.Subject.CommonName)
+
+	record := structs.NameMatchRecord{Hostname: hostname, SANCount: len(sans)}
+	record.MatchedName, record.MatchType = nameCoveredBy(hostname, sans)
+	// Clients ignore the CN once SANs are present, so a CN match only counts without SANs.
+	if record.MatchType == structs.NameMatchNone && len(sans) == 0 && len(commonName) > 0 &&
+		(commonName == hostname || matchesWildcard(commonName, hostname)) {
+		record.MatchedName = commonName
+		record.MatchType = structs.NameMatchCommonNameOnly
+		sans = append(sans, commonName)
+	}
+
+	if strings.HasPrefix(hostname, "www.") {
+		record.WWWVariant = strings.TrimPrefix(hostname, "www.")
+	} else {
+		record.WWWVariant = "www." + hostname
+	}
+	_, wwwMatch := nameCoveredBy(record.WWWVariant, sans)
+	record.WWWVariantCovered = wwwMatch != structs.NameMatchNone
+
+	hostRegistrable, err := publicsuffix.EffectiveTLDPlusOne(hostname)
+	if err == nil {
+		record.Apex = hostRegistrable
+		_, apexMatch := nameCoveredBy(hostRegistrable, sans)
+		record.ApexCovered = apexMatch != structs.NameMatchNone
+	}
+
+	shared := make(map[string]struct{})
+	for _, san := range sans {
+		registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimPrefix(san, "*."))
+		if err != nil || registrable == hostRegistrable {
+			continue
+		}
+		shared[registrable] = struct{}{}
+	}
+	record.SharedRegistrableDomains = make([]string, 0, len(shared))
+	for registrable := range shared {
+		record.SharedRegistrableDomains = append(record.SharedRegistrableDomains, registrable)
+	}
+	sort.Strings(record.SharedRegistrableDomains)
+
+	return record
+}
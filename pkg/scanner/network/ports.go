@@ -2,6 +2,7 @@ package network
 
 import (
 	"net"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -21,7 +22,7 @@ type PortScanReport struct {
 
 func selectivePortScan(ip net.IP, port int, taskReport chan PortScanReport) {
 	targetInstance := net.JoinHostPort(ip.String(), strconv.FormatInt(int64(port), 10))
-	_, err := net.DialTimeout("tcp", targetInstance, 5*time.Second)
+	conn, err := net.DialTimeout("tcp", targetInstance, 5*time.Second)
 	if err != nil {
 		taskReport <- PortScanReport{
 			IP:     ip,
@@ -30,6 +31,7 @@ func selectivePortScan(ip net.IP, port int, taskReport chan PortScanReport) {
 		}
 		return
 	}
+	conn.Close()
 	taskReport <- PortScanReport{
 		IP:     ip,
 		Port:   port,
@@ -38,7 +40,8 @@ func selectivePortScan(ip net.IP, port int, taskReport chan PortScanReport) {
 	return
 }
 
-func PerformGreedyPortScan(ipAddresses []net.IP) []int {
+// PerformPerIPPortScan returns the open SMTP ports of every IP, keyed ip : sorted ports
+func PerformPerIPPortScan(ipAddresses []net.IP) map[string][]int {
 	numTasks := len(ipAddresses) * len(SMTPPorts)
 	tasks := make(chan PortScanReport, numTasks)
 
@@ -48,17 +51,18 @@ func PerformGreedyPortScan(ipAddresses []net.IP) []int {
 		}
 	}
 
-	openPortMap := make(map[int]bool, 0)
+	openPorts := make(map[string][]int)
+	for _, ip := range ipAddresses {
+		openPorts[ip.String()] = make([]int, 0)
+	}
 	for taskIndex := 0; taskIndex < numTasks; taskIndex++ {
 		response := <-tasks
 		if response.isOpen {
-			openPortMap[response.Port] = true
+			openPorts[response.IP.String()] = append(openPorts[response.IP.String()], response.Port)
 		}
 	}
-
-	openPorts := make([]int, 0)
-	for port := range openPortMap {
-		openPorts = append(openPorts, port)
+	for _, ports := range openPorts {
+		sort.Ints(ports)
 	}
 
 	return openPorts
@@ -0,0 +1,140 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxPTRNames bounds the forward lookups spent on an IP with many PTR records
+const maxPTRNames = 10
+
+// ReverseDNSResolver answers the PTR and forward lookups of FCrDNS, MailResolver implements it.
+type ReverseDNSResolver interface {
+	LookupPTR(ip net.IP) ([]string, error)
+	LookupIP(name string, queryType uint16) ([]net.IP, error)
+}
+
+func sameHostname(a string, b string) bool {
+	return len(a) > 0 && strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+// CheckReverseDNS looks up the PTR names of ip, confirms each against its forward records and
+// compares the confirmed names with hostname.
+func CheckReverseDNS(resolver ReverseDNSResolver, ip net.IP, hostname string) structs.ReverseDNSRecord {
+	record := structs.ReverseDNSRecord{PTRNames: make([]string, 0), ConfirmedNames: make([]string, 0)}
+	names, err := resolver.LookupPTR(ip)
+	if err != nil && !errors.Is(err, ErrNXDomain) {
+		record.Error = err.Error()
+		return record
+	}
+	record.PTRNames = append(record.PTRNames, names...)
+	if len(names) > maxPTRNames {
+		names = names[:maxPTRNames]
+	}
+	queryType := dns.TypeAAAA
+	if ip.To4() != nil {
+		queryType = dns.TypeA
+	}
+	for _, name := range names {
+		forward, err := resolver.LookupIP(name, queryType)
+		if err != nil {
+			continue
+		}
+		for _, forwardIP := range forward {
+			if forwardIP.Equal(ip) {
+				record.ConfirmedNames = append(record.ConfirmedNames, name)
+				break
+			}
+		}
+	}
+	record.ForwardConfirmed = len(record.ConfirmedNames) > 0
+	return CompareHostname(record, hostname)
+}
+
+// CompareHostname records hostname and whether a confirmed PTR name is that hostname
+func CompareHostname(record structs.ReverseDNSRecord, hostname string) structs.ReverseDNSRecord {
+	record.Hostname = hostname
+	record.MatchesHostname = false
+	for _, name := range record.ConfirmedNames {
+		if sameHostname(name, hostname) {
+			record.MatchesHostname = true
+		}
+	}
+	return record
+}
+
+// BannerHostname returns the name announced in an SMTP greeting, "mx.example.gov ESMTP ready".
+func BannerHostname(banner string) string {
+	fields := strings.Fields(strings.TrimPrefix(strings.SplitN(banner, "\n", 2)[0], "220"))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(fields[0], "-")
+}
+
+// CompareBannerHostname records the greeting's hostname and whether a confirmed PTR name announces it
+func CompareBannerHostname(record structs.ReverseDNSRecord, banner string) structs.ReverseDNSRecord {
+	record.BannerHostname = BannerHostname(banner)
+	for _, name := range record.ConfirmedNames {
+		if sameHostname(name, record.BannerHostname) {
+			record.MatchesBanner = true
+		}
+	}
+	return record
+}
+
+// MailReverseDNS builds the reverse DNS of every MX IP, keyed mx : ip since an IP shared by several
+// MXs is compared with each of them. Records of the TLS scans are reused, IPs that were not scanned
+// are looked up once. The banner comes from the IP's endpoints of the MX, port 25 first.
+func MailReverseDNS(resolver ReverseDNSResolver, mxHosts []string, mxIPs map[string][]net.IP, mxTLS map[string]structs.TLSCombinedRecord, endpoints []structs.MailEndpointRecord) map[string]map[string]structs.ReverseDNSRecord {
+	records := make(map[string]map[string]structs.ReverseDNSRecord)
+	lookedUp := make(map[string]structs.ReverseDNSRecord) // ip : record of an IP no scan covered
+	for _, mx := range mxHosts {
+		if _, ok := records[mx]; ok {
+			continue
+		}
+		records[mx] = make(map[string]structs.ReverseDNSRecord)
+		for _, ip := range mxIPs[mx] {
+			record, found := scannedReverseDNS(mx, ip.String(), mxTLS)
+			if !found {
+				if record, found = lookedUp[ip.String()]; found {
+					record = CompareHostname(record, mx)
+				} else {
+					record = CheckReverseDNS(resolver, ip, mx)
+					lookedUp[ip.String()] = record
+				}
+			}
+			banner := ""
+			for _, endpoint := range endpoints {
+				if endpoint.MX != mx || endpoint.IP != ip.String() || endpoint.Metadata == nil || len(endpoint.Metadata.Banner) == 0 {
+					continue
+				}
+				if len(banner) == 0 || endpoint.Port == 25 {
+					banner = endpoint.Metadata.Banner
+				}
+			}
+			if len(banner) > 0 {
+				record = CompareBannerHostname(record, banner)
+			}
+			records[mx][ip.String()] = record
+		}
+	}
+	return records
+}
+
+func scannedReverseDNS(mx string, ip string, mxTLS map[string]structs.TLSCombinedRecord) (structs.ReverseDNSRecord, bool) {
+	for hostPort, tlsRecord := range mxTLS {
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil || host != mx {
+			continue
+		}
+		if record, ok := tlsRecord.ReverseDNS[ip]; ok {
+			return record, true
+		}
+	}
+	return structs.ReverseDNSRecord{}, false
+}
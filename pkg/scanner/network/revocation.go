@@ -0,0 +1,250 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	REVOCATION_SECOND_TIMEOUT = 10
+	MaxCRLBytes               = 32 << 20 // Some government CAs publish very large CRLs
+)
+
+var (
+	ErrNoRevocationSources = errors.New("certificate has no OCSP responder or CRL distribution point")
+	ErrCRLSignature        = errors.New("CRL is not signed by the issuer")
+	ErrCRLExpired          = errors.New("CRL is past its next update")
+	ErrOCSPExpired         = errors.New("OCSP response is past its next update")
+
+	oidExtensionCRLReason = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+type crlCacheEntry struct {
+	once sync.Once
+	list *x509.RevocationList
+	err  error
+}
+
+// RevocationChecker queries OCSP responders and CRL distribution points. Downloaded CRLs are
+// cached for the lifetime of the checker, so a single checker should be shared across a batch.
+type RevocationChecker struct {
+	Client   *http.Client
+	mutex    sync.Mutex
+	crlCache map[string]*crlCacheEntry
+}
+
+// NewRevocationChecker uses the provided client for all requests, tests may inject a client for a local responder.
+func NewRevocationChecker(client *http.Client) *RevocationChecker {
+	if client == nil {
+		client = &http.Client{Timeout: REVOCATION_SECOND_TIMEOUT * time.Second}
+	}
+	return &RevocationChecker{Client: client, crlCache: make(map[string]*crlCacheEntry)}
+}
+
+// Check determines the revocation status of cert, which must be issued by issuer.
+func (r *RevocationChecker) Check(cert *x509.Certificate, issuer *x509.Certificate) *structs.RevocationRecord {
+	record := &structs.RevocationRecord{Status: structs.RevocationUnknown}
+	if len(cert.OCSPServer) == 0 && len(cert.CRLDistributionPoints) == 0 {
+		record.Error = ErrNoRevocationSources.Error()
+		return record
+	}
+
+	if len(cert.OCSPServer) > 0 {
+		record.OCSP = r.checkOCSP(cert, issuer, cert.OCSPServer[0])
+	}
+	for _, url := range cert.CRLDistributionPoints {
+		record.CRL = r.checkCRL(cert, issuer, url)
+		if record.CRL.Error == "" {
+			break
+		}
+	}
+
+	// A revocation reported by either source wins, otherwise any good answer is sufficient.
+	for _, source := range []*structs.RevocationSourceRecord{record.OCSP, record.CRL} {
+		if source != nil && source.Status == structs.RevocationRevoked {
+			record.Status = structs.RevocationRevoked
+			record.RevokedAt = source.RevokedAt
+			record.Reason = source.Reason
+			return record
+		}
+	}
+	for _, source := range []*structs.RevocationSourceRecord{record.OCSP, record.CRL} {
+		if source != nil && source.Status == structs.RevocationGood {
+			record.Status = structs.RevocationGood
+		}
+	}
+	return record
+}
+
+func (r *RevocationChecker) checkOCSP(cert *x509.Certificate, issuer *x509.Certificate, responder string) *structs.RevocationSourceRecord {
+	source := &structs.RevocationSourceRecord{URL: responder, Status: structs.RevocationUnknown}
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		source.Error = err.Error()
+		return source
+	}
+	resp, err := r.Client.Post(responder, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		source.Error = err.Error()
+		return source
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		source.Error = ErrHTTPStatus.Error()
+		return source
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		source.Error = err.Error()
+		return source
+	}
+	response, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		source.Error = err.Error()
+		return source
+	}
+
+	source.ThisUpdate = response.ThisUpdate
+	source.NextUpdate = response.NextUpdate
+	// A stale response is treated like a stale CRL
+	if !response.NextUpdate.IsZero() && time.Now().After(response.NextUpdate) {
+		source.Error = ErrOCSPExpired.Error()
+		return source
+	}
+	switch response.Status {
+	case ocsp.Good:
+		source.Status = structs.RevocationGood
+	case ocsp.Revoked:
+		source.Status = structs.RevocationRevoked
+		revokedAt := response.RevokedAt
+		source.RevokedAt = &revokedAt
+		source.Reason = response.RevocationReason
+	}
+	return source
+}
+
+func (r *RevocationChecker) checkCRL(cert *x509.Certificate, issuer *x509.Certificate, url string) *structs.RevocationSourceRecord {
+	source := &structs.RevocationSourceRecord{URL: url, Status: structs.RevocationUnknown}
+	list, err := r.getCRL(url)
+	if err != nil {
+		source.Error = err.Error()
+		return source
+	}
+	if err := list.CheckSignatureFrom(issuer); err != nil {
+		source.Error = fmt.Sprintf("%s: %s", ErrCRLSignature.Error(), err.Error())
+		return source
+	}
+
+	source.ThisUpdate = list.ThisUpdate
+	source.NextUpdate = list.NextUpdate
+	// A stale CRL may be missing recent revocations, so it answers nothing either way
+	if !list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+		source.Error = ErrCRLExpired.Error()
+		return source
+	}
+	source.Status = structs.RevocationGood
+	for _, revoked := range list.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			source.Status = structs.RevocationRevoked
+			revokedAt := revoked.RevocationTime
+			source.RevokedAt = &revokedAt
+			source.Reason = crlEntryReason(revoked)
+			break
+		}
+	}
+	return source
+}
+
+// crlEntryReason returns the CRLReason entry extension, entries without one are unspecified (0)
+func crlEntryReason(revoked pkix.RevokedCertificate) int {
+	for _, extension := range revoked.Extensions {
+		if !extension.Id.Equal(oidExtensionCRLReason) {
+			continue
+		}
+		var reason asn1.Enumerated
+		if rest, err := asn1.Unmarshal(extension.Value, &reason); err == nil && len(rest) == 0 {
+			return int(reason)
+		}
+	}
+	return 0
+}
+
+// getCRL downloads and parses each distribution point once, concurrent callers wait on the first download.
+func (r *RevocationChecker) getCRL(url string) (*x509.RevocationList, error) {
+	r.mutex.Lock()
+	entry, ok := r.crlCache[url]
+	if !ok {
+		entry = &crlCacheEntry{}
+		r.crlCache[url] = entry
+	}
+	r.mutex.Unlock()
+
+	entry.once.Do(func() {
+		entry.list, entry.err = r.downloadCRL(url)
+	})
+	return entry.list, entry.err
+}
+
+func (r *RevocationChecker) downloadCRL(url string) (*x509.RevocationList, error) {
+	resp, err := r.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrHTTPStatus
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxCRLBytes))
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
+
+// CheckChain checks every certificate of the presented chain against its issuer. The verified chain
+// is preferred since it also contains the root that issued the last presented intermediate.
+// Returns the leaf record and the records of the presented intermediates, in presented order.
+func (r *RevocationChecker) CheckChain(presented []*x509.Certificate, verifiedChains [][]*x509.Certificate) (*structs.RevocationRecord, []*structs.RevocationRecord) {
+	chain := presented
+	if len(verifiedChains) > 0 {
+		chain = verifiedChains[0]
+	}
+	issuers := make(map[int]*x509.Certificate)
+	for i, cert := range presented {
+		for j := 0; j < len(chain)-1; j++ {
+			if chain[j].Equal(cert) {
+				issuers[i] = chain[j+1]
+				break
+			}
+		}
+	}
+
+	records := make([]*structs.RevocationRecord, len(presented))
+	for i, cert := range presented {
+		issuer, ok := issuers[i]
+		if !ok {
+			records[i] = &structs.RevocationRecord{Status: structs.RevocationUnknown, Error: "issuer not available"}
+			continue
+		}
+		records[i] = r.Check(cert, issuer)
+	}
+	if len(records) == 0 {
+		return nil, records
+	}
+	return records[0], records[1:]
+}
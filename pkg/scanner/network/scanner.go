@@ -2,7 +2,7 @@ package network
 
 import (
 	"Scanner/localtls"
-	"Scanner/pkg/config"
+	"Scanner/pkg/scanner/storage"
 	structs2 "Scanner/pkg/scanner/structs"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -10,8 +10,8 @@ import (
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net"
-	"net/smtp"
 	"time"
 )
 
@@ -21,7 +21,17 @@ type TLSRequest struct {
 	ResolvedIPAddresses  []net.IP
 	Hostname             string
 	Port                 string
-	Type                 string // TLS or SMTP
+	Type                 string            // upgrade protocol name, e.g. TLS, SMTP or IMAP
+	IPTypes              map[string]string // ip : upgrade protocol name, overrides Type where IPs of a port differ
+	Mode                 string            // connection mode reported in the record, implicit-tls or starttls
+	Options              TLSScanOptions
+}
+
+// TLSScanOptions holds the optional collaborators shared by every request of a batch, nil disables the feature
+type TLSScanOptions struct {
+	CertificateStore  *storage.CertificateStore // persists every unique leaf and intermediate certificate
+	RevocationChecker *RevocationChecker        // checks OCSP and CRL status of every presented certificate
+	ServerSignatures  *ServerSignatureDB        // labels server fingerprints with products
 }
 
 // returned from multi-IP lookups per hostname (parallelized)
@@ -30,6 +40,11 @@ type TLSResult struct {
 	CipherSuites      []structs2.VersionSuitesRecord
 	CertificateRecord structs2.CertificateRecord
 	RawC              []byte
+	Chain             []*x509.Certificate // leaf first, as presented by the server
+	NameMatch         structs2.NameMatchRecord
+	Features          structs2.TLSFeatureRecord
+	Fingerprint       structs2.ServerFingerprintRecord
+	ReverseDNS        structs2.ReverseDNSRecord
 	ConnectionSuccess bool
 	Error             string
 }
@@ -67,13 +82,19 @@ func ParallelHostnameScan(requests []TLSRequest) map[string]structs2.MXSpecificD
 		fmt.Printf("%s done (%d/%d)\n",
 			net.JoinHostPort(r.OriginalTLSRequest.Hostname, r.OriginalTLSRequest.Port),
 			resultIndex+1, numTasks)
+		// An MX is scanned once per open port, keep the records of the ports that finished earlier
 		if _, ok := allMXSpecificData[r.OriginalTLSRequest.Hostname]; !ok {
-			allMXSpecificData[r.OriginalTLSRequest.Hostname] = structs2.MXSpecificData{}
+			var mxSpecificData structs2.MXSpecificData
+			mxSpecificData.MXTLSInformation = make(map[string]structs2.TLSCombinedRecord)
+			mxSpecificData.MXMetaData = make(map[string]structs2.SMTPMetadata)
+			mxSpecificData.MXEndpoints = make(map[string]structs2.MailEndpointRecord)
+			mxSpecificData.CertificateChains = make(map[string][]*x509.Certificate)
+			mxSpecificData.ScannedAt = time.Now().UTC()
+			allMXSpecificData[r.OriginalTLSRequest.Hostname] = mxSpecificData
+		}
+		for ipPort, chain := range r.Certificates {
+			allMXSpecificData[r.OriginalTLSRequest.Hostname].CertificateChains[ipPort] = chain
 		}
-		var mxSpecificData structs2.MXSpecificData
-		mxSpecificData.MXTLSInformation = make(map[string]structs2.TLSCombinedRecord)
-		mxSpecificData.MXMetaData = make(map[string]structs2.SMTPMetadata)
-		allMXSpecificData[r.OriginalTLSRequest.Hostname] = mxSpecificData
 		allMXSpecificData[r.OriginalTLSRequest.Hostname].MXTLSInformation[net.JoinHostPort(r.OriginalTLSRequest.Hostname, r.OriginalTLSRequest.Port)] = r.CombinedRecord
 	}
 	return allMXSpecificData
@@ -98,8 +119,14 @@ func (request TLSRequest) ParallelIPScan() (structs2.TLSCombinedRecord, map[stri
 
 	serializedIPAddresses := structs2.SerializeIPAddresses(request.ScannableIPAddresses)
 	// Certificate data
-	certificateSHA256FingerprintMap := make(map[string][]byte)        // fingerprint : raw byte, calculates unique certificates
-	certificateRecords := make(map[string]structs2.CertificateRecord) // ip : record, stores records
+	certificateSHA256FingerprintMap := make(map[string][]byte)              // fingerprint : raw byte, calculates unique certificates
+	certificateRecords := make(map[string]structs2.CertificateRecord)       // ip : record, stores records
+	chainFingerprints := make(map[string][]string)                          // ip : fingerprints, leaf first
+	nameMatches := make(map[string]structs2.NameMatchRecord)                // ip : hostname match against the leaf
+	features := make(map[string]structs2.TLSFeatureRecord)                  // ip : protocol features
+	serverFingerprints := make(map[string]structs2.ServerFingerprintRecord) // ip : server fingerprint
+	reverseDNS := make(map[string]structs2.ReverseDNSRecord)                // ip : PTR and forward confirmation
+	uniqueCertificates := make(map[string]*x509.Certificate)                // fingerprint : certificate, leaf and intermediates
 	// Error data
 	tlsErrors := make(map[string]string) // ip : error, stores all errors
 	// Cipher suite data
@@ -121,6 +148,7 @@ func (request TLSRequest) ParallelIPScan() (structs2.TLSCombinedRecord, map[stri
 
 	for resultIndex := 0; resultIndex < numTasks; resultIndex++ {
 		r := <-promiseResponses
+		reverseDNS[r.IP.String()] = r.ReverseDNS
 		// If successful TLS connection to IP, store ciphersuites, certificate chain, and sha256 fingerprint
 		if r.Error != "" {
 			tlsErrors[r.IP.String()] = r.Error
@@ -131,6 +159,25 @@ func (request TLSRequest) ParallelIPScan() (structs2.TLSCombinedRecord, map[stri
 			if _, ok := certificateSHA256FingerprintMap[r.CertificateRecord.SHA256Fingerprint]; !ok {
 				certificateSHA256FingerprintMap[r.CertificateRecord.SHA256Fingerprint] = r.RawC
 			}
+			certificateChains[net.JoinHostPort(r.IP.String(), request.Port)] = r.Chain
+			fingerprints := make([]string, 0, len(r.Chain))
+			for _, cert := range r.Chain {
+				fingerprint := storage.CertificateFingerprint(cert)
+				uniqueCertificates[fingerprint] = cert
+				fingerprints = append(fingerprints, fingerprint)
+			}
+			chainFingerprints[r.IP.String()] = fingerprints
+			nameMatches[r.IP.String()] = r.NameMatch
+			features[r.IP.String()] = r.Features
+			serverFingerprints[r.IP.String()] = r.Fingerprint
+		}
+	}
+
+	if request.Options.CertificateStore != nil {
+		for fingerprint, cert := range uniqueCertificates {
+			if _, err := request.Options.CertificateStore.Put(cert); err != nil {
+				log.Printf("[CertificateStore] unable to persist %s: %v", fingerprint, err)
+			}
 		}
 	}
 
@@ -142,39 +189,110 @@ func (request TLSRequest) ParallelIPScan() (structs2.TLSCombinedRecord, map[stri
 	record.ScannedIPs = serializedIPAddresses.IPs
 	record.IPv4Count = serializedIPAddresses.IPv4Count
 	record.IPv6Count = serializedIPAddresses.IPv6Count
+	record.ConnectionMode = request.Mode
 	record.NumUniqueCerts = len(certificateSHA256FingerprintMap)
 	record.Certificates = certificateRecords
+	record.CertificateChains = chainFingerprints
+	record.NameMatches = nameMatches
+	record.Features = features
+	record.ServerFingerprint = serverFingerprints
+	record.ReverseDNS = reverseDNS
 	record.Errors = tlsErrors
 	record.CipherSuites = cipherSuites
+	record.IdentifyConsistency()
 
 	return record, certificateChains
 }
 
-func CheckCertEVStatus(cert *x509.Certificate) structs2.EVCertInformation {
-	extensions := cert.Extensions
-	isEV := false
-	evOID := ""
-	org := ""
-	for _, ext := range extensions {
-		asn1OIDString := ext.Id.String()
-		if val, ok := localtls.EVObjectIdentifiers[asn1OIDString]; ok {
-			isEV = true
-			evOID = asn1OIDString
-			org = val
+// CheckCertEVStatus looks up the certificate policies in the EV policy registry and, when the
+// registry carries root data, verifies that one of the verified chains ends in a root entitled to the OID.
+// Without an entitled root such a certificate is not EV. The first entitled OID wins, otherwise the
+// first OID found in the registry is reported.
+func CheckCertEVStatus(cert *x509.Certificate, verifiedChains [][]*x509.Certificate) structs2.EVCertInformation {
+	var first *structs2.EVCertInformation
+	for _, oid := range cert.PolicyIdentifiers {
+		information, ok := evStatusForOID(oid.String(), verifiedChains)
+		if !ok {
+			continue
+		}
+		if information.RootEntitled {
+			return information
+		}
+		if first == nil {
+			first = &information
+		}
+	}
+	if first == nil {
+		return structs2.EVCertInformation{}
+	}
+	return *first
+}
+
+func evStatusForOID(asn1OIDString string, verifiedChains [][]*x509.Certificate) (structs2.EVCertInformation, bool) {
+	entries, source, ok := localtls.EVPolicyRegistry.Lookup(asn1OIDString)
+	if !ok {
+		return structs2.EVCertInformation{}, false
+	}
+	information := structs2.EVCertInformation{
+		ObjectIdentifier:    asn1OIDString,
+		IssuingOrganization: entries[0].CAOwner,
+		RegistrySource:      source,
+		RootVerifiable:      localtls.HasRootData(entries),
+	}
+	for i, chain := range verifiedChains {
+		root := chain[len(chain)-1]
+		rootFingerprint := sha256.Sum256(root.Raw)
+		fingerprint := hex.EncodeToString(rootFingerprint[:])
+		if i == 0 {
+			information.RootFingerprint = fingerprint
+		}
+		if owner, entitled := localtls.EntitledOwner(entries, fingerprint); entitled {
+			information.RootEntitled = true
+			information.RootFingerprint = fingerprint
+			information.IssuingOrganization = owner
 			break
 		}
 	}
-	return structs2.EVCertInformation{
-		IsEVCertType:        isEV,
-		ObjectIdentifier:    evOID,
-		IssuingOrganization: org,
+	// The OID alone only counts when the registry cannot say which roots are entitled to it
+	information.IsEVCertType = information.RootEntitled || !information.RootVerifiable
+	return information, true
+}
+
+// SerializeChain records the parent certificates presented after the leaf
+func SerializeChain(parentCertificates []*x509.Certificate) []structs2.ChainRecord {
+	chain := make([]structs2.ChainRecord, 0)
+	for _, parentCertificate := range parentCertificates {
+		sha256Fingerprint := sha256.Sum256(parentCertificate.Raw)
+		keyType, keyLength := structs2.IdentifyPublicKeyType(parentCertificate.PublicKey)
+		chain = append(chain, structs2.ChainRecord{
+			Subject:            parentCertificate.Subject.String(),
+			Issuer:             parentCertificate.Issuer.String(),
+			Fingerprint:        hex.EncodeToString(sha256Fingerprint[:]),
+			KeyType:            keyType,
+			KeyLength:          keyLength,
+			SignatureAlgorithm: parentCertificate.SignatureAlgorithm.String(),
+			IsCA:               parentCertificate.IsCA,
+			Extensions:         structs2.SerializeCertificateExtensions(parentCertificate),
+		})
 	}
+	return chain
+}
+
+// connectionType returns the upgrade protocol name used to scan ip
+func (request TLSRequest) connectionType(ip net.IP) string {
+	if connectionType, ok := request.IPTypes[ip.String()]; ok {
+		return connectionType
+	}
+	return request.Type
 }
 
 // individual thread worker (responsible for retrieving cipher suites + certificate info)
 func IPScanWorker(request TLSRequest, ips <-chan net.IP, results chan<- TLSResult) {
+	reverseResolver := NewMailResolver("")
 	for IP := range ips {
+		connectionType := request.connectionType(IP)
 		res := TLSResult{IP: IP, ConnectionSuccess: true}
+		res.ReverseDNS = CheckReverseDNS(reverseResolver, IP, request.Hostname)
 		// nil if no validation error, set to error otherwise
 		clientConfig := tls.Config{
 			ServerName:         request.Hostname,
@@ -184,113 +302,38 @@ func IPScanWorker(request TLSRequest, ips <-chan net.IP, results chan<- TLSResul
 			MaxVersion:         tls.VersionTLS13,
 		}
 
-		var certValid bool
+		var verifiedChains [][]*x509.Certificate
 		var certErr error
 		var c *x509.Certificate
 		// Gather certificate info
 		statusRecord := structs2.StatusRecord{}
 
-		chain := make([]structs2.ChainRecord, 0)
-		// switch statement which handles differences between TLS and SMTP
-		switch request.Type {
-		case "SMTP":
-			dialer := &net.Dialer{
-				Timeout: HOSTNAME_SECOND_TIMEOUT * time.Second,
-			}
-			conn, err := dialer.Dial("tcp", net.JoinHostPort(IP.String(), request.Port))
-			if err != nil {
-				res.ConnectionSuccess = false
-				results <- res
-				continue
-			}
-			conn.SetDeadline(time.Now().Add(time.Second * config.TLS_CIPHER_SUITE_SECOND_TIMEOUT))
-
-			smtpConn, err := smtp.NewClient(conn, request.Hostname)
-			if err != nil {
-				res.ConnectionSuccess = false
-				results <- res
-				continue
-			}
-
-			smtpConn.Hello(config.SMTPHELO_Introduction)
-
-			connErr := smtpConn.StartTLS(&clientConfig)
-			if connErr != nil {
-				res.ConnectionSuccess = false
-				results <- res
-				continue
-			}
-			// Gather suite info
-			res.CipherSuites = RetrieveCipherSuites(IP, request.Hostname, request.Port, request.Type)
-
-			connState, ok := smtpConn.TLSConnectionState()
-			if ok {
-				certValid, certErr := VerifyTLSConnection(connState)
-				if certErr != nil {
-					statusRecord.Err = certErr.Error()
-				} else {
-					statusRecord.Err = ""
-				}
-				statusRecord.Valid = certValid
-			} else {
-				statusRecord.Err = "failed to create a TLS connection"
-				statusRecord.Valid = false
-			}
+		// The upgrade protocol of the request type handles the differences between implicit TLS and STARTTLS
+		conn, err := dialTLS(IP, request.Hostname, request.Port, connectionType, &clientConfig)
+		if err != nil {
+			res.Error = err.Error()
+			res.ConnectionSuccess = false
+			results <- res
+			continue
+		}
 
-			c = connState.PeerCertificates[0]
-			// create chain of parent certificates
-			chain := make([]structs2.ChainRecord, 0)
-
-			for _, parentCertificate := range connState.PeerCertificates[1:] {
-				sha256Fingerprint := sha256.Sum256(parentCertificate.Raw)
-				keyType, keyLength := structs2.IdentifyPublicKeyType(parentCertificate.PublicKey)
-				chain = append(chain, structs2.ChainRecord{
-					Issuer:             parentCertificate.Issuer.String(),
-					Fingerprint:        hex.EncodeToString(sha256Fingerprint[:]),
-					KeyType:            keyType,
-					KeyLength:          keyLength,
-					SignatureAlgorithm: parentCertificate.SignatureAlgorithm.String(),
-					IsCA:               parentCertificate.IsCA,
-				})
-			}
-		case "TLS":
-			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: HOSTNAME_SECOND_TIMEOUT * time.Second}, "tcp", net.JoinHostPort(IP.String(), request.Port), &clientConfig)
-			if err != nil {
-				res.Error = err.Error()
-				res.ConnectionSuccess = false
-				results <- res
-				continue
-			}
+		connState := conn.ConnectionState()
+		conn.Close()
+		verifiedChains, certErr = VerifyTLSConnectionChains(connState)
+		if certErr == nil {
+			statusRecord.Err = ""
+		} else {
+			statusRecord.Err = certErr.Error()
+		}
+		statusRecord.Valid = certErr == nil
 
-			tlsConnectionState := conn.ConnectionState()
-			certValid, certErr = VerifyTLSConnection(tlsConnectionState)
+		// Gather suite info
+		res.CipherSuites = RetrieveCipherSuites(IP, request.Hostname, request.Port, connectionType)
 
-			if certErr == nil {
-				statusRecord.Err = ""
-			} else {
-				statusRecord.Err = certErr.Error()
-			}
-			statusRecord.Valid = certValid
-
-			// Gather suite info
-			res.CipherSuites = RetrieveCipherSuites(IP, request.Hostname, request.Port, request.Type)
-
-			c = conn.ConnectionState().PeerCertificates[0]
-			// create chain of parent certificates
-			for _, parentCertificate := range conn.ConnectionState().PeerCertificates[1:] {
-				sha256Fingerprint := sha256.Sum256(parentCertificate.Raw)
-				keyType, keyLength := structs2.IdentifyPublicKeyType(parentCertificate.PublicKey)
-				chain = append(chain, structs2.ChainRecord{
-					Issuer:             parentCertificate.Issuer.String(),
-					Fingerprint:        hex.EncodeToString(sha256Fingerprint[:]),
-					KeyType:            keyType,
-					KeyLength:          keyLength,
-					SignatureAlgorithm: parentCertificate.SignatureAlgorithm.String(),
-					IsCA:               parentCertificate.IsCA,
-				})
-			}
-			conn.Close()
-		}
+		c = connState.PeerCertificates[0]
+		res.Chain = connState.PeerCertificates
+		// create chain of parent certificates
+		chain := SerializeChain(connState.PeerCertificates[1:])
 
 		record := structs2.CertificateRecord{}
 		record.Subject = c.Subject.String()
@@ -303,10 +346,18 @@ func IPScanWorker(request TLSRequest, ips <-chan net.IP, results chan<- TLSResul
 		record.PublicKey = structs2.SerializePublicKey(c.PublicKey)
 		record.Issuer = c.Issuer.String()
 		record.SignatureAlgorithm = c.SignatureAlgorithm.String()
-		record.EV = CheckCertEVStatus(c)
+		record.EV = CheckCertEVStatus(c, verifiedChains)
 
 		record.Status = statusRecord
 
+		if request.Options.RevocationChecker != nil {
+			leafRevocation, chainRevocations := request.Options.RevocationChecker.CheckChain(res.Chain, verifiedChains)
+			record.Revocation = leafRevocation
+			for i := range chain {
+				chain[i].Revocation = chainRevocations[i]
+			}
+		}
+
 		record.Chain = chain
 
 		// check for duplicate certificate
@@ -322,6 +373,7 @@ func IPScanWorker(request TLSRequest, ips <-chan net.IP, results chan<- TLSResul
 
 		SPKIFingerprint := sha256.Sum256(c.RawSubjectPublicKeyInfo)
 		record.SPKISHA256Hash = hex.EncodeToString(SPKIFingerprint[:])
+		record.Extensions = structs2.SerializeCertificateExtensions(c)
 		// new certificate check
 		res.RawC = c.Raw
 
@@ -330,6 +382,9 @@ func IPScanWorker(request TLSRequest, ips <-chan net.IP, results chan<- TLSResul
 			res.Error = certErr.Error()
 		}
 		res.CertificateRecord = record
+		res.NameMatch = MatchHostname(request.Hostname, c)
+		res.Features = ProbeTLSFeatures(IP, request.Hostname, request.Port, connectionType, SupportedVersions(res.CipherSuites))
+		res.Fingerprint = FingerprintServer(IP, request.Hostname, request.Port, connectionType, request.Options.ServerSignatures)
 		results <- res
 	}
 }
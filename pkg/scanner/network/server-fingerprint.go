@@ -0,0 +1,167 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FingerprintProbe is one of the fixed ClientHellos a server fingerprint is computed from. The set
+// must stay stable across scans, otherwise fingerprints of the same stack are not comparable.
+type FingerprintProbe struct {
+	Name string
+	Spec ClientHelloSpec
+}
+
+var FingerprintProbes = []FingerprintProbe{
+	{Name: "tls12", Spec: ClientHelloSpec{
+		Version:         tls.VersionTLS12,
+		CipherSuites:    DefaultProbeCipherSuites,
+		EmptyExtensions: []uint16{ExtensionRenegotiationInfo, ExtensionExtendedMasterSecret, ExtensionSessionTicket},
+	}},
+	{Name: "tls12-alpn", Spec: ClientHelloSpec{
+		Version:         tls.VersionTLS12,
+		CipherSuites:    DefaultProbeCipherSuites,
+		ALPN:            []string{"h2", "http/1.1"},
+		EmptyExtensions: []uint16{ExtensionRenegotiationInfo, ExtensionExtendedMasterSecret, ExtensionEncryptThenMAC, ExtensionSessionTicket},
+	}},
+	{Name: "tls13", Spec: ClientHelloSpec{
+		Version:           tls.VersionTLS12,
+		CipherSuites:      DefaultProbeCipherSuites,
+		SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+		ALPN:              []string{"h2", "http/1.1"},
+		EmptyExtensions:   []uint16{ExtensionRenegotiationInfo, ExtensionExtendedMasterSecret, ExtensionSessionTicket},
+	}},
+}
+
+// ServerSignature maps a JA3S and/or JA4S fingerprint to a product label, e.g. "F5 BIG-IP".
+type ServerSignature struct {
+	JA3S  string `json:"ja3s"`
+	JA4S  string `json:"ja4s"`
+	Probe string `json:"probe"` // optional, restricts the signature to one probe
+	Label string `json:"label"`
+}
+
+type ServerSignatureDB struct {
+	Signatures []ServerSignature `json:"signatures"`
+}
+
+func LoadServerSignatures(path string) (*ServerSignatureDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &ServerSignatureDB{}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Match returns the labels of every signature matching the probe fingerprint.
+func (db *ServerSignatureDB) Match(fingerprint structs.ProbeFingerprint) []string {
+	labels := make([]string, 0)
+	for _, signature := range db.Signatures {
+		if len(signature.Probe) > 0 && signature.Probe != fingerprint.Probe {
+			continue
+		}
+		if (len(signature.JA3S) > 0 && signature.JA3S == fingerprint.JA3S) ||
+			(len(signature.JA4S) > 0 && signature.JA4S == fingerprint.JA4S) {
+			labels = append(labels, signature.Label)
+		}
+	}
+	return labels
+}
+
+// JA3S returns the JA3S string "SSLVersion,Cipher,Extensions" and its MD5 hash.
+func JA3S(hello *ServerHello) (string, string) {
+	extensions := make([]string, 0, len(hello.Extensions))
+	for _, extension := range hello.Extensions {
+		extensions = append(extensions, strconv.Itoa(int(extension)))
+	}
+	ja3s := fmt.Sprintf("%d,%d,%s", hello.Version, hello.CipherSuite, strings.Join(extensions, "-"))
+	hash := md5.Sum([]byte(ja3s))
+	return ja3s, hex.EncodeToString(hash[:])
+}
+
+func ja4Version(version uint16) string {
+	switch version {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// JA4S returns the raw and hashed JA4S fingerprint, e.g. t130200_1301_a56c5b993250.
+// Extensions are kept in the order the server sent them.
+func JA4S(hello *ServerHello) (string, string) {
+	alpn := "00"
+	if len(hello.ALPN) > 0 {
+		alpn = string(hello.ALPN[0]) + string(hello.ALPN[len(hello.ALPN)-1])
+	}
+	count := len(hello.Extensions)
+	if count > 99 {
+		count = 99
+	}
+	prefix := fmt.Sprintf("t%s%02d%s_%04x", ja4Version(hello.NegotiatedVersion), count, alpn, hello.CipherSuite)
+
+	extensions := make([]string, 0, len(hello.Extensions))
+	for _, extension := range hello.Extensions {
+		extensions = append(extensions, fmt.Sprintf("%04x", extension))
+	}
+	raw := fmt.Sprintf("%s_%s", prefix, strings.Join(extensions, ","))
+	extensionHash := "000000000000"
+	if len(extensions) > 0 {
+		hash := sha256.Sum256([]byte(strings.Join(extensions, ",")))
+		extensionHash = hex.EncodeToString(hash[:])[:12]
+	}
+	return raw, fmt.Sprintf("%s_%s", prefix, extensionHash)
+}
+
+// FingerprintServer runs every probe against the IP and labels the results with the optional signature database.
+func FingerprintServer(ip net.IP, hostname string, port string, connectionType string, db *ServerSignatureDB) structs.ServerFingerprintRecord {
+	record := structs.ServerFingerprintRecord{Probes: make([]structs.ProbeFingerprint, 0), Labels: make([]string, 0)}
+	labels := make(map[string]struct{})
+	for _, probe := range FingerprintProbes {
+		spec := probe.Spec
+		spec.ServerName = hostname
+		fingerprint := structs.ProbeFingerprint{Probe: probe.Name}
+		hello, err := RawHandshake(ip, hostname, port, connectionType, spec)
+		if err != nil {
+			fingerprint.Error = err.Error()
+			record.Probes = append(record.Probes, fingerprint)
+			continue
+		}
+		fingerprint.JA3SString, fingerprint.JA3S = JA3S(hello)
+		fingerprint.JA4SRaw, fingerprint.JA4S = JA4S(hello)
+		if db != nil {
+			for _, label := range db.Match(fingerprint) {
+				labels[label] = struct{}{}
+			}
+		}
+		record.Probes = append(record.Probes, fingerprint)
+	}
+	for label := range labels {
+		record.Labels = append(record.Labels, label)
+	}
+	sort.Strings(record.Labels)
+	return record
+}
@@ -3,22 +3,36 @@ package network
 import (
 	"Scanner/pkg/config"
 	"Scanner/pkg/scanner/structs"
+	"crypto/tls"
 	"net"
 	"net/textproto"
 	"strings"
 	"time"
 )
 
-func GetSMTPBannerAndCapabilities(address string) structs.SMTPMetadata {
+type SMTPMetadataRequest struct {
+	Address    string // ip:port, so every IP of an MX is read separately
+	ServerName string // SNI for implicit TLS and STARTTLS
+	Mode       string // implicit-tls ports are read after the TLS handshake
+}
+
+func GetSMTPBannerAndCapabilities(address string, serverName string, mode string) structs.SMTPMetadata {
 	response := structs.NewSMTPMetadata(address)
 
 	dialer := &net.Dialer{
 		Timeout: HOSTNAME_SECOND_TIMEOUT * time.Second,
 	}
-	conn, err := dialer.Dial("tcp", address)
+	var conn net.Conn
+	var err error
+	if mode == structs.ModeImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
 	if err != nil {
 		return response
 	}
+	defer conn.Close()
 	conn.SetDeadline(time.Now().Add(time.Second * config.TLS_CIPHER_SUITE_SECOND_TIMEOUT))
 
 	// C: TCP-CONNECTION-ACK
@@ -32,63 +46,96 @@ func GetSMTPBannerAndCapabilities(address string) structs.SMTPMetadata {
 
 	// C: EHLO <introduction>
 	// S: Capabilities ...
-	format := "EHLO %s"
-	ehloCode := 250
-
-	id, err := text.Cmd(format, config.SMTPHELO_Introduction)
+	lines, err := sendEHLO(text)
 	if err != nil {
 		return response
 	}
-	text.StartResponse(id)
-	defer text.EndResponse(id)
 
-	_, msg, err := text.ReadResponse(ehloCode)
+	capabilities := make(map[string]string)
+	for _, line := range lines {
+		k, v, _ := strings.Cut(line, " ")
+		capabilities[k] = v
+	}
 
+	response.Capabilities = capabilities
+	response.ParsedCapabilities = structs.ParseEHLOCapabilities(lines, mode == structs.ModeImplicitTLS)
+	if mode == structs.ModeImplicitTLS || !response.ParsedCapabilities.StartTLS {
+		return response
+	}
+
+	// C: STARTTLS, then EHLO again over TLS since servers commonly only offer AUTH under encryption
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return response
+	}
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return response
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		return response
+	}
+	lines, err = sendEHLO(textproto.NewConn(tlsConn))
 	if err != nil {
 		return response
 	}
+	encryptedCapabilities := structs.ParseEHLOCapabilities(lines, true)
+	changes := structs.DiffCapabilities(response.ParsedCapabilities, encryptedCapabilities)
+	response.EncryptedCapabilities = &encryptedCapabilities
+	response.CapabilityChanges = &changes
 
-	capabilities := make(map[string]string)
-	capabilitiesList := strings.Split(msg, "\n")
-	if len(capabilitiesList) > 1 {
-		capabilitiesList = capabilitiesList[1:]
-		for _, line := range capabilitiesList {
-			k, v, _ := strings.Cut(line, " ")
-			capabilities[k] = v
-		}
+	return response
+}
+
+// sendEHLO returns the EHLO response lines following the greeting line
+func sendEHLO(text *textproto.Conn) ([]string, error) {
+	id, err := text.Cmd("EHLO %s", config.SMTPHELO_Introduction)
+	if err != nil {
+		return nil, err
 	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
 
-	response.Capabilities = capabilities
+	_, msg, err := text.ReadResponse(250)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(msg, "\n")
+	return lines[1:], nil
+}
 
-	return response
+type smtpMetadataResult struct {
+	request  SMTPMetadataRequest
+	metadata structs.SMTPMetadata
 }
 
-func GetSMTPMetadata(requests <-chan string, results chan<- structs.SMTPMetadata) {
-	for address := range requests {
-		response := GetSMTPBannerAndCapabilities(address)
-		results <- response
+func GetSMTPMetadata(requests <-chan SMTPMetadataRequest, results chan<- smtpMetadataResult) {
+	for request := range requests {
+		response := GetSMTPBannerAndCapabilities(request.Address, request.ServerName, request.Mode)
+		results <- smtpMetadataResult{request: request, metadata: response}
 	}
 }
 
-func ParallelMailMetadataScan(addresses []string) map[string]structs.SMTPMetadata {
-	result := make(map[string]structs.SMTPMetadata)
-	numThreads := len(addresses)
-	numTasks := len(addresses)
+// ParallelMailMetadataScan reads the banner and capabilities of every request
+func ParallelMailMetadataScan(requests []SMTPMetadataRequest) map[SMTPMetadataRequest]structs.SMTPMetadata {
+	result := make(map[SMTPMetadataRequest]structs.SMTPMetadata)
+	numThreads := len(requests)
+	numTasks := len(requests)
 
-	tasks := make(chan string, numTasks)
-	promises := make(chan structs.SMTPMetadata, numTasks)
+	tasks := make(chan SMTPMetadataRequest, numTasks)
+	promises := make(chan smtpMetadataResult, numTasks)
 
 	for workerIndex := 0; workerIndex < numThreads; workerIndex++ {
 		go GetSMTPMetadata(tasks, promises)
 	}
 
 	for jobIndex := 0; jobIndex < numTasks; jobIndex++ {
-		tasks <- addresses[jobIndex]
+		tasks <- requests[jobIndex]
 	}
+	close(tasks)
 
 	for resultIndex := 0; resultIndex < numTasks; resultIndex++ {
 		res := <-promises
-		result[res.GetHost()] = res
+		result[res.request] = res.metadata
 	}
 	return result
 }
@@ -0,0 +1,667 @@
+package network
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	ErrSPFLookupLimit     = errors.New("SPF evaluation exceeded 10 DNS lookups")
+	ErrSPFVoidLookupLimit = errors.New("SPF evaluation exceeded 2 void lookups")
+	ErrSPFMultipleRecords = errors.New("multiple SPF records")
+	ErrSPFMacro           = errors.New("invalid SPF macro")
+	errSPFMacroNeedsIP    = errors.New("SPF macro depends on the connecting IP")
+)
+
+// spfAnalysisLookupCap stops the expansion of a record tree, well past the limit receivers enforce
+// so the reported count stays useful, but bounded against include fan-out.
+const spfAnalysisLookupCap = 5 * structs.SPFMaxDNSLookups
+
+// SPFResolver answers the lookups of SPF evaluation. NODATA returns no records and no error,
+// a name that does not exist returns ErrNXDomain.
+type SPFResolver interface {
+	TXTResolver
+	LookupIP(name string, queryType uint16) ([]net.IP, error) // A or AAAA
+	LookupMX(name string) ([]string, error)
+	LookupPTR(ip net.IP) ([]string, error)
+}
+
+// SPFChecker retrieves, expands and evaluates the SPF policy of a mail domain (RFC 7208).
+type SPFChecker struct {
+	Resolver SPFResolver
+}
+
+// NewSPFChecker uses the default mail resolver when nil, tests point a MailResolver at an in-process server.
+func NewSPFChecker(resolver SPFResolver) *SPFChecker {
+	if resolver == nil {
+		resolver = NewMailResolver("")
+	}
+	return &SPFChecker{Resolver: resolver}
+}
+
+// Check expands the include and redirect tree of the domain's SPF record and evaluates every IP
+// of the MX hosts in mxIPs and every A/AAAA record of the domain itself.
+func (c *SPFChecker) Check(domain string, mxIPs map[string][]net.IP) structs.SPFRecord {
+	domain = normalizeMailDomain(domain)
+	resolver := newSPFLookupCache(c.Resolver)
+	lookups := 0
+	record, _ := analyzeSPF(resolver, domain, make(map[string]bool), &lookups)
+	record.MXResults = make(map[string]structs.SPFIPResult)
+	record.DomainResults = make(map[string]structs.SPFIPResult)
+	if record.LookupLimitExceeded {
+		record.Errors = append(record.Errors, fmt.Sprintf("expansion stopped after %d DNS lookups", spfAnalysisLookupCap))
+	}
+	if record.DNSLookups > structs.SPFMaxDNSLookups {
+		record.LookupLimitExceeded = true
+		record.Errors = append(record.Errors, fmt.Sprintf("%d DNS lookups exceed the limit of %d, receivers may return permerror", record.DNSLookups, structs.SPFMaxDNSLookups))
+	}
+	if record.VoidLookups > structs.SPFMaxVoidLookups {
+		record.Errors = append(record.Errors, fmt.Sprintf("%d void lookups exceed the limit of %d", record.VoidLookups, structs.SPFMaxVoidLookups))
+	}
+
+	for host, ips := range mxIPs {
+		for _, ip := range ips {
+			result := evaluateSPF(resolver, ip, domain)
+			result.Host = host
+			record.MXResults[ip.String()] = result
+		}
+	}
+	for _, queryType := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		ips, err := resolver.LookupIP(domain, queryType)
+		if err != nil && !errors.Is(err, ErrNXDomain) {
+			record.Errors = append(record.Errors, err.Error())
+		}
+		for _, ip := range ips {
+			record.DomainResults[ip.String()] = evaluateSPF(resolver, ip, domain)
+		}
+	}
+	return record
+}
+
+// Evaluate runs check_host for a single IP, with "postmaster@<domain>" as the sender.
+func (c *SPFChecker) Evaluate(ip net.IP, domain string) structs.SPFIPResult {
+	return evaluateSPF(newSPFLookupCache(c.Resolver), ip, normalizeMailDomain(domain))
+}
+
+func normalizeMailDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+type spfRecordLookup struct {
+	txt    string
+	policy *structs.SPFPolicy
+	result string // the check_host result when no usable policy was found
+	void   bool   // NXDOMAIN or no TXT records at all
+	err    error
+}
+
+func lookupSPFRecord(resolver TXTResolver, domain string) spfRecordLookup {
+	txtRecords, err := resolver.LookupTXT(domain)
+	if errors.Is(err, ErrNXDomain) {
+		return spfRecordLookup{result: structs.SPFNone, void: true}
+	}
+	if err != nil {
+		return spfRecordLookup{result: structs.SPFTempError, err: err}
+	}
+	spfRecords := make([]string, 0)
+	for _, txt := range txtRecords {
+		if structs.IsSPFRecord(txt) {
+			spfRecords = append(spfRecords, txt)
+		}
+	}
+	switch {
+	case len(spfRecords) == 0:
+		return spfRecordLookup{result: structs.SPFNone, void: len(txtRecords) == 0}
+	case len(spfRecords) > 1:
+		return spfRecordLookup{result: structs.SPFPermError, err: fmt.Errorf("%w for %s", ErrSPFMultipleRecords, domain)}
+	}
+	policy, err := structs.ParseSPF(spfRecords[0])
+	if err != nil {
+		return spfRecordLookup{txt: spfRecords[0], result: structs.SPFPermError, err: err}
+	}
+	return spfRecordLookup{txt: spfRecords[0], policy: &policy}
+}
+
+// analyzeSPF expands the record of domain without a connecting IP. Lookups are counted the way
+// check_host counts them when every mechanism is reached. Domain specs using IP dependent macros
+// are counted but not followed. lookups is the running count of the whole tree, once it reaches
+// spfAnalysisLookupCap no further lookups are made. The second return value reports a void TXT lookup.
+func analyzeSPF(resolver SPFResolver, domain string, visited map[string]bool, lookups *int) (structs.SPFRecord, bool) {
+	record := structs.SPFRecord{Domain: domain, Includes: make([]structs.SPFRecord, 0), Errors: make([]string, 0)}
+	lookup := lookupSPFRecord(resolver, domain)
+	record.TXTRecord = lookup.txt
+	record.MultipleRecords = errors.Is(lookup.err, ErrSPFMultipleRecords)
+	if lookup.err != nil {
+		record.Errors = append(record.Errors, lookup.err.Error())
+	}
+	if lookup.policy == nil {
+		return record, lookup.void
+	}
+	record.Found = true
+	record.Policy = lookup.policy
+	// Loops are detected along the current path, the same include may appear in several branches
+	visited[domain] = true
+	defer delete(visited, domain)
+
+	for _, directive := range lookup.policy.Directives {
+		if directive.LookupMechanism() {
+			if *lookups >= spfAnalysisLookupCap {
+				record.LookupLimitExceeded = true
+				continue
+			}
+			record.DNSLookups++
+			*lookups++
+		}
+		if directive.Mechanism == "all" {
+			record.AllQualifier = directive.Qualifier
+		}
+		target, err := expandSPFTarget(directive.DomainSpec, nil, domain, "postmaster@"+domain)
+		if err != nil {
+			if !errors.Is(err, errSPFMacroNeedsIP) {
+				record.Errors = append(record.Errors, err.Error())
+			}
+			continue
+		}
+		switch directive.Mechanism {
+		case "include":
+			if visited[target] {
+				record.Errors = append(record.Errors, fmt.Sprintf("include loop at %s", target))
+				continue
+			}
+			include, void := analyzeSPF(resolver, target, visited, lookups)
+			if void {
+				record.VoidLookups++
+			}
+			if !include.Found {
+				record.Errors = append(record.Errors, fmt.Sprintf("include:%s has no usable SPF record, receivers return permerror when it is reached", target))
+			}
+			record.DNSLookups += include.DNSLookups
+			record.VoidLookups += include.VoidLookups
+			record.LookupLimitExceeded = record.LookupLimitExceeded || include.LookupLimitExceeded
+			record.Includes = append(record.Includes, include)
+		case "a", "exists":
+			count, err := countSPFAddresses(resolver, target, directive.Mechanism == "a")
+			if err != nil {
+				record.Errors = append(record.Errors, err.Error())
+			} else if count == 0 {
+				record.VoidLookups++
+			}
+		case "mx":
+			hosts, err := resolver.LookupMX(target)
+			if err != nil && !errors.Is(err, ErrNXDomain) {
+				record.Errors = append(record.Errors, err.Error())
+			} else if len(hosts) == 0 {
+				record.VoidLookups++
+			} else if len(hosts) > structs.SPFMaxDNSLookups {
+				record.Errors = append(record.Errors, fmt.Sprintf("mx:%s has %d MX hosts, more than %d is a permerror", target, len(hosts), structs.SPFMaxDNSLookups))
+			}
+		}
+	}
+
+	// redirect is ignored when the record has an "all" mechanism
+	if len(lookup.policy.Redirect) > 0 && len(record.AllQualifier) == 0 {
+		if *lookups >= spfAnalysisLookupCap {
+			record.LookupLimitExceeded = true
+			return record, false
+		}
+		record.DNSLookups++
+		*lookups++
+		target, err := expandSPFTarget(lookup.policy.Redirect, nil, domain, "postmaster@"+domain)
+		switch {
+		case errors.Is(err, errSPFMacroNeedsIP):
+		case err != nil:
+			record.Errors = append(record.Errors, err.Error())
+		case visited[target]:
+			record.Errors = append(record.Errors, fmt.Sprintf("redirect loop at %s", target))
+		default:
+			redirect, void := analyzeSPF(resolver, target, visited, lookups)
+			if void {
+				record.VoidLookups++
+			}
+			if !redirect.Found {
+				record.Errors = append(record.Errors, fmt.Sprintf("redirect=%s has no usable SPF record, receivers return permerror", target))
+			}
+			record.DNSLookups += redirect.DNSLookups
+			record.VoidLookups += redirect.VoidLookups
+			record.LookupLimitExceeded = record.LookupLimitExceeded || redirect.LookupLimitExceeded
+			record.AllQualifier = redirect.AllQualifier
+			record.Redirect = &redirect
+		}
+	}
+	record.PermissiveAll = record.AllQualifier == "+" || record.AllQualifier == "?"
+	return record, false
+}
+
+func countSPFAddresses(resolver SPFResolver, name string, includeIPv6 bool) (int, error) {
+	queryTypes := []uint16{dns.TypeA}
+	if includeIPv6 {
+		queryTypes = append(queryTypes, dns.TypeAAAA)
+	}
+	count := 0
+	for _, queryType := range queryTypes {
+		ips, err := resolver.LookupIP(name, queryType)
+		if err != nil && !errors.Is(err, ErrNXDomain) {
+			return 0, err
+		}
+		count += len(ips)
+	}
+	return count, nil
+}
+
+// spfEvaluation holds the state of one check_host run, the lookup counters span every include and redirect.
+type spfEvaluation struct {
+	resolver    SPFResolver
+	ip          net.IP
+	sender      string
+	lookups     int
+	voidLookups int
+	depth       int
+}
+
+func evaluateSPF(resolver SPFResolver, ip net.IP, domain string) structs.SPFIPResult {
+	evaluation := &spfEvaluation{resolver: resolver, ip: ip, sender: "postmaster@" + domain}
+	result := structs.SPFIPResult{Host: domain}
+	var err error
+	result.Result, result.MatchedTerm, err = evaluation.checkHost(domain)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Pass = result.Result == structs.SPFPass
+	return result
+}
+
+// checkHost implements check_host() (RFC 7208 section 4) and returns the result and the deciding term.
+func (e *spfEvaluation) checkHost(domain string) (string, string, error) {
+	lookup := lookupSPFRecord(e.resolver, domain)
+	if lookup.policy == nil {
+		if lookup.void && e.depth > 0 {
+			if err := e.countVoidLookup(); err != nil {
+				return structs.SPFPermError, "", err
+			}
+		}
+		return lookup.result, "", lookup.err
+	}
+
+	for _, directive := range lookup.policy.Directives {
+		term := fmt.Sprintf("%s %s", domain, directive)
+		matched, errorResult, err := e.match(domain, directive)
+		if err != nil {
+			return errorResult, term, err
+		}
+		if matched {
+			return directive.Result(), term, nil
+		}
+	}
+
+	if len(lookup.policy.Redirect) > 0 {
+		term := fmt.Sprintf("%s redirect=%s", domain, lookup.policy.Redirect)
+		if err := e.countLookup(); err != nil {
+			return structs.SPFPermError, term, err
+		}
+		target, err := expandSPFTarget(lookup.policy.Redirect, e.ip, domain, e.sender)
+		if err != nil {
+			return structs.SPFPermError, term, err
+		}
+		e.depth++
+		result, matchedTerm, err := e.checkHost(target)
+		e.depth--
+		if result == structs.SPFNone {
+			return structs.SPFPermError, term, fmt.Errorf("redirect target %s has no SPF record", target)
+		}
+		return result, matchedTerm, err
+	}
+	return structs.SPFNeutral, "", nil
+}
+
+func (e *spfEvaluation) countLookup() error {
+	e.lookups++
+	if e.lookups > structs.SPFMaxDNSLookups {
+		return ErrSPFLookupLimit
+	}
+	return nil
+}
+
+func (e *spfEvaluation) countVoidLookup() error {
+	e.voidLookups++
+	if e.voidLookups > structs.SPFMaxVoidLookups {
+		return ErrSPFVoidLookupLimit
+	}
+	return nil
+}
+
+// checkAnswer turns a mechanism lookup into an error result, NXDOMAIN and NODATA are void lookups
+func (e *spfEvaluation) checkAnswer(count int, err error) (string, error) {
+	if err != nil && !errors.Is(err, ErrNXDomain) {
+		return structs.SPFTempError, err
+	}
+	if count == 0 {
+		if err := e.countVoidLookup(); err != nil {
+			return structs.SPFPermError, err
+		}
+	}
+	return "", nil
+}
+
+func (e *spfEvaluation) addressQueryType() uint16 {
+	if e.ip.To4() != nil {
+		return dns.TypeA
+	}
+	return dns.TypeAAAA
+}
+
+// match reports whether the directive matches the connecting IP, or the error result ending evaluation
+func (e *spfEvaluation) match(domain string, directive structs.SPFDirective) (bool, string, error) {
+	if directive.LookupMechanism() {
+		if err := e.countLookup(); err != nil {
+			return false, structs.SPFPermError, err
+		}
+	}
+	target, err := expandSPFTarget(directive.DomainSpec, e.ip, domain, e.sender)
+	if err != nil {
+		return false, structs.SPFPermError, err
+	}
+
+	switch directive.Mechanism {
+	case "all":
+		return true, "", nil
+	case "ip4", "ip6":
+		if (e.ip.To4() != nil) != (directive.Mechanism == "ip4") {
+			return false, "", nil
+		}
+		_, network, err := net.ParseCIDR(directive.Network)
+		return err == nil && network.Contains(e.ip), "", nil
+	case "a":
+		ips, err := e.resolver.LookupIP(target, e.addressQueryType())
+		if result, err := e.checkAnswer(len(ips), err); err != nil {
+			return false, result, err
+		}
+		return e.contains(ips, directive), "", nil
+	case "mx":
+		hosts, err := e.resolver.LookupMX(target)
+		if result, err := e.checkAnswer(len(hosts), err); err != nil {
+			return false, result, err
+		}
+		if len(hosts) > structs.SPFMaxDNSLookups {
+			return false, structs.SPFPermError, fmt.Errorf("mx:%s has more than %d MX hosts", target, structs.SPFMaxDNSLookups)
+		}
+		for _, host := range hosts {
+			ips, err := e.resolver.LookupIP(host, e.addressQueryType())
+			if err == nil && e.contains(ips, directive) {
+				return true, "", nil
+			}
+		}
+		return false, "", nil
+	case "ptr":
+		// Lookup failures make ptr not match (RFC 7208 section 5.5)
+		names, err := e.resolver.LookupPTR(e.ip)
+		if err != nil {
+			return false, "", nil
+		}
+		if len(names) > structs.SPFMaxDNSLookups {
+			names = names[:structs.SPFMaxDNSLookups]
+		}
+		for _, name := range names {
+			name = normalizeMailDomain(name)
+			if name != target && !strings.HasSuffix(name, "."+target) {
+				continue
+			}
+			ips, err := e.resolver.LookupIP(name, e.addressQueryType())
+			if err != nil {
+				continue
+			}
+			for _, ip := range ips {
+				if ip.Equal(e.ip) {
+					return true, "", nil
+				}
+			}
+		}
+		return false, "", nil
+	case "exists":
+		ips, err := e.resolver.LookupIP(target, dns.TypeA)
+		if result, err := e.checkAnswer(len(ips), err); err != nil {
+			return false, result, err
+		}
+		return len(ips) > 0, "", nil
+	case "include":
+		e.depth++
+		result, _, err := e.checkHost(target)
+		e.depth--
+		switch result {
+		case structs.SPFPass:
+			return true, "", nil
+		case structs.SPFFail, structs.SPFSoftFail, structs.SPFNeutral:
+			return false, "", nil
+		case structs.SPFTempError:
+			return false, structs.SPFTempError, err
+		case structs.SPFNone:
+			return false, structs.SPFPermError, fmt.Errorf("include target %s has no SPF record", target)
+		default:
+			return false, structs.SPFPermError, err
+		}
+	}
+	return false, structs.SPFPermError, fmt.Errorf("unknown mechanism %q", directive.Mechanism)
+}
+
+// contains applies the a and mx CIDR lengths to the resolved addresses
+func (e *spfEvaluation) contains(ips []net.IP, directive structs.SPFDirective) bool {
+	prefix, bits := directive.IP6Prefix, 128
+	if e.ip.To4() != nil {
+		prefix, bits = directive.IP4Prefix, 32
+	}
+	mask := net.CIDRMask(prefix, bits)
+	for _, ip := range ips {
+		if (ip.To4() != nil) != (bits == 32) {
+			continue
+		}
+		network := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+		if network.Contains(e.ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandSPFTarget expands the domain spec of a mechanism or modifier, an empty spec is the current domain.
+// A nil ip makes macros needing it fail with errSPFMacroNeedsIP.
+func expandSPFTarget(spec string, ip net.IP, domain string, sender string) (string, error) {
+	if len(spec) == 0 {
+		return domain, nil
+	}
+	expanded, err := ExpandSPFMacros(spec, ip, domain, sender)
+	if err != nil {
+		return "", err
+	}
+	return normalizeMailDomain(expanded), nil
+}
+
+// ExpandSPFMacros expands the macros of a domain spec (RFC 7208 section 7). The HELO name is taken
+// to be the domain and %{p} expands to "unknown" so no PTR lookup is spent on it.
+func ExpandSPFMacros(spec string, ip net.IP, domain string, sender string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			builder.WriteByte(spec[i])
+			continue
+		}
+		if i+1 >= len(spec) {
+			return "", fmt.Errorf("%w: trailing %% in %q", ErrSPFMacro, spec)
+		}
+		i++
+		switch spec[i] {
+		case '%':
+			builder.WriteByte('%')
+		case '_':
+			builder.WriteByte(' ')
+		case '-':
+			builder.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(spec[i:], '}')
+			if end < 2 {
+				return "", fmt.Errorf("%w: unterminated macro in %q", ErrSPFMacro, spec)
+			}
+			value, err := expandSPFMacro(spec[i+1:i+end], ip, domain, sender)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(value)
+			i += end
+		default:
+			return "", fmt.Errorf("%w: %%%c in %q", ErrSPFMacro, spec[i], spec)
+		}
+	}
+	expanded := builder.String()
+	// Expanded names longer than 253 characters lose labels from the left
+	for len(expanded) > 253 {
+		_, rest, ok := strings.Cut(expanded, ".")
+		if !ok {
+			break
+		}
+		expanded = rest
+	}
+	return expanded, nil
+}
+
+// expandSPFMacro expands the body of %{...}, a letter followed by optional digits, "r" and delimiters
+func expandSPFMacro(macro string, ip net.IP, domain string, sender string) (string, error) {
+	letter := macro[0]
+	lower := letter | 0x20
+	localPart, senderDomain, _ := strings.Cut(sender, "@")
+	var value string
+	switch lower {
+	case 's':
+		value = sender
+	case 'l':
+		value = localPart
+	case 'o':
+		value = senderDomain
+	case 'd', 'h':
+		value = domain
+	case 'i', 'v', 'p':
+		if ip == nil {
+			return "", errSPFMacroNeedsIP
+		}
+		switch {
+		case lower == 'p':
+			value = "unknown"
+		case lower == 'v' && ip.To4() != nil:
+			value = "in-addr"
+		case lower == 'v':
+			value = "ip6"
+		case ip.To4() != nil:
+			value = ip.To4().String()
+		default:
+			nibbles := make([]string, 0, 32)
+			for _, b := range ip.To16() {
+				nibbles = append(nibbles, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0x0f), 16))
+			}
+			value = strings.Join(nibbles, ".")
+		}
+	default:
+		return "", fmt.Errorf("%w: unknown macro letter %q", ErrSPFMacro, letter)
+	}
+
+	transformers := macro[1:]
+	digits := 0
+	for digits < len(transformers) && transformers[digits] >= '0' && transformers[digits] <= '9' {
+		digits++
+	}
+	keep := 0
+	if digits > 0 {
+		keep, _ = strconv.Atoi(transformers[:digits])
+		if keep == 0 {
+			return "", fmt.Errorf("%w: zero labels in %%{%s}", ErrSPFMacro, macro)
+		}
+	}
+	transformers = transformers[digits:]
+	reverse := strings.HasPrefix(transformers, "r") || strings.HasPrefix(transformers, "R")
+	if reverse {
+		transformers = transformers[1:]
+	}
+	delimiters := "."
+	if len(transformers) > 0 {
+		if strings.Trim(transformers, ".-+,/_=") != "" {
+			return "", fmt.Errorf("%w: invalid delimiters in %%{%s}", ErrSPFMacro, macro)
+		}
+		delimiters = transformers
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(delimiters, r) })
+	if reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+	if keep > 0 && keep < len(parts) {
+		parts = parts[len(parts)-keep:]
+	}
+	value = strings.Join(parts, ".")
+	// Upper case macro letters are URL escaped
+	if letter != lower {
+		value = url.QueryEscape(value)
+	}
+	return value, nil
+}
+
+// spfLookupCache answers repeated lookups of one Check from memory, every evaluated IP walks the same tree.
+type spfLookupCache struct {
+	resolver SPFResolver
+	answers  map[string]spfAnswer
+}
+
+type spfAnswer struct {
+	txt   []string
+	ips   []net.IP
+	names []string
+	err   error
+}
+
+func newSPFLookupCache(resolver SPFResolver) *spfLookupCache {
+	return &spfLookupCache{resolver: resolver, answers: make(map[string]spfAnswer)}
+}
+
+func (c *spfLookupCache) LookupTXT(name string) ([]string, error) {
+	key := "TXT " + normalizeMailDomain(name)
+	answer, ok := c.answers[key]
+	if !ok {
+		answer.txt, answer.err = c.resolver.LookupTXT(name)
+		c.answers[key] = answer
+	}
+	return answer.txt, answer.err
+}
+
+func (c *spfLookupCache) LookupIP(name string, queryType uint16) ([]net.IP, error) {
+	key := dns.TypeToString[queryType] + " " + normalizeMailDomain(name)
+	answer, ok := c.answers[key]
+	if !ok {
+		answer.ips, answer.err = c.resolver.LookupIP(name, queryType)
+		c.answers[key] = answer
+	}
+	return answer.ips, answer.err
+}
+
+func (c *spfLookupCache) LookupMX(name string) ([]string, error) {
+	key := "MX " + normalizeMailDomain(name)
+	answer, ok := c.answers[key]
+	if !ok {
+		answer.names, answer.err = c.resolver.LookupMX(name)
+		c.answers[key] = answer
+	}
+	return answer.names, answer.err
+}
+
+func (c *spfLookupCache) LookupPTR(ip net.IP) ([]string, error) {
+	key := "PTR " + ip.String()
+	answer, ok := c.answers[key]
+	if !ok {
+		answer.names, answer.err = c.resolver.LookupPTR(ip)
+		c.answers[key] = answer
+	}
+	return answer.names, answer.err
+}
@@ -7,6 +7,15 @@ import (
 
 // VerifyTLSConnection Explicit Verification Method for the TLS connection
 func VerifyTLSConnection(cs tls.ConnectionState) (bool, error) {
+	_, err := VerifyTLSConnectionChains(cs)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyTLSConnectionChains verifies the TLS connection and returns the verified chains, each ending in a trusted root
+func VerifyTLSConnectionChains(cs tls.ConnectionState) ([][]*x509.Certificate, error) {
 	opts := x509.VerifyOptions{
 		DNSName:       cs.ServerName,
 		Intermediates: x509.NewCertPool(),
@@ -14,9 +23,5 @@ func VerifyTLSConnection(cs tls.ConnectionState) (bool, error) {
 	for _, cert := range cs.PeerCertificates[1:] {
 		opts.Intermediates.AddCert(cert)
 	}
-	_, err := cs.PeerCertificates[0].Verify(opts)
-	if err != nil {
-		return false, err
-	}
-	return true, nil
+	return cs.PeerCertificates[0].Verify(opts)
 }
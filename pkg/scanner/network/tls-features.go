@@ -0,0 +1,141 @@
+package network
+
+import (
+	"Scanner/localtls"
+	"Scanner/pkg/scanner/structs"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// resumptionTicketWait bounds the read used to receive TLS 1.3 session tickets after the first handshake
+const resumptionTicketWait = 2 * time.Second
+
+// cbcCipherSuites are the suites encrypt-then-MAC applies to, servers only echo the extension for them.
+var cbcCipherSuites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+}
+
+func SupportedVersions(records []structs.VersionSuitesRecord) []uint16 {
+	versions := make([]uint16, 0)
+	for _, record := range records {
+		if record.IsSupported {
+			versions = append(versions, record.TLSVersion)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// ProbeTLSFeatures detects session, renegotiation and downgrade protection features with a few
+// extra handshakes. supportedVersions comes from the cipher suite scan and drives the fallback probe.
+//
+// Insecure renegotiation is ruled out by RFC 5746 support, otherwise a session of the negotiated
+// version is renegotiated with ProbeInsecureRenegotiation. Session ID resumption is not verified,
+// only whether the server assigns session IDs is reported. Resumption is verified with crypto/tls,
+// which resumes with session tickets (TLS 1.2) or PSKs (TLS 1.3).
+func ProbeTLSFeatures(ip net.IP, hostname string, port string, connectionType string, supportedVersions []uint16) structs.TLSFeatureRecord {
+	record := structs.TLSFeatureRecord{FallbackSCSV: structs.FallbackSCSVNotApplicable, Errors: make(map[string]string)}
+
+	// 1. TLS 1.2 hello offering every extension of interest with the preferred suites.
+	hello, err := RawHandshake(ip, hostname, port, connectionType, ClientHelloSpec{
+		ServerName:   hostname,
+		Version:      tls.VersionTLS12,
+		CipherSuites: localtls.TLS12Ciphers,
+		EmptyExtensions: []uint16{
+			ExtensionRenegotiationInfo,
+			ExtensionExtendedMasterSecret,
+			ExtensionEncryptThenMAC,
+			ExtensionSessionTicket,
+		},
+	})
+	if err != nil {
+		record.Errors["features"] = err.Error()
+	} else {
+		record.SessionIDAssigned = len(hello.SessionID) > 0
+		record.SessionTicketSupported = hello.HasExtension(ExtensionSessionTicket)
+		record.SecureRenegotiation = hello.HasExtension(ExtensionRenegotiationInfo)
+		if record.SecureRenegotiation {
+			insecure := false
+			record.InsecureRenegotiation = &insecure
+		} else if insecure, err := ProbeInsecureRenegotiation(ip, hostname, port, connectionType, hello.Version); err != nil {
+			// Without RFC 5746 only renegotiating an established session tells whether the server allows it
+			record.Errors["renegotiation"] = err.Error()
+		} else {
+			record.InsecureRenegotiation = &insecure
+		}
+		record.ExtendedMasterSecret = hello.HasExtension(ExtensionExtendedMasterSecret)
+		record.EncryptThenMAC = hello.HasExtension(ExtensionEncryptThenMAC)
+	}
+
+	// 2. Encrypt-then-MAC is only negotiated for CBC suites, retry offering nothing else.
+	if err == nil && !record.EncryptThenMAC {
+		cbcHello, err := RawHandshake(ip, hostname, port, connectionType, ClientHelloSpec{
+			ServerName:      hostname,
+			Version:         tls.VersionTLS12,
+			CipherSuites:    cbcCipherSuites,
+			EmptyExtensions: []uint16{ExtensionRenegotiationInfo, ExtensionEncryptThenMAC},
+		})
+		if err != nil {
+			record.Errors["encryptThenMac"] = err.Error()
+		} else {
+			record.EncryptThenMAC = cbcHello.HasExtension(ExtensionEncryptThenMAC)
+		}
+	}
+
+	// 3. TLS_FALLBACK_SCSV, offer one version below the highest supported version.
+	if len(supportedVersions) > 1 {
+		fallbackVersion := supportedVersions[len(supportedVersions)-2]
+		_, err := RawHandshake(ip, hostname, port, connectionType, ClientHelloSpec{
+			ServerName:   hostname,
+			Version:      fallbackVersion,
+			CipherSuites: append(append([]uint16{}, localtls.TLS12Ciphers...), CipherFallbackSCSV),
+		})
+		var alert HandshakeAlertError
+		switch {
+		case err == nil:
+			record.FallbackSCSV = structs.FallbackSCSVIgnored
+		case errors.As(err, &alert) && alert.Description == AlertInappropriateFallback:
+			record.FallbackSCSV = structs.FallbackSCSVHonoured
+		default:
+			record.FallbackSCSV = structs.FallbackSCSVUnknown
+			record.Errors["fallbackScsv"] = err.Error()
+		}
+	}
+
+	// 4. Resumption, a second full crypto/tls handshake offering the cached session.
+	cfg := &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS13,
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		conn, err := dialTLS(ip, hostname, port, connectionType, cfg)
+		if err != nil {
+			record.Errors["resumption"] = err.Error()
+			break
+		}
+		if attempt == 0 {
+			// TLS 1.3 tickets arrive after the handshake, a read lets crypto/tls process them.
+			conn.SetReadDeadline(time.Now().Add(resumptionTicketWait))
+			conn.Read(make([]byte, 1))
+		} else {
+			record.ResumptionSupported = conn.ConnectionState().DidResume
+		}
+		conn.Close()
+	}
+
+	return record
+}
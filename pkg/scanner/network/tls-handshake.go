@@ -0,0 +1,361 @@
+package network
+
+import (
+	"Scanner/localtls"
+	"Scanner/pkg/config"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// TLS wire constants used by the raw handshake layer (RFC 5246, RFC 8446 and the extension RFCs)
+const (
+	recordTypeChangeCipherSpec uint8 = 20
+	recordTypeAlert            uint8 = 21
+	recordTypeHandshake        uint8 = 22
+
+	handshakeTypeClientHello        uint8 = 1
+	handshakeTypeServerHello        uint8 = 2
+	handshakeTypeCertificate        uint8 = 11
+	handshakeTypeServerKeyExchange  uint8 = 12
+	handshakeTypeCertificateRequest uint8 = 13
+	handshakeTypeServerHelloDone    uint8 = 14
+	handshakeTypeClientKeyExchange  uint8 = 16
+	handshakeTypeFinished           uint8 = 20
+
+	ExtensionServerName           uint16 = 0
+	ExtensionSupportedGroups      uint16 = 10
+	ExtensionECPointFormats       uint16 = 11
+	ExtensionSignatureAlgorithms  uint16 = 13
+	ExtensionALPN                 uint16 = 16
+	ExtensionEncryptThenMAC       uint16 = 22 // RFC 7366
+	ExtensionExtendedMasterSecret uint16 = 23 // RFC 7627
+	ExtensionSessionTicket        uint16 = 35 // RFC 5077
+	ExtensionSupportedVersions    uint16 = 43
+	ExtensionPSKKeyExchangeModes  uint16 = 45
+	ExtensionKeyShare             uint16 = 51
+	ExtensionRenegotiationInfo    uint16 = 0xff01 // RFC 5746
+
+	CipherEmptyRenegotiationInfoSCSV uint16 = 0x00ff
+	CipherFallbackSCSV               uint16 = 0x5600 // RFC 7507
+
+	AlertInappropriateFallback uint8 = 86
+	AlertNoRenegotiation       uint8 = 100
+
+	groupX25519    uint16 = 29
+	groupSECP256R1 uint16 = 23
+	groupSECP384R1 uint16 = 24
+
+	maxHandshakeBytes = 1 << 16
+)
+
+var (
+	ErrNotServerHello   = errors.New("server did not answer with a ServerHello")
+	ErrMalformedMessage = errors.New("malformed TLS handshake message")
+)
+
+// HandshakeAlertError is returned when the server answers the ClientHello with an alert.
+type HandshakeAlertError struct {
+	Level       uint8
+	Description uint8
+}
+
+func (e HandshakeAlertError) Error() string {
+	return fmt.Sprintf("server sent alert %d (level %d)", e.Description, e.Level)
+}
+
+var defaultSignatureAlgorithms = []uint16{
+	0x0403, 0x0503, 0x0603, // ecdsa_secp{256,384,521}r1_sha{256,384,512}
+	0x0804, 0x0805, 0x0806, // rsa_pss_rsae_sha{256,384,512}
+	0x0401, 0x0501, 0x0601, // rsa_pkcs1_sha{256,384,512}
+	0x0201, 0x0203, // rsa_pkcs1_sha1, ecdsa_sha1
+}
+
+// DefaultProbeCipherSuites is offered by raw probes, broad enough for servers to pick their preference.
+var DefaultProbeCipherSuites = append(append([]uint16{}, localtls.TLS13Ciphers...), localtls.TLS12Ciphers...)
+
+// ClientHelloSpec describes a hand crafted ClientHello. Unlike crypto/tls it allows sending the
+// extensions and signalling cipher suite values needed to probe protocol features.
+type ClientHelloSpec struct {
+	ServerName        string
+	Version           uint16   // legacy_version, the highest version offered without supported_versions
+	CipherSuites      []uint16 // may contain SCSVs
+	SupportedVersions []uint16 // sends supported_versions and an X25519 key_share when it contains TLS 1.3
+	ALPN              []string
+	SessionID         []byte
+	EmptyExtensions   []uint16 // extensions sent without data, e.g. EMS, EtM, session ticket, renegotiation info
+}
+
+// ServerHello is the parsed reply, extension types are kept in the order the server sent them.
+type ServerHello struct {
+	Version           uint16 // legacy_version
+	NegotiatedVersion uint16 // supported_versions when present, legacy_version otherwise
+	SessionID         []byte
+	CipherSuite       uint16
+	CompressionMethod uint8
+	Extensions        []uint16
+	ExtensionData     map[uint16][]byte
+	ALPN              string
+}
+
+func (s *ServerHello) HasExtension(extension uint16) bool {
+	_, ok := s.ExtensionData[extension]
+	return ok
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendVector appends data prefixed with its length encoded in lengthBytes bytes
+func appendVector(b []byte, lengthBytes int, data []byte) []byte {
+	length := len(data)
+	for i := lengthBytes - 1; i >= 0; i-- {
+		b = append(b, byte(length>>(8*i)))
+	}
+	return append(b, data...)
+}
+
+func appendExtension(b []byte, extension uint16, data []byte) []byte {
+	b = appendUint16(b, extension)
+	return appendVector(b, 2, data)
+}
+
+func (spec ClientHelloSpec) offersTLS13() bool {
+	for _, v := range spec.SupportedVersions {
+		if v == tls.VersionTLS13 {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal returns the ClientHello wrapped in a TLS record
+func (spec ClientHelloSpec) Marshal() []byte {
+	random := make([]byte, 32)
+	rand.Read(random)
+
+	extensions := make([]byte, 0)
+	if len(spec.ServerName) > 0 && net.ParseIP(spec.ServerName) == nil {
+		serverName := appendVector([]byte{0}, 2, []byte(spec.ServerName))
+		extensions = appendExtension(extensions, ExtensionServerName, appendVector(nil, 2, serverName))
+	}
+	groups := make([]byte, 0)
+	for _, group := range []uint16{groupX25519, groupSECP256R1, groupSECP384R1} {
+		groups = appendUint16(groups, group)
+	}
+	extensions = appendExtension(extensions, ExtensionSupportedGroups, appendVector(nil, 2, groups))
+	extensions = appendExtension(extensions, ExtensionECPointFormats, []byte{1, 0})
+	signatureAlgorithms := make([]byte, 0)
+	for _, algorithm := range defaultSignatureAlgorithms {
+		signatureAlgorithms = appendUint16(signatureAlgorithms, algorithm)
+	}
+	extensions = appendExtension(extensions, ExtensionSignatureAlgorithms, appendVector(nil, 2, signatureAlgorithms))
+	if len(spec.ALPN) > 0 {
+		protocols := make([]byte, 0)
+		for _, protocol := range spec.ALPN {
+			protocols = appendVector(protocols, 1, []byte(protocol))
+		}
+		extensions = appendExtension(extensions, ExtensionALPN, appendVector(nil, 2, protocols))
+	}
+	for _, extension := range spec.EmptyExtensions {
+		data := make([]byte, 0)
+		if extension == ExtensionRenegotiationInfo {
+			data = []byte{0} // empty renegotiated_connection
+		}
+		extensions = appendExtension(extensions, extension, data)
+	}
+	if len(spec.SupportedVersions) > 0 {
+		versions := make([]byte, 0)
+		for _, version := range spec.SupportedVersions {
+			versions = appendUint16(versions, version)
+		}
+		extensions = appendExtension(extensions, ExtensionSupportedVersions, appendVector(nil, 1, versions))
+	}
+	if spec.offersTLS13() {
+		// Any 32 bytes form a valid X25519 public key, the handshake is never completed.
+		publicKey := make([]byte, 32)
+		rand.Read(publicKey)
+		keyShare := appendVector(appendUint16(nil, groupX25519), 2, publicKey)
+		extensions = appendExtension(extensions, ExtensionKeyShare, appendVector(nil, 2, keyShare))
+		extensions = appendExtension(extensions, ExtensionPSKKeyExchangeModes, []byte{1, 1})
+	}
+
+	suites := make([]byte, 0)
+	for _, suite := range spec.CipherSuites {
+		suites = appendUint16(suites, suite)
+	}
+
+	body := appendUint16(nil, spec.Version)
+	body = append(body, random...)
+	body = appendVector(body, 1, spec.SessionID)
+	body = appendVector(body, 2, suites)
+	body = appendVector(body, 1, []byte{0}) // null compression only
+	body = appendVector(body, 2, extensions)
+
+	handshake := appendVector([]byte{handshakeTypeClientHello}, 3, body)
+	// Record version stays at TLS 1.0 for compatibility with intolerant servers.
+	record := append([]byte{recordTypeHandshake}, 0x03, 0x01)
+	return appendVector(record, 2, handshake)
+}
+
+// ReadServerHello reads records until a complete ServerHello has been received.
+// An alert from the server is returned as a HandshakeAlertError.
+func ReadServerHello(r io.Reader) (*ServerHello, error) {
+	handshake := make([]byte, 0)
+	header := make([]byte, 5)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[3:5]))
+		fragment := make([]byte, length)
+		if _, err := io.ReadFull(r, fragment); err != nil {
+			return nil, err
+		}
+		switch header[0] {
+		case recordTypeAlert:
+			if len(fragment) < 2 {
+				return nil, ErrMalformedMessage
+			}
+			return nil, HandshakeAlertError{Level: fragment[0], Description: fragment[1]}
+		case recordTypeHandshake:
+			handshake = append(handshake, fragment...)
+		default:
+			return nil, ErrNotServerHello
+		}
+		if len(handshake) > maxHandshakeBytes {
+			return nil, ErrMalformedMessage
+		}
+		if len(handshake) >= 4 {
+			if handshake[0] != handshakeTypeServerHello {
+				return nil, ErrNotServerHello
+			}
+			messageLength := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+			if len(handshake) >= 4+messageLength {
+				return parseServerHello(handshake[4 : 4+messageLength])
+			}
+		}
+	}
+}
+
+// byteReader consumes big endian fields from a message, it records the first bounds error.
+type byteReader struct {
+	data []byte
+	err  error
+}
+
+func (b *byteReader) next(n int) []byte {
+	if b.err != nil || len(b.data) < n {
+		b.err = ErrMalformedMessage
+		return make([]byte, n)
+	}
+	out := b.data[:n]
+	b.data = b.data[n:]
+	return out
+}
+
+func (b *byteReader) uint8() uint8 {
+	return b.next(1)[0]
+}
+
+func (b *byteReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(b.next(2))
+}
+
+func parseServerHello(message []byte) (*ServerHello, error) {
+	reader := &byteReader{data: message}
+	hello := &ServerHello{ExtensionData: make(map[uint16][]byte), Extensions: make([]uint16, 0)}
+	hello.Version = reader.uint16()
+	reader.next(32) // random
+	hello.SessionID = append([]byte{}, reader.next(int(reader.uint8()))...)
+	hello.CipherSuite = reader.uint16()
+	hello.CompressionMethod = reader.uint8()
+	hello.NegotiatedVersion = hello.Version
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	if len(reader.data) == 0 {
+		// Extensions are optional before TLS 1.3
+		return hello, nil
+	}
+
+	extensions := &byteReader{data: reader.next(int(reader.uint16()))}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	for len(extensions.data) > 0 {
+		extension := extensions.uint16()
+		data := extensions.next(int(extensions.uint16()))
+		if extensions.err != nil {
+			return nil, extensions.err
+		}
+		hello.Extensions = append(hello.Extensions, extension)
+		hello.ExtensionData[extension] = data
+	}
+
+	if data, ok := hello.ExtensionData[ExtensionSupportedVersions]; ok && len(data) == 2 {
+		hello.NegotiatedVersion = binary.BigEndian.Uint16(data)
+	}
+	if data, ok := hello.ExtensionData[ExtensionALPN]; ok {
+		alpn := &byteReader{data: data}
+		alpn.uint16()
+		protocol := alpn.next(int(alpn.uint8()))
+		if alpn.err == nil {
+			hello.ALPN = string(protocol)
+		}
+	}
+	return hello, nil
+}
+
+// dialUpgraded returns a plaintext connection that is ready for a ClientHello, running the
+// upgrade protocol of the connection type first.
+func dialUpgraded(ip net.IP, hostname string, port string, connectionType string) (net.Conn, error) {
+	protocol, err := GetUpgradeProtocol(connectionType)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: config.TLS_CIPHER_SUITE_SECOND_TIMEOUT * time.Second}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(time.Second * config.TLS_CIPHER_SUITE_SECOND_TIMEOUT))
+	if err := protocol.Upgrade(conn, hostname); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// RawHandshake sends the ClientHello and returns the parsed ServerHello, the connection is closed afterwards.
+func RawHandshake(ip net.IP, hostname string, port string, connectionType string, spec ClientHelloSpec) (*ServerHello, error) {
+	conn, err := dialUpgraded(ip, hostname, port, connectionType)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write(spec.Marshal()); err != nil {
+		return nil, err
+	}
+	return ReadServerHello(conn)
+}
+
+// dialTLS performs a complete crypto/tls handshake after the upgrade protocol of the connection type.
+func dialTLS(ip net.IP, hostname string, port string, connectionType string, cfg *tls.Config) (*tls.Conn, error) {
+	conn, err := dialUpgraded(ip, hostname, port, connectionType)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
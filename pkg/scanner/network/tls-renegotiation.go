@@ -0,0 +1,505 @@
+package network
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"net"
+	"syscall"
+)
+
+var (
+	ErrRenegotiationVersion     = errors.New("renegotiation is only probed for TLS 1.0 to TLS 1.2")
+	ErrRenegotiationCipherSuite = errors.New("server selected a cipher suite the renegotiation probe does not implement")
+	ErrRenegotiationKeyExchange = errors.New("unsupported server certificate or key exchange")
+	ErrUnexpectedMessage        = errors.New("unexpected TLS message")
+	ErrRecordAuthentication     = errors.New("TLS record failed authentication")
+	ErrFinishedMismatch         = errors.New("server Finished does not match the handshake")
+)
+
+// renegotiationSuite describes the suites the renegotiation probe can complete a handshake with,
+// AES-128-GCM with the SHA-256 PRF or AES-128-CBC with HMAC-SHA1.
+type renegotiationSuite struct {
+	ecdhe bool
+	aead  bool
+}
+
+var renegotiationSuites = map[uint16]renegotiationSuite{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: {ecdhe: true, aead: true},
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   {ecdhe: true, aead: true},
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         {aead: true},
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    {ecdhe: true},
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      {ecdhe: true},
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            {},
+}
+
+// renegotiationCipherSuites is the preference order, GCM suites are only offered with TLS 1.2.
+var renegotiationCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+}
+
+var renegotiationCurves = map[uint16]ecdh.Curve{
+	groupX25519:    ecdh.X25519(),
+	groupSECP256R1: ecdh.P256(),
+	groupSECP384R1: ecdh.P384(),
+}
+
+// ProbeInsecureRenegotiation completes a handshake at version without RFC 5746, neither the
+// renegotiation_info extension nor the SCSV is sent, then sends a second ClientHello on the
+// established session. The server allows insecure client-initiated renegotiation when it answers
+// with a ServerHello, an alert or a closed connection is a refusal.
+// The key exchange signature is not verified, the probe only needs a session the server accepts.
+func ProbeInsecureRenegotiation(ip net.IP, hostname string, port string, connectionType string, version uint16) (bool, error) {
+	if version < tls.VersionTLS10 || version > tls.VersionTLS12 {
+		return false, ErrRenegotiationVersion
+	}
+	conn, err := dialUpgraded(ip, hostname, port, connectionType)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	spec := ClientHelloSpec{ServerName: hostname, Version: version}
+	for _, suite := range renegotiationCipherSuites {
+		if !renegotiationSuites[suite].aead || version == tls.VersionTLS12 {
+			spec.CipherSuites = append(spec.CipherSuites, suite)
+		}
+	}
+	session, err := establishSession(conn, spec)
+	if err != nil {
+		return false, err
+	}
+
+	// The renegotiating ClientHello is protected by the session keys, the record header is stripped.
+	if err := session.writeRecord(recordTypeHandshake, spec.Marshal()[5:]); err != nil {
+		return false, err
+	}
+	for {
+		recordType, fragment, err := session.readRecord()
+		var alert HandshakeAlertError
+		switch {
+		case errors.As(err, &alert):
+			// usually a no_renegotiation warning, some servers send a fatal handshake_failure
+			return false, nil
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET):
+			return false, nil
+		case err != nil:
+			return false, err
+		case recordType == recordTypeHandshake:
+			if len(fragment) == 0 || fragment[0] != handshakeTypeServerHello {
+				return false, ErrNotServerHello
+			}
+			return true, nil
+		}
+		// Application data sent before the answer is skipped
+	}
+}
+
+// establishSession runs a full handshake with the ClientHello of spec and returns the record layer
+// with the session keys in use in both directions.
+func establishSession(conn net.Conn, spec ClientHelloSpec) (*recordConn, error) {
+	record := spec.Marshal()
+	if _, err := conn.Write(record); err != nil {
+		return nil, err
+	}
+	clientHello := record[5:]
+	clientRandom := append([]byte{}, clientHello[6:38]...)
+	transcript := append([]byte{}, clientHello...)
+	session := &recordConn{conn: conn}
+
+	message, err := session.readHandshake()
+	if err != nil {
+		return nil, err
+	}
+	if message[0] != handshakeTypeServerHello {
+		return nil, ErrNotServerHello
+	}
+	hello, err := parseServerHello(message[4:])
+	if err != nil {
+		return nil, err
+	}
+	if hello.Version < tls.VersionTLS10 || hello.Version > spec.Version {
+		return nil, ErrRenegotiationVersion
+	}
+	suite, ok := renegotiationSuites[hello.CipherSuite]
+	if !ok || (suite.aead && hello.Version != tls.VersionTLS12) {
+		return nil, ErrRenegotiationCipherSuite
+	}
+	session.version = hello.Version
+	serverRandom := append([]byte{}, message[6:38]...)
+	transcript = append(transcript, message...)
+
+	var certificate *x509.Certificate
+	var serverKeyExchange []byte
+	certificateRequested := false
+	for done := false; !done; {
+		message, err := session.readHandshake()
+		if err != nil {
+			return nil, err
+		}
+		transcript = append(transcript, message...)
+		switch message[0] {
+		case handshakeTypeCertificate:
+			if certificate, err = parseLeafCertificate(message[4:]); err != nil {
+				return nil, err
+			}
+		case handshakeTypeServerKeyExchange:
+			serverKeyExchange = message[4:]
+		case handshakeTypeCertificateRequest:
+			certificateRequested = true
+		case handshakeTypeServerHelloDone:
+			done = true
+		default:
+			return nil, ErrUnexpectedMessage
+		}
+	}
+
+	preMasterSecret, clientKeyExchange, err := clientKeyExchange(suite, spec.Version, certificate, serverKeyExchange)
+	if err != nil {
+		return nil, err
+	}
+	flight := make([]byte, 0)
+	if certificateRequested {
+		// an empty certificate_list, servers requiring client authentication fail the handshake
+		flight = appendVector([]byte{handshakeTypeCertificate}, 3, []byte{0, 0, 0})
+	}
+	flight = append(flight, appendVector([]byte{handshakeTypeClientKeyExchange}, 3, clientKeyExchange)...)
+	transcript = append(transcript, flight...)
+	if err := session.writeRecord(recordTypeHandshake, flight); err != nil {
+		return nil, err
+	}
+
+	masterSecret := prf(session.version, preMasterSecret, "master secret", append(append([]byte{}, clientRandom...), serverRandom...), 48)
+	macLength, ivLength := sha1.Size, aes.BlockSize
+	if suite.aead {
+		macLength, ivLength = 0, 4 // implicit part of the GCM nonce
+	}
+	keyBlock := prf(session.version, masterSecret, "key expansion", append(append([]byte{}, serverRandom...), clientRandom...), 2*(macLength+16+ivLength))
+	take := func(n int) []byte {
+		out := keyBlock[:n]
+		keyBlock = keyBlock[n:]
+		return out
+	}
+	clientMAC, serverMAC := take(macLength), take(macLength)
+	clientKey, serverKey := take(16), take(16)
+	clientIV, serverIV := take(ivLength), take(ivLength)
+
+	if err := session.writeRecord(recordTypeChangeCipherSpec, []byte{1}); err != nil {
+		return nil, err
+	}
+	session.out = newRecordCipher(suite, clientMAC, clientKey, clientIV)
+	finished := appendVector([]byte{handshakeTypeFinished}, 3, prf(session.version, masterSecret, "client finished", finishedHash(session.version, transcript), 12))
+	transcript = append(transcript, finished...)
+	if err := session.writeRecord(recordTypeHandshake, finished); err != nil {
+		return nil, err
+	}
+
+	recordType, _, err := session.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	if recordType != recordTypeChangeCipherSpec {
+		return nil, ErrUnexpectedMessage
+	}
+	session.in = newRecordCipher(suite, serverMAC, serverKey, serverIV)
+	message, err = session.readHandshake()
+	if err != nil {
+		return nil, err
+	}
+	expected := prf(session.version, masterSecret, "server finished", finishedHash(session.version, transcript), 12)
+	if message[0] != handshakeTypeFinished || !hmac.Equal(message[4:], expected) {
+		return nil, ErrFinishedMismatch
+	}
+	return session, nil
+}
+
+func (b *byteReader) uint24() int {
+	data := b.next(3)
+	return int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+}
+
+// parseLeafCertificate returns the first certificate of a Certificate message
+func parseLeafCertificate(message []byte) (*x509.Certificate, error) {
+	reader := &byteReader{data: message}
+	reader.uint24() // certificate_list length
+	der := reader.next(reader.uint24())
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// clientKeyExchange returns the pre-master secret and the body of the ClientKeyExchange message,
+// RSA encrypts the secret to the certificate, ECDHE answers the named curve of the ServerKeyExchange.
+func clientKeyExchange(suite renegotiationSuite, clientVersion uint16, certificate *x509.Certificate, serverKeyExchange []byte) ([]byte, []byte, error) {
+	if !suite.ecdhe {
+		if certificate == nil {
+			return nil, nil, ErrRenegotiationKeyExchange
+		}
+		publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, nil, ErrRenegotiationKeyExchange
+		}
+		preMasterSecret := make([]byte, 48)
+		binary.BigEndian.PutUint16(preMasterSecret, clientVersion)
+		rand.Read(preMasterSecret[2:])
+		encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, preMasterSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		return preMasterSecret, appendVector(nil, 2, encrypted), nil
+	}
+
+	// ServerECDHParams, the signature that follows is not verified
+	reader := &byteReader{data: serverKeyExchange}
+	curveType := reader.uint8()
+	curve, ok := renegotiationCurves[reader.uint16()]
+	serverPublicKey := reader.next(int(reader.uint8()))
+	if reader.err != nil || curveType != 3 || !ok {
+		return nil, nil, ErrRenegotiationKeyExchange
+	}
+	peerKey, err := curve.NewPublicKey(serverPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	preMasterSecret, err := privateKey.ECDH(peerKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return preMasterSecret, appendVector(nil, 1, privateKey.PublicKey().Bytes()), nil
+}
+
+// pHash is P_hash of RFC 5246 section 5
+func pHash(result []byte, secret []byte, seed []byte, h func() hash.Hash) {
+	mac := hmac.New(h, secret)
+	mac.Write(seed)
+	a := mac.Sum(nil)
+	for j := 0; j < len(result); {
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(seed)
+		j += copy(result[j:], mac.Sum(nil))
+		mac.Reset()
+		mac.Write(a)
+		a = mac.Sum(nil)
+	}
+}
+
+// prf is the SHA-256 PRF of TLS 1.2, or the MD5 and SHA-1 PRF of TLS 1.0 and 1.1 (RFC 2246 section 5)
+func prf(version uint16, secret []byte, label string, seed []byte, length int) []byte {
+	labelAndSeed := append([]byte(label), seed...)
+	result := make([]byte, length)
+	if version >= tls.VersionTLS12 {
+		pHash(result, secret, labelAndSeed, sha256.New)
+		return result
+	}
+	half := (len(secret) + 1) / 2
+	pHash(result, secret[:half], labelAndSeed, md5.New)
+	sha1Result := make([]byte, length)
+	pHash(sha1Result, secret[len(secret)-half:], labelAndSeed, sha1.New)
+	for i := range result {
+		result[i] ^= sha1Result[i]
+	}
+	return result
+}
+
+// finishedHash digests the handshake messages for the verify_data of a Finished message
+func finishedHash(version uint16, transcript []byte) []byte {
+	if version >= tls.VersionTLS12 {
+		sum := sha256.Sum256(transcript)
+		return sum[:]
+	}
+	md5Sum := md5.Sum(transcript)
+	sha1Sum := sha1.Sum(transcript)
+	return append(md5Sum[:], sha1Sum[:]...)
+}
+
+// recordCipher protects the records of one direction, with AES-GCM or with AES-CBC and HMAC-SHA1.
+type recordCipher struct {
+	aead    cipher.AEAD
+	fixedIV []byte
+	block   cipher.Block
+	iv      []byte // TLS 1.0 chains the IV from the previous record
+	mac     hash.Hash
+	seq     uint64
+}
+
+func newRecordCipher(suite renegotiationSuite, macKey []byte, key []byte, iv []byte) *recordCipher {
+	block, _ := aes.NewCipher(key) // the key block always yields 16 byte keys
+	if suite.aead {
+		aead, _ := cipher.NewGCM(block)
+		return &recordCipher{aead: aead, fixedIV: iv}
+	}
+	return &recordCipher{block: block, iv: iv, mac: hmac.New(sha1.New, macKey)}
+}
+
+// additionalData is the sequence number and record header covered by the MAC or the AEAD tag
+func (c *recordCipher) additionalData(recordType uint8, version uint16, length int) []byte {
+	data := binary.BigEndian.AppendUint64(nil, c.seq)
+	data = append(data, recordType)
+	data = appendUint16(data, version)
+	return appendUint16(data, uint16(length))
+}
+
+func (c *recordCipher) seal(recordType uint8, version uint16, payload []byte) []byte {
+	additionalData := c.additionalData(recordType, version, len(payload))
+	c.seq++
+	if c.aead != nil {
+		explicitNonce := additionalData[:8]
+		nonce := append(append([]byte{}, c.fixedIV...), explicitNonce...)
+		return c.aead.Seal(append([]byte{}, explicitNonce...), nonce, payload, additionalData)
+	}
+
+	c.mac.Reset()
+	c.mac.Write(additionalData)
+	c.mac.Write(payload)
+	data := c.mac.Sum(append([]byte{}, payload...))
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	for i := 0; i < padding; i++ {
+		data = append(data, byte(padding-1))
+	}
+	out := make([]byte, 0, aes.BlockSize+len(data))
+	iv := c.iv
+	if version > tls.VersionTLS10 {
+		iv = make([]byte, aes.BlockSize)
+		rand.Read(iv)
+		out = append(out, iv...)
+	}
+	ciphertext := make([]byte, len(data))
+	cipher.NewCBCEncrypter(c.block, iv).CryptBlocks(ciphertext, data)
+	c.iv = ciphertext[len(ciphertext)-aes.BlockSize:]
+	return append(out, ciphertext...)
+}
+
+func (c *recordCipher) open(recordType uint8, version uint16, fragment []byte) ([]byte, error) {
+	if c.aead != nil {
+		if len(fragment) < 8+c.aead.Overhead() {
+			return nil, ErrRecordAuthentication
+		}
+		nonce := append(append([]byte{}, c.fixedIV...), fragment[:8]...)
+		additionalData := c.additionalData(recordType, version, len(fragment)-8-c.aead.Overhead())
+		c.seq++
+		payload, err := c.aead.Open(nil, nonce, fragment[8:], additionalData)
+		if err != nil {
+			return nil, ErrRecordAuthentication
+		}
+		return payload, nil
+	}
+
+	iv := c.iv
+	if version > tls.VersionTLS10 {
+		if len(fragment) < aes.BlockSize {
+			return nil, ErrRecordAuthentication
+		}
+		iv, fragment = fragment[:aes.BlockSize], fragment[aes.BlockSize:]
+	}
+	if len(fragment) == 0 || len(fragment)%aes.BlockSize != 0 {
+		return nil, ErrRecordAuthentication
+	}
+	data := make([]byte, len(fragment))
+	cipher.NewCBCDecrypter(c.block, iv).CryptBlocks(data, fragment)
+	c.iv = append([]byte{}, fragment[len(fragment)-aes.BlockSize:]...)
+	padding := int(data[len(data)-1]) + 1
+	if padding+c.mac.Size() > len(data) {
+		return nil, ErrRecordAuthentication
+	}
+	data = data[:len(data)-padding]
+	payload, mac := data[:len(data)-c.mac.Size()], data[len(data)-c.mac.Size():]
+	c.mac.Reset()
+	c.mac.Write(c.additionalData(recordType, version, len(payload)))
+	c.mac.Write(payload)
+	c.seq++
+	if !hmac.Equal(c.mac.Sum(nil), mac) {
+		return nil, ErrRecordAuthentication
+	}
+	return payload, nil
+}
+
+// recordConn is the TLS 1.0 to 1.2 record layer of the renegotiation probe, records are protected
+// once the cipher of a direction is set.
+type recordConn struct {
+	conn      net.Conn
+	version   uint16
+	in, out   *recordCipher
+	handshake []byte // received handshake bytes not returned yet
+}
+
+func (r *recordConn) writeRecord(recordType uint8, payload []byte) error {
+	if r.out != nil {
+		payload = r.out.seal(recordType, r.version, payload)
+	}
+	record := appendUint16([]byte{recordType}, r.version)
+	_, err := r.conn.Write(appendVector(record, 2, payload))
+	return err
+}
+
+// readRecord returns the next record, an alert from the server is returned as a HandshakeAlertError.
+func (r *recordConn) readRecord() (uint8, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r.conn, header); err != nil {
+		return 0, nil, err
+	}
+	fragment := make([]byte, binary.BigEndian.Uint16(header[3:5]))
+	if _, err := io.ReadFull(r.conn, fragment); err != nil {
+		return 0, nil, err
+	}
+	if r.in != nil {
+		var err error
+		if fragment, err = r.in.open(header[0], r.version, fragment); err != nil {
+			return 0, nil, err
+		}
+	}
+	if header[0] == recordTypeAlert {
+		if len(fragment) < 2 {
+			return 0, nil, ErrMalformedMessage
+		}
+		return header[0], fragment, HandshakeAlertError{Level: fragment[0], Description: fragment[1]}
+	}
+	return header[0], fragment, nil
+}
+
+// readHandshake returns the next handshake message including its header
+func (r *recordConn) readHandshake() ([]byte, error) {
+	for {
+		if len(r.handshake) >= 4 {
+			length := 4 + (int(r.handshake[1])<<16 | int(r.handshake[2])<<8 | int(r.handshake[3]))
+			if length > maxHandshakeBytes {
+				return nil, ErrMalformedMessage
+			}
+			if len(r.handshake) >= length {
+				message := r.handshake[:length]
+				r.handshake = r.handshake[length:]
+				return message, nil
+			}
+		}
+		recordType, fragment, err := r.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if recordType != recordTypeHandshake {
+			return nil, ErrUnexpectedMessage
+		}
+		r.handshake = append(r.handshake, fragment...)
+	}
+}
@@ -0,0 +1,315 @@
+package network
+
+import (
+	"Scanner/pkg/config"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+var (
+	ErrUnknownProtocol  = errors.New("unknown upgrade protocol")
+	ErrUpgradeRefused   = errors.New("server refused the TLS upgrade")
+	ErrUpgradeMalformed = errors.New("malformed upgrade response")
+)
+
+// UpgradeProtocol prepares a freshly dialed connection for a ClientHello. Implicit TLS protocols
+// return immediately, STARTTLS protocols run the plaintext exchange that makes the server expect TLS.
+// Implementations must not read past the server's last plaintext response.
+type UpgradeProtocol interface {
+	Name() string
+	DefaultPort() string
+	Upgrade(conn net.Conn, hostname string) error
+}
+
+// upgradeProtocols is keyed by TLSRequest.Type
+var upgradeProtocols = map[string]UpgradeProtocol{}
+
+func RegisterUpgradeProtocol(protocol UpgradeProtocol) {
+	upgradeProtocols[protocol.Name()] = protocol
+}
+
+// GetUpgradeProtocol looks up the protocol of a TLSRequest type, names are case-insensitive.
+func GetUpgradeProtocol(connectionType string) (UpgradeProtocol, error) {
+	protocol, ok := upgradeProtocols[strings.ToUpper(connectionType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProtocol, connectionType)
+	}
+	return protocol, nil
+}
+
+func init() {
+	RegisterUpgradeProtocol(ImplicitTLS{ProtocolName: "TLS", Port: config.DefaultTLSPort})
+	RegisterUpgradeProtocol(ImplicitTLS{ProtocolName: "SMTPS", Port: "465"})
+	RegisterUpgradeProtocol(ImplicitTLS{ProtocolName: "IMAPS", Port: "993"})
+	RegisterUpgradeProtocol(ImplicitTLS{ProtocolName: "POP3S", Port: "995"})
+	RegisterUpgradeProtocol(ImplicitTLS{ProtocolName: "LDAPS", Port: "636"})
+	RegisterUpgradeProtocol(SMTPStartTLS{})
+	RegisterUpgradeProtocol(IMAPStartTLS{})
+	RegisterUpgradeProtocol(POP3StartTLS{})
+	RegisterUpgradeProtocol(FTPAuthTLS{})
+	RegisterUpgradeProtocol(XMPPStartTLS{})
+	RegisterUpgradeProtocol(LDAPStartTLS{})
+	RegisterUpgradeProtocol(PostgresSSLRequest{})
+}
+
+// ImplicitTLS speaks TLS from the first byte
+type ImplicitTLS struct {
+	ProtocolName string
+	Port         string
+}
+
+func (p ImplicitTLS) Name() string        { return p.ProtocolName }
+func (p ImplicitTLS) DefaultPort() string { return p.Port }
+func (p ImplicitTLS) Upgrade(net.Conn, string) error {
+	return nil
+}
+
+// SMTPStartTLS (RFC 3207) also covers submission on 587
+type SMTPStartTLS struct{}
+
+func (SMTPStartTLS) Name() string        { return "SMTP" }
+func (SMTPStartTLS) DefaultPort() string { return "25" }
+func (SMTPStartTLS) Upgrade(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+	if err := text.PrintfLine("EHLO %s", config.SMTPHELO_Introduction); err != nil {
+		return err
+	}
+	if _, _, err := text.ReadResponse(250); err != nil {
+		return err
+	}
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	_, _, err := text.ReadResponse(220)
+	return err
+}
+
+// IMAPStartTLS (RFC 3501 section 6.2.1)
+type IMAPStartTLS struct{}
+
+func (IMAPStartTLS) Name() string        { return "IMAP" }
+func (IMAPStartTLS) DefaultPort() string { return "143" }
+func (IMAPStartTLS) Upgrade(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("%w: %s", ErrUpgradeRefused, greeting)
+	}
+	if err := text.PrintfLine("a001 STARTTLS"); err != nil {
+		return err
+	}
+	// Skip untagged responses until the tagged completion
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "a001 ") {
+			if !strings.HasPrefix(strings.ToUpper(line), "A001 OK") {
+				return fmt.Errorf("%w: %s", ErrUpgradeRefused, line)
+			}
+			return nil
+		}
+	}
+}
+
+// POP3StartTLS (RFC 2595 section 4)
+type POP3StartTLS struct{}
+
+func (POP3StartTLS) Name() string        { return "POP3" }
+func (POP3StartTLS) DefaultPort() string { return "110" }
+func (POP3StartTLS) Upgrade(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+	for _, command := range []string{"", "STLS"} {
+		if len(command) > 0 {
+			if err := text.PrintfLine(command); err != nil {
+				return err
+			}
+		}
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "+OK") {
+			return fmt.Errorf("%w: %s", ErrUpgradeRefused, line)
+		}
+	}
+	return nil
+}
+
+// FTPAuthTLS (RFC 4217)
+type FTPAuthTLS struct{}
+
+func (FTPAuthTLS) Name() string        { return "FTP" }
+func (FTPAuthTLS) DefaultPort() string { return "21" }
+func (FTPAuthTLS) Upgrade(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+	if err := text.PrintfLine("AUTH TLS"); err != nil {
+		return err
+	}
+	_, _, err := text.ReadResponse(234)
+	return err
+}
+
+// XMPPStartTLS (RFC 6120 section 5), client to server streams
+type XMPPStartTLS struct{}
+
+const xmppStreamHeader = "<?xml version='1.0'?><stream:stream to='%s' version='1.0' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'>"
+
+func (XMPPStartTLS) Name() string        { return "XMPP" }
+func (XMPPStartTLS) DefaultPort() string { return "5222" }
+func (XMPPStartTLS) Upgrade(conn net.Conn, hostname string) error {
+	if _, err := fmt.Fprintf(conn, xmppStreamHeader, hostname); err != nil {
+		return err
+	}
+	features, err := readXMPPUntil(conn, "</stream:features>")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(features, "urn:ietf:params:xml:ns:xmpp-tls") {
+		return fmt.Errorf("%w: starttls not offered", ErrUpgradeRefused)
+	}
+	if _, err := io.WriteString(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+	response, err := readXMPPUntil(conn, ">")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(response, "<proceed") {
+		return fmt.Errorf("%w: %s", ErrUpgradeRefused, response)
+	}
+	return nil
+}
+
+// readXMPPUntil reads byte by byte so nothing past the terminator is consumed.
+func readXMPPUntil(conn net.Conn, terminator string) (string, error) {
+	var builder strings.Builder
+	b := make([]byte, 1)
+	for builder.Len() < 1<<16 {
+		if _, err := conn.Read(b); err != nil {
+			return builder.String(), err
+		}
+		builder.WriteByte(b[0])
+		if strings.HasSuffix(builder.String(), terminator) {
+			return builder.String(), nil
+		}
+	}
+	return builder.String(), ErrUpgradeMalformed
+}
+
+// LDAPStartTLS sends the StartTLS extended operation (RFC 4511 section 4.14)
+type LDAPStartTLS struct{}
+
+const ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+func (LDAPStartTLS) Name() string        { return "LDAP" }
+func (LDAPStartTLS) DefaultPort() string { return "389" }
+func (LDAPStartTLS) Upgrade(conn net.Conn, _ string) error {
+	// LDAPMessage { messageID 1, extendedReq [APPLICATION 23] { requestName [0] OID } }
+	request := append([]byte{0x80, byte(len(ldapStartTLSOID))}, ldapStartTLSOID...)
+	request = append([]byte{0x77, byte(len(request))}, request...)
+	request = append([]byte{0x02, 0x01, 0x01}, request...)
+	request = append([]byte{0x30, byte(len(request))}, request...)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	message, err := readBERElement(bufio.NewReaderSize(conn, 16), 0x30)
+	if err != nil {
+		return err
+	}
+	// messageID, then extendedResp [APPLICATION 24] whose first element is the resultCode
+	reader := bufio.NewReader(bytes.NewReader(message))
+	if _, err := readBERElement(reader, 0x02); err != nil {
+		return err
+	}
+	response, err := readBERElement(reader, 0x78)
+	if err != nil {
+		return err
+	}
+	resultCode, err := readBERElement(bufio.NewReader(bytes.NewReader(response)), 0x0a)
+	if err != nil {
+		return err
+	}
+	if len(resultCode) != 1 || resultCode[0] != 0 {
+		return fmt.Errorf("%w: LDAP result code %v", ErrUpgradeRefused, resultCode)
+	}
+	return nil
+}
+
+// readBERElement reads one element with the expected tag and returns its contents.
+// The LDAP response is read through a small buffer, the server sends nothing after it.
+func readBERElement(reader *bufio.Reader, tag byte) ([]byte, error) {
+	actualTag, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if actualTag != tag {
+		return nil, fmt.Errorf("%w: tag %#x, expected %#x", ErrUpgradeMalformed, actualTag, tag)
+	}
+	lengthByte, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length := int(lengthByte)
+	if lengthByte&0x80 != 0 {
+		count := int(lengthByte & 0x7f)
+		if count == 0 || count > 3 {
+			return nil, ErrUpgradeMalformed
+		}
+		length = 0
+		for i := 0; i < count; i++ {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	contents := make([]byte, length)
+	if _, err := io.ReadFull(reader, contents); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// PostgresSSLRequest sends the SSLRequest startup packet, the server answers a single 'S' or 'N'
+type PostgresSSLRequest struct{}
+
+const postgresSSLRequestCode = 80877103
+
+func (PostgresSSLRequest) Name() string        { return "POSTGRES" }
+func (PostgresSSLRequest) DefaultPort() string { return "5432" }
+func (PostgresSSLRequest) Upgrade(conn net.Conn, _ string) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return err
+	}
+	if response[0] != 'S' {
+		return fmt.Errorf("%w: SSLRequest answered %q", ErrUpgradeRefused, response[0])
+	}
+	return nil
+}
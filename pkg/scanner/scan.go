@@ -1,18 +1,34 @@
 package scanner
 
 import (
-	"Scanner/pkg/config"
 	"Scanner/pkg/scanner/network"
 	"Scanner/pkg/scanner/structs"
 	"net"
 	"strconv"
 )
 
-func PerformTLSScan(request structs.Request) (structs.TLSCombinedRecord, error) {
+func PerformTLSScan(request structs.Request, options network.TLSScanOptions) (structs.TLSCombinedRecord, error) {
 	hostname := request.Hostname
-	ipAddressesResolved, err := network.ResolveIPAddresses(hostname)
 	response := structs.TLSCombinedRecord{}
 
+	protocolName := request.Protocol
+	if len(protocolName) == 0 {
+		protocolName = "TLS"
+	}
+	protocol, err := network.GetUpgradeProtocol(protocolName)
+	if err != nil {
+		return response, err
+	}
+	port := request.Port
+	if len(port) == 0 {
+		port = protocol.DefaultPort()
+	}
+	mode := structs.ModeSTARTTLS
+	if _, ok := protocol.(network.ImplicitTLS); ok {
+		mode = structs.ModeImplicitTLS
+	}
+
+	ipAddressesResolved, err := network.ResolveIPAddresses(hostname)
 	if err != nil {
 		return response, err
 	}
@@ -33,8 +49,10 @@ func PerformTLSScan(request structs.Request) (structs.TLSCombinedRecord, error)
 		FilteredIPAddresses:  filteredIPAddresses,
 		ResolvedIPAddresses:  ipAddressesResolved,
 		Hostname:             hostname,
-		Port:                 config.DefaultTLSPort,
-		Type:                 "TLS",
+		Port:                 port,
+		Type:                 protocol.Name(),
+		Mode:                 mode,
+		Options:              options,
 	}
 
 	records, _ := tlsTask.ParallelIPScan()
@@ -46,40 +64,101 @@ func PerformMailScan(mailHostsToIPs map[string][]net.IP,
 	resolvedHostToIPs map[string][]net.IP,
 	filteredHostsToIPs map[string][]net.IP,
 	cachedMXs map[string]struct{},
+	options network.TLSScanOptions,
 	MXSpecificDataOut chan<- map[string]structs.MXSpecificData) {
 
 	smtpTasks := make([]network.TLSRequest, 0)
-	bannerMetadataTask := make([]string, 0)
+	bannerMetadataTasks := make([]network.SMTPMetadataRequest, 0)
+	mxEndpoints := make(map[string][]structs.MailEndpointRecord) // mx : endpoints of every IP and port
 	for host, ipList := range mailHostsToIPs {
 		// Don't rescan cached MXs
 		if _, ok := cachedMXs[host]; ok {
 			continue
 		}
-		openPorts := network.PerformGreedyPortScan(ipList)
-		for _, port := range openPorts {
+		endpoints := network.DetectMailEndpoints(host, ipList)
+		mxEndpoints[host] = endpoints
+
+		// One TLS scan per port covering the IPs the port is open on, each IP scanned in the mode it accepted
+		portIPs := make(map[int][]net.IP)
+		portModes := make(map[int]string)
+		portIPTypes := make(map[int]map[string]string) // port : ip : upgrade protocol name
+		for _, endpoint := range endpoints {
+			if !endpoint.Open {
+				continue
+			}
+			portIPs[endpoint.Port] = append(portIPs[endpoint.Port], net.ParseIP(endpoint.IP))
+			if _, ok := portModes[endpoint.Port]; !ok || portModes[endpoint.Port] == structs.ModeUnknown {
+				portModes[endpoint.Port] = endpoint.Mode
+			}
+			if _, ok := portIPTypes[endpoint.Port]; !ok {
+				portIPTypes[endpoint.Port] = make(map[string]string)
+			}
+			portIPTypes[endpoint.Port][endpoint.IP] = network.ConnectionTypeForMode(scanMode(endpoint.Mode, endpoint.Port))
+		}
+		for port, ips := range portIPs {
+			mode := portModes[port]
 			smtpTLSTask := network.TLSRequest{
-				ScannableIPAddresses: ipList,
+				ScannableIPAddresses: ips,
 				FilteredIPAddresses:  filteredHostsToIPs[host],
 				ResolvedIPAddresses:  resolvedHostToIPs[host],
 				Hostname:             host,
 				Port:                 strconv.Itoa(port),
-				Type:                 "SMTP",
+				Type:                 network.ConnectionTypeForMode(scanMode(mode, port)),
+				IPTypes:              portIPTypes[port],
+				Mode:                 mode,
+				Options:              options,
 			}
 			smtpTasks = append(smtpTasks, smtpTLSTask)
-			bannerMetadataTask = append(bannerMetadataTask, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+		for _, endpoint := range endpoints {
+			if endpoint.Open {
+				bannerMetadataTasks = append(bannerMetadataTasks, bannerMetadataRequest(endpoint))
+			}
 		}
 	}
 
-	smtpMetadata := network.ParallelMailMetadataScan(bannerMetadataTask)
+	smtpMetadata := network.ParallelMailMetadataScan(bannerMetadataTasks)
 
 	allMXSpecificData := network.ParallelHostnameScan(smtpTasks)
-	for hostPort, bannerInfo := range smtpMetadata {
-		host, _, _ := net.SplitHostPort(hostPort)
-		allMXSpecificData[host].MXMetaData[hostPort] = bannerInfo
+	// MXs without any open port have no TLS scan and are not reported, as before
+	for host, endpoints := range mxEndpoints {
+		mxData, ok := allMXSpecificData[host]
+		if !ok {
+			continue
+		}
+		for _, endpoint := range endpoints {
+			hostPort := net.JoinHostPort(host, strconv.Itoa(endpoint.Port))
+			if endpoint.Open {
+				metadata := smtpMetadata[bannerMetadataRequest(endpoint)]
+				endpoint.Metadata = &metadata
+				// host:port metadata keeps the first IP that presented a banner
+				if existing, ok := mxData.MXMetaData[hostPort]; !ok || len(existing.Banner) == 0 {
+					mxData.MXMetaData[hostPort] = metadata
+				}
+				tlsRecord := mxData.MXTLSInformation[hostPort]
+				_, endpoint.TLSSuccess = tlsRecord.Certificates[endpoint.IP]
+				endpoint.TLSError = tlsRecord.Errors[endpoint.IP]
+			}
+			mxData.MXEndpoints[net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))] = endpoint
+		}
 	}
 	MXSpecificDataOut <- allMXSpecificData
 }
 
+// scanMode is the detected mode, or the mode the port number implies when no handshake succeeded
+func scanMode(mode string, port int) string {
+	if mode == structs.ModeUnknown {
+		return network.PreferredModes(strconv.Itoa(port))[0]
+	}
+	return mode
+}
+
+// bannerMetadataRequest reads the banner in the mode the endpoint is scanned in
+func bannerMetadataRequest(endpoint structs.MailEndpointRecord) network.SMTPMetadataRequest {
+	address := net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+	return network.SMTPMetadataRequest{Address: address, ServerName: endpoint.MX, Mode: scanMode(endpoint.Mode, endpoint.Port)}
+}
+
 func PerformDNSSECScan(request structs.DNSRequest) structs.DNSSECRecord {
 	query := network.DNSSEC{Hostname: request.Hostname, QueryType: request.QueryType, NoServer: request.NoServer}
 	return query.Query()
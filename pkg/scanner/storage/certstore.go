@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrCertificateNotFound = errors.New("certificate not found in store")
+
+// CertificateStore persists certificates as DER and PEM files in a content
+// addressed layout, keyed by the hex encoded SHA-256 fingerprint of the DER
+// bytes. A certificate seen on thousands of endpoints is written exactly once:
+//
+//	<DirectoryPath>/<fingerprint[:2]>/<fingerprint>.der
+//	<DirectoryPath>/<fingerprint[:2]>/<fingerprint>.pem
+type CertificateStore struct {
+	DirectoryPath string
+}
+
+func NewCertificateStore(dirPath string) (*CertificateStore, error) {
+	if len(strings.TrimSpace(dirPath)) == 0 {
+		return nil, errors.New("certificate store path cannot be empty")
+	}
+	if err := CreateDirectoryIfNotExists(dirPath); err != nil {
+		return nil, err
+	}
+	return &CertificateStore{DirectoryPath: dirPath}, nil
+}
+
+// CertificateFingerprint returns the hex encoded SHA-256 fingerprint used as the store key.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+func (s *CertificateStore) getFilePath(fingerprint string, extension string) string {
+	return filepath.Join(s.DirectoryPath, fingerprint[:2], fmt.Sprintf("%s.%s", fingerprint, extension))
+}
+
+// Contains reports whether the certificate with the given fingerprint has already been persisted.
+func (s *CertificateStore) Contains(fingerprint string) bool {
+	if len(fingerprint) < 2 {
+		return false
+	}
+	_, err := os.Stat(s.getFilePath(fingerprint, ExtensionDER))
+	return err == nil
+}
+
+// Put persists the certificate if it is not already present and returns its fingerprint.
+func (s *CertificateStore) Put(cert *x509.Certificate) (string, error) {
+	fingerprint := CertificateFingerprint(cert)
+	if s.Contains(fingerprint) {
+		return fingerprint, nil
+	}
+	if err := CreateDirectoryIfNotExists(filepath.Join(s.DirectoryPath, fingerprint[:2])); err != nil {
+		return fingerprint, err
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := writeFileAtomic(s.getFilePath(fingerprint, ExtensionPEM), pemBytes); err != nil {
+		return fingerprint, err
+	}
+	// DER is written last since its existence marks the entry as complete.
+	if err := writeFileAtomic(s.getFilePath(fingerprint, ExtensionDER), cert.Raw); err != nil {
+		return fingerprint, err
+	}
+	return fingerprint, nil
+}
+
+// PutChain persists every certificate of the chain and returns the fingerprints in chain order.
+func (s *CertificateStore) PutChain(chain []*x509.Certificate) ([]string, error) {
+	fingerprints := make([]string, 0, len(chain))
+	for _, cert := range chain {
+		fingerprint, err := s.Put(cert)
+		if err != nil {
+			return fingerprints, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// Get loads and re-parses a previously persisted certificate.
+func (s *CertificateStore) Get(fingerprint string) (*x509.Certificate, error) {
+	if !s.Contains(fingerprint) {
+		return nil, ErrCertificateNotFound
+	}
+	data, err := os.ReadFile(s.getFilePath(fingerprint, ExtensionDER))
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(data)
+}
+
+// writeFileAtomic writes to a temporary file and renames it in place so that concurrent
+// scanners storing the same certificate never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
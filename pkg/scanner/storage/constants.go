@@ -8,6 +8,8 @@ const (
 
 const (
 	ExtensionJSON = "json"
+	ExtensionDER  = "der"
+	ExtensionPEM  = "pem"
 )
 
 const (
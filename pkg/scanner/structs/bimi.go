@@ -0,0 +1,48 @@
+package structs
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// BIMIRecord is the brand indicator record at default._bimi.<domain>
+type BIMIRecord struct {
+	Domain    string   `json:"domain"`
+	Found     bool     `json:"found"`
+	TXTRecord string   `json:"txtRecord"`
+	Location  string   `json:"location"`  // l=, the SVG logo
+	Authority string   `json:"authority"` // a=, the mark certificate
+	Declined  bool     `json:"declined"`  // empty l= and a=, the domain publishes no indicator
+	Errors    []string `json:"errors"`
+}
+
+// IsBIMIRecord reports whether a TXT record starts with "v=BIMI1"
+func IsBIMIRecord(txt string) bool {
+	tags, _ := parseTagList(txt)
+	return len(tags) > 0 && tags[0].Tag == "v" && tags[0].Value == "BIMI1"
+}
+
+// ParseBIMI parses a BIMI assertion record into record, which keeps its domain.
+func ParseBIMI(record BIMIRecord, txt string) BIMIRecord {
+	record.TXTRecord = txt
+	if record.Errors == nil {
+		record.Errors = make([]string, 0)
+	}
+	tags, problems := parseTagList(txt)
+	record.Errors = append(record.Errors, problems...)
+	for _, tag := range tags {
+		switch tag.Tag {
+		case "l":
+			record.Location = tag.Value
+		case "a":
+			record.Authority = tag.Value
+		default:
+			continue
+		}
+		if parsed, err := url.Parse(tag.Value); len(tag.Value) > 0 && (err != nil || parsed.Scheme != "https") {
+			record.Errors = append(record.Errors, fmt.Sprintf("%s=%q is not an https: URI", tag.Tag, tag.Value))
+		}
+	}
+	record.Declined = len(record.Location) == 0 && len(record.Authority) == 0
+	return record
+}
@@ -1,25 +1,194 @@
 package structs
 
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
 type MXSpecificData struct {
-	MXTLSInformation map[string]TLSCombinedRecord `json:"mxTLSInformation"`
-	MXMetaData       map[string]SMTPMetadata      `json:"mxMetaData"`
+	MXTLSInformation map[string]TLSCombinedRecord  `json:"mxTLSInformation"`
+	MXMetaData       map[string]SMTPMetadata       `json:"mxMetaData"`
+	MXEndpoints      map[string]MailEndpointRecord `json:"mxEndpoints"` // ip:port : endpoint
+	// Presented chains of this scan, not cached
+	CertificateChains map[string][]*x509.Certificate `json:"-"` // ip:port : chain, leaf first
 	// Unique Fingerprint
 	PortCount           int `json:"portCount"`
 	TLSVersionCount     int `json:"tlsVersionCount"`
 	TLSCipherSuiteCount int `json:"tlsCipherSuiteCount"`
 	// Cache Count
 	SeenCount int `json:"mxSeenCount"`
+	// Cache expiry
+	ScannedAt   time.Time `json:"scannedAt"`
+	MXRecordTTL uint32    `json:"mxRecordTTL"` // TTL of the MX record that pointed at this host
+}
+
+// MXPortFingerprint is what one port of an MX serves, merged over the scanned IPs
+type MXPortFingerprint struct {
+	Suites       map[uint16][]uint16 `json:"suites"`       // TLS version : sorted supported cipher suites
+	Certificates []string            `json:"certificates"` // sorted leaf SHA-256 fingerprints
+}
+
+// MXFingerprint identifies an MX scan for cache consensus, port : fingerprint
+type MXFingerprint map[string]MXPortFingerprint
+
+// Fingerprint merges the supported suites and leaf certificates of every scanned IP per port
+func (m MXSpecificData) Fingerprint() MXFingerprint {
+	suites := make(map[string]map[uint16]map[uint16]struct{}) // port : version : suite set
+	certificates := make(map[string]map[string]struct{})      // port : leaf fingerprint set
+	for hostPort, record := range m.MXTLSInformation {
+		_, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			port = hostPort
+		}
+		if _, ok := suites[port]; !ok {
+			suites[port] = make(map[uint16]map[uint16]struct{})
+			certificates[port] = make(map[string]struct{})
+		}
+		for _, versionSuites := range record.CipherSuites {
+			for _, versionSuite := range versionSuites {
+				if !versionSuite.IsSupported {
+					continue
+				}
+				if _, ok := suites[port][versionSuite.TLSVersion]; !ok {
+					suites[port][versionSuite.TLSVersion] = make(map[uint16]struct{})
+				}
+				for _, suite := range versionSuite.SupportedCipherSuites {
+					suites[port][versionSuite.TLSVersion][suite] = struct{}{}
+				}
+			}
+		}
+		for _, chain := range record.CertificateChains {
+			if len(chain) > 0 {
+				certificates[port][chain[0]] = struct{}{}
+			}
+		}
+	}
+
+	fingerprint := make(MXFingerprint)
+	for port, versions := range suites {
+		portFingerprint := MXPortFingerprint{Suites: make(map[uint16][]uint16), Certificates: make([]string, 0)}
+		for version, versionSuites := range versions {
+			sorted := make([]uint16, 0, len(versionSuites))
+			for suite := range versionSuites {
+				sorted = append(sorted, suite)
+			}
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			portFingerprint.Suites[version] = sorted
+		}
+		for certificate := range certificates[port] {
+			portFingerprint.Certificates = append(portFingerprint.Certificates, certificate)
+		}
+		sort.Strings(portFingerprint.Certificates)
+		fingerprint[port] = portFingerprint
+	}
+	return fingerprint
+}
+
+// Digest is the SHA-256 of the canonical JSON encoding, equal fingerprints have equal digests
+func (f MXFingerprint) Digest() string {
+	// json sorts map keys, the slices are already sorted
+	encoded, _ := json.Marshal(f)
+	digest := sha256.Sum256(encoded)
+	return hex.EncodeToString(digest[:])
 }
 
-// Returns negative num for less than, 0 for equal, positive num for greater than
-func (m MXSpecificData) CompareTo(o MXSpecificData) int {
-	if m.PortCount != o.PortCount {
-		return m.PortCount - o.PortCount
-	} else if m.TLSVersionCount != o.TLSVersionCount {
-		return m.TLSVersionCount - o.TLSVersionCount
-	} else if m.TLSCipherSuiteCount != o.TLSCipherSuiteCount {
-		return m.TLSCipherSuiteCount - o.TLSCipherSuiteCount
-	} else {
-		return 0
+// Differences describes how o differs from f, port by port. Empty when both are equal.
+func (f MXFingerprint) Differences(o MXFingerprint) []string {
+	differences := make([]string, 0)
+	for _, port := range fingerprintPorts(f, o) {
+		mine, inMine := f[port]
+		other, inOther := o[port]
+		if !inOther {
+			differences = append(differences, fmt.Sprintf("port %s: not scanned", port))
+			continue
+		}
+		if !inMine {
+			differences = append(differences, fmt.Sprintf("port %s: additionally scanned", port))
+			continue
+		}
+		versions := make(map[uint16]struct{})
+		for version := range mine.Suites {
+			versions[version] = struct{}{}
+		}
+		for version := range other.Suites {
+			versions[version] = struct{}{}
+		}
+		sortedVersions := make([]uint16, 0, len(versions))
+		for version := range versions {
+			sortedVersions = append(sortedVersions, version)
+		}
+		sort.Slice(sortedVersions, func(i, j int) bool { return sortedVersions[i] < sortedVersions[j] })
+		for _, version := range sortedVersions {
+			mySuites, myVersion := mine.Suites[version]
+			otherSuites, otherVersion := other.Suites[version]
+			switch {
+			case !otherVersion:
+				differences = append(differences, fmt.Sprintf("port %s: version 0x%04x not supported", port, version))
+			case !myVersion:
+				differences = append(differences, fmt.Sprintf("port %s: version 0x%04x additionally supported", port, version))
+			default:
+				missing, added := uint16SetDifference(mySuites, otherSuites), uint16SetDifference(otherSuites, mySuites)
+				if missing+added > 0 {
+					differences = append(differences, fmt.Sprintf("port %s: version 0x%04x suites differ (%d missing, %d added)", port, version, missing, added))
+				}
+			}
+		}
+		if strings.Join(mine.Certificates, ",") != strings.Join(other.Certificates, ",") {
+			differences = append(differences, fmt.Sprintf("port %s: leaf certificates differ", port))
+		}
+	}
+	return differences
+}
+
+func fingerprintPorts(fingerprints ...MXFingerprint) []string {
+	seen := make(map[string]struct{})
+	keys := make([]string, 0)
+	for _, fingerprint := range fingerprints {
+		for key := range fingerprint {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// uint16SetDifference counts the values of a missing from b, both sorted
+func uint16SetDifference(a []uint16, b []uint16) int {
+	count, j := 0, 0
+	for _, value := range a {
+		for j < len(b) && b[j] < value {
+			j++
+		}
+		if j == len(b) || b[j] != value {
+			count++
+		}
+	}
+	return count
+}
+
+// ExpiresAt is the scan time plus the MX record TTL, with the lifetime clamped to [minimum, maximum].
+// Submissions without a scan time age from received.
+func (m MXSpecificData) ExpiresAt(received time.Time, minimum time.Duration, maximum time.Duration) time.Time {
+	scannedAt := m.ScannedAt
+	if scannedAt.IsZero() || scannedAt.After(received) {
+		scannedAt = received
+	}
+	lifetime := time.Duration(m.MXRecordTTL) * time.Second
+	if lifetime < minimum {
+		lifetime = minimum
+	}
+	if lifetime > maximum {
+		lifetime = maximum
 	}
+	return scannedAt.Add(lifetime)
 }
@@ -0,0 +1,117 @@
+package structs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SMTPCapabilities is the typed form of an EHLO response
+type SMTPCapabilities struct {
+	Encrypted              bool     `json:"encrypted"` // advertised over TLS
+	StartTLS               bool     `json:"starttls"`
+	SizeAdvertised         bool     `json:"sizeAdvertised"`
+	SizeLimit              int64    `json:"sizeLimit"` // bytes, 0 means no fixed limit (RFC 1870)
+	AuthMechanisms         []string `json:"authMechanisms"`
+	PlaintextAuthBeforeTLS bool     `json:"plaintextAuthBeforeTLS"` // PLAIN or LOGIN offered without TLS
+	Pipelining             bool     `json:"pipelining"`
+	EightBitMIME           bool     `json:"8bitmime"`
+	SMTPUTF8               bool     `json:"smtputf8"`
+	Chunking               bool     `json:"chunking"`
+	RequireTLS             bool     `json:"requiretls"`
+	DSN                    bool     `json:"dsn"`
+	Keywords               []string `json:"keywords"` // every advertised keyword, upper case
+}
+
+// CapabilityChanges lists the capabilities gained and lost after STARTTLS. AUTH mechanisms are listed as "AUTH <mechanism>".
+type CapabilityChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+var plaintextAuthMechanisms = map[string]bool{"PLAIN": true, "LOGIN": true}
+
+// ParseEHLOCapabilities parses the EHLO response lines following the greeting line.
+func ParseEHLOCapabilities(lines []string, encrypted bool) SMTPCapabilities {
+	capabilities := SMTPCapabilities{Encrypted: encrypted, AuthMechanisms: make([]string, 0), Keywords: make([]string, 0)}
+	mechanisms := make(map[string]bool)
+	for _, line := range lines {
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) == 0 {
+			continue
+		}
+		keyword := strings.ToUpper(fields[0])
+		parameters := fields[1:]
+		// Some servers still advertise the pre-standard "AUTH=PLAIN LOGIN" form
+		if strings.HasPrefix(keyword, "AUTH=") {
+			parameters = append([]string{strings.TrimPrefix(keyword, "AUTH=")}, parameters...)
+			keyword = "AUTH"
+		}
+		capabilities.Keywords = append(capabilities.Keywords, keyword)
+
+		switch keyword {
+		case "STARTTLS":
+			capabilities.StartTLS = true
+		case "SIZE":
+			capabilities.SizeAdvertised = true
+			if len(parameters) > 0 {
+				capabilities.SizeLimit, _ = strconv.ParseInt(parameters[0], 10, 64)
+			}
+		case "AUTH":
+			for _, mechanism := range parameters {
+				mechanism = strings.ToUpper(mechanism)
+				if !mechanisms[mechanism] {
+					mechanisms[mechanism] = true
+					capabilities.AuthMechanisms = append(capabilities.AuthMechanisms, mechanism)
+				}
+				if !encrypted && plaintextAuthMechanisms[mechanism] {
+					capabilities.PlaintextAuthBeforeTLS = true
+				}
+			}
+		case "PIPELINING":
+			capabilities.Pipelining = true
+		case "8BITMIME":
+			capabilities.EightBitMIME = true
+		case "SMTPUTF8":
+			capabilities.SMTPUTF8 = true
+		case "CHUNKING":
+			capabilities.Chunking = true
+		case "REQUIRETLS":
+			capabilities.RequireTLS = true
+		case "DSN":
+			capabilities.DSN = true
+		}
+	}
+	return capabilities
+}
+
+func capabilityItems(capabilities SMTPCapabilities) map[string]bool {
+	items := make(map[string]bool)
+	for _, keyword := range capabilities.Keywords {
+		if keyword != "AUTH" {
+			items[keyword] = true
+		}
+	}
+	for _, mechanism := range capabilities.AuthMechanisms {
+		items["AUTH "+mechanism] = true
+	}
+	return items
+}
+
+func DiffCapabilities(before SMTPCapabilities, after SMTPCapabilities) CapabilityChanges {
+	changes := CapabilityChanges{Added: make([]string, 0), Removed: make([]string, 0)}
+	beforeItems, afterItems := capabilityItems(before), capabilityItems(after)
+	for item := range afterItems {
+		if !beforeItems[item] {
+			changes.Added = append(changes.Added, item)
+		}
+	}
+	for item := range beforeItems {
+		if !afterItems[item] {
+			changes.Removed = append(changes.Removed, item)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	return changes
+}
@@ -22,19 +22,28 @@ type CertificateRecord struct {
 	KeyUsage           []KeyUsageType         `json:"keyUsage"`
 	ExtKeyUsage        []ExtendedKeyUsageType `json:"extKeyUsage"`
 	SPKISHA256Hash     string                 `json:"spkiHash"` // Hex encoded
+	Extensions         CertificateExtensions  `json:"extensions"`
+	Revocation         *RevocationRecord      `json:"revocation"` // nil unless revocation checking is enabled
 }
 
 type ChainRecord struct {
-	Issuer             string        `json:"issuer"`
-	Fingerprint        string        `json:"sha256fingerprint"`
-	KeyType            PublicKeyType `json:"publicKeyType"`
-	KeyLength          int           `json:"publicKeyLength"`
-	SignatureAlgorithm string        `json:"signatureAlgorithm"`
-	IsCA               bool          `json:"isCA"`
+	Subject            string                `json:"subject"`
+	Issuer             string                `json:"issuer"`
+	Fingerprint        string                `json:"sha256fingerprint"`
+	KeyType            PublicKeyType         `json:"publicKeyType"`
+	KeyLength          int                   `json:"publicKeyLength"`
+	SignatureAlgorithm string                `json:"signatureAlgorithm"`
+	IsCA               bool                  `json:"isCA"`
+	Extensions         CertificateExtensions `json:"extensions"`
+	Revocation         *RevocationRecord     `json:"revocation"`
 }
 
 type EVCertInformation struct {
 	IsEVCertType        bool   `json:"isEV"`
 	ObjectIdentifier    string `json:"oid"`
 	IssuingOrganization string `json:"org"`
+	RegistrySource      string `json:"registrySource"`  // builtin, ccadb or json
+	RootVerifiable      bool   `json:"rootVerifiable"`  // registry has root fingerprints for the OID
+	RootEntitled        bool   `json:"rootEntitled"`    // verified chain ends in a root entitled to the OID
+	RootFingerprint     string `json:"rootFingerprint"` // SHA-256 of the verified root, hex encoded
 }
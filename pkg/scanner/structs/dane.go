@@ -0,0 +1,30 @@
+package structs
+
+type DANEStatus string
+
+const (
+	DANEAbsent     DANEStatus = "absent"     // no TLSA records
+	DANEInsecure   DANEStatus = "insecure"   // TLSA records without a DNSSEC signature, ignored by senders
+	DANEValid      DANEStatus = "valid"      // every scanned IP matched a usable TLSA record
+	DANEMismatched DANEStatus = "mismatched" // at least one scanned IP matched no usable TLSA record
+	DANEBogus      DANEStatus = "bogus"      // the TLSA records failed DNSSEC validation
+	DANEUnchecked  DANEStatus = "unchecked"  // validated TLSA records, but port 25 presented no certificate to compare
+	DANEError      DANEStatus = "error"      // the TLSA lookup failed, senders defer delivery
+)
+
+type DANERecord struct {
+	MX          string          `json:"mx"`
+	Name        string          `json:"name"` // _25._tcp.<mx>
+	Status      DANEStatus      `json:"status"`
+	TLSARecords []TLSARecord    `json:"tlsaRecords"`
+	IPMatches   map[string]bool `json:"ipMatches"` // ip : matched a usable TLSA record
+	Error       string          `json:"error"`
+}
+
+type TLSARecord struct {
+	Usage        uint8  `json:"usage"`
+	Selector     uint8  `json:"selector"`
+	MatchingType uint8  `json:"matchingType"`
+	Data         string `json:"data"`
+	Usable       bool   `json:"usable"` // SMTP only uses DANE-TA(2) and DANE-EE(3) (RFC 7672 section 3.1.3)
+}
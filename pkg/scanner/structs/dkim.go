@@ -0,0 +1,118 @@
+package structs
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const DKIMMinimumRSABits = 1024 // smaller keys must not be accepted (RFC 8301 section 3.2)
+
+type DKIMRecord struct {
+	Domain          string             `json:"domain"`
+	SelectorsProbed int                `json:"selectorsProbed"`
+	Keys            map[string]DKIMKey `json:"keys"` // selector : key, only selectors that publish a record
+	Errors          []string           `json:"errors"`
+}
+
+type DKIMKey struct {
+	Selector         string   `json:"selector"`
+	Name             string   `json:"name"` // <selector>._domainkey.<domain>
+	TXTRecord        string   `json:"txtRecord"`
+	KeyType          string   `json:"keyType"` // rsa or ed25519
+	KeyBits          int      `json:"keyBits"`
+	HashAlgorithms   []string `json:"hashAlgorithms"` // h=, empty allows every algorithm
+	Testing          bool     `json:"testing"`        // t=y, receivers treat signatures as unsigned mail
+	StrictSubdomains bool     `json:"strictSubdomains"`
+	Revoked          bool     `json:"revoked"` // empty p=
+	Weak             bool     `json:"weak"`    // RSA key under DKIMMinimumRSABits
+	Errors           []string `json:"errors"`
+}
+
+// IsDKIMKeyRecord reports whether a TXT record is a DKIM key record, which requires a p= tag
+func IsDKIMKeyRecord(txt string) bool {
+	tags, _ := parseTagList(txt)
+	for _, tag := range tags {
+		if tag.Tag == "p" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDKIMKey parses a DKIM key record (RFC 6376 section 3.6.1) into key, which keeps its selector and name.
+func ParseDKIMKey(key DKIMKey, txt string) DKIMKey {
+	key.TXTRecord = txt
+	key.KeyType = "rsa"
+	key.HashAlgorithms = make([]string, 0)
+	if key.Errors == nil {
+		key.Errors = make([]string, 0)
+	}
+	tags, problems := parseTagList(txt)
+	key.Errors = append(key.Errors, problems...)
+	publicKey := ""
+	for i, tag := range tags {
+		switch tag.Tag {
+		case "v":
+			if i != 0 || tag.Value != "DKIM1" {
+				key.Errors = append(key.Errors, "v=DKIM1 must be the first tag")
+			}
+		case "k":
+			key.KeyType = strings.ToLower(tag.Value)
+		case "h":
+			for _, algorithm := range strings.Split(tag.Value, ":") {
+				key.HashAlgorithms = append(key.HashAlgorithms, strings.TrimSpace(algorithm))
+			}
+		case "t":
+			for _, flag := range strings.Split(tag.Value, ":") {
+				switch strings.TrimSpace(flag) {
+				case "y":
+					key.Testing = true
+				case "s":
+					key.StrictSubdomains = true
+				}
+			}
+		case "p":
+			// Base64 in key records may be split by whitespace
+			publicKey = strings.Join(strings.Fields(tag.Value), "")
+		}
+	}
+
+	if len(publicKey) == 0 {
+		key.Revoked = true
+		return key
+	}
+	data, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		key.Errors = append(key.Errors, fmt.Sprintf("p= is not valid base64: %v", err))
+		return key
+	}
+	switch key.KeyType {
+	case "rsa":
+		var rsaKey *rsa.PublicKey
+		if parsed, err := x509.ParsePKIXPublicKey(data); err == nil {
+			rsaKey, _ = parsed.(*rsa.PublicKey)
+		} else {
+			// Some signers publish a bare RSAPublicKey instead of a SubjectPublicKeyInfo
+			rsaKey, _ = x509.ParsePKCS1PublicKey(data)
+		}
+		if rsaKey == nil {
+			key.Errors = append(key.Errors, "p= is not an RSA public key")
+			return key
+		}
+		key.KeyBits = rsaKey.N.BitLen()
+		key.Weak = key.KeyBits < DKIMMinimumRSABits
+	case "ed25519":
+		if len(data) != ed25519.PublicKeySize {
+			key.Errors = append(key.Errors, fmt.Sprintf("ed25519 key is %d bytes, expected %d", len(data), ed25519.PublicKeySize))
+			return key
+		}
+		key.KeyBits = 256
+	default:
+		key.Errors = append(key.Errors, fmt.Sprintf("unknown key type %q", key.KeyType))
+	}
+	return key
+}
@@ -0,0 +1,201 @@
+package structs
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DMARCPolicyNone       = "none"
+	DMARCPolicyQuarantine = "quarantine"
+	DMARCPolicyReject     = "reject"
+)
+
+type DMARCRecord struct {
+	Domain               string           `json:"domain"`
+	Found                bool             `json:"found"`
+	RecordDomain         string           `json:"recordDomain"` // where the record was found, the organizational domain on fallback
+	OrganizationalDomain string           `json:"organizationalDomain"`
+	TXTRecord            string           `json:"txtRecord"`
+	Policy               string           `json:"policy"`
+	SubdomainPolicy      string           `json:"subdomainPolicy"` // sp, or p when sp is absent
+	AppliedPolicy        string           `json:"appliedPolicy"`   // the policy receivers apply to mail from the domain
+	Percentage           int              `json:"percentage"`
+	DKIMAlignment        string           `json:"dkimAlignment"` // r (relaxed) or s (strict)
+	SPFAlignment         string           `json:"spfAlignment"`
+	FailureOptions       []string         `json:"failureOptions"`
+	ReportInterval       int64            `json:"reportInterval"`
+	AggregateReports     []DMARCReportURI `json:"aggregateReports"` // rua
+	FailureReports       []DMARCReportURI `json:"failureReports"`   // ruf
+	Enforced             bool             `json:"enforced"`         // quarantine or reject applied to all mail
+	Errors               []string         `json:"errors"`
+}
+
+type DMARCReportURI struct {
+	URI                 string `json:"uri"`
+	Domain              string `json:"domain"`
+	SizeLimit           string `json:"sizeLimit"`
+	External            bool   `json:"external"`            // outside the organizational domain
+	Authorized          bool   `json:"authorized"`          // same organization, or the destination published <domain>._report._dmarc
+	AuthorizationRecord string `json:"authorizationRecord"` // name queried for external destinations
+}
+
+// tagValue is one "tag=value" pair of the tag lists used by DMARC, DKIM, TLS-RPT and BIMI records
+type tagValue struct {
+	Tag   string
+	Value string
+}
+
+// parseTagList splits a "tag=value; tag=value" record, the returned problems cover malformed and repeated tags
+func parseTagList(record string) ([]tagValue, []string) {
+	tags := make([]tagValue, 0)
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(record, ";") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		tag, value, ok := strings.Cut(field, "=")
+		tag = strings.TrimSpace(tag)
+		if !ok || len(tag) == 0 {
+			problems = append(problems, fmt.Sprintf("malformed tag %q", field))
+			continue
+		}
+		if seen[tag] {
+			problems = append(problems, fmt.Sprintf("tag %s appears more than once", tag))
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tagValue{Tag: tag, Value: strings.TrimSpace(value)})
+	}
+	return tags, problems
+}
+
+// IsDMARCRecord reports whether a TXT record is a DMARC record, "v=DMARC1" must be the first tag
+func IsDMARCRecord(txt string) bool {
+	tags, _ := parseTagList(txt)
+	return len(tags) > 0 && tags[0].Tag == "v" && tags[0].Value == "DMARC1"
+}
+
+// ParseDMARC parses a DMARC record (RFC 7489 section 6.3) into record, which keeps its domain fields.
+// Invalid values are reported and replaced by their defaults.
+func ParseDMARC(record DMARCRecord, txt string) DMARCRecord {
+	record.TXTRecord = txt
+	record.Percentage = 100
+	record.DKIMAlignment, record.SPFAlignment = "r", "r"
+	record.FailureOptions = []string{"0"}
+	record.ReportInterval = 86400
+	record.AggregateReports = make([]DMARCReportURI, 0)
+	record.FailureReports = make([]DMARCReportURI, 0)
+	if record.Errors == nil {
+		record.Errors = make([]string, 0)
+	}
+
+	tags, problems := parseTagList(txt)
+	record.Errors = append(record.Errors, problems...)
+	for _, tag := range tags {
+		switch tag.Tag {
+		case "p", "sp":
+			if !validDMARCPolicy(tag.Value) {
+				record.Errors = append(record.Errors, fmt.Sprintf("%s=%q is not none, quarantine or reject", tag.Tag, tag.Value))
+			} else if tag.Tag == "p" {
+				record.Policy = tag.Value
+			} else {
+				record.SubdomainPolicy = tag.Value
+			}
+		case "pct":
+			pct, err := strconv.Atoi(tag.Value)
+			if err != nil || pct < 0 || pct > 100 {
+				record.Errors = append(record.Errors, fmt.Sprintf("pct=%q is not between 0 and 100", tag.Value))
+				continue
+			}
+			record.Percentage = pct
+		case "adkim", "aspf":
+			if tag.Value != "r" && tag.Value != "s" {
+				record.Errors = append(record.Errors, fmt.Sprintf("%s=%q is not r or s", tag.Tag, tag.Value))
+			} else if tag.Tag == "adkim" {
+				record.DKIMAlignment = tag.Value
+			} else {
+				record.SPFAlignment = tag.Value
+			}
+		case "fo":
+			options := strings.Split(tag.Value, ":")
+			for _, option := range options {
+				if option != "0" && option != "1" && option != "d" && option != "s" {
+					record.Errors = append(record.Errors, fmt.Sprintf("fo option %q is not 0, 1, d or s", option))
+				}
+			}
+			record.FailureOptions = options
+		case "ri":
+			interval, err := strconv.ParseInt(tag.Value, 10, 64)
+			if err != nil || interval < 0 {
+				record.Errors = append(record.Errors, fmt.Sprintf("ri=%q is not a number of seconds", tag.Value))
+				continue
+			}
+			record.ReportInterval = interval
+		case "rua", "ruf":
+			uris := make([]DMARCReportURI, 0)
+			for _, uri := range strings.Split(tag.Value, ",") {
+				reportURI, err := ParseDMARCReportURI(strings.TrimSpace(uri))
+				if err != nil {
+					record.Errors = append(record.Errors, err.Error())
+					continue
+				}
+				uris = append(uris, reportURI)
+			}
+			if tag.Tag == "rua" {
+				record.AggregateReports = uris
+			} else {
+				record.FailureReports = uris
+			}
+		}
+	}
+
+	// A record without a valid p but with rua is treated as p=none (RFC 7489 section 6.6.3)
+	if len(record.Policy) == 0 {
+		record.Errors = append(record.Errors, "missing or invalid p tag, receivers treat the policy as none")
+		record.Policy = DMARCPolicyNone
+	}
+	if len(record.SubdomainPolicy) == 0 {
+		record.SubdomainPolicy = record.Policy
+	}
+	record.AppliedPolicy = record.Policy
+	if len(record.RecordDomain) > 0 && record.RecordDomain != record.Domain {
+		record.AppliedPolicy = record.SubdomainPolicy
+	}
+	record.Enforced = record.AppliedPolicy != DMARCPolicyNone && record.Percentage == 100
+	return record
+}
+
+func validDMARCPolicy(policy string) bool {
+	return policy == DMARCPolicyNone || policy == DMARCPolicyQuarantine || policy == DMARCPolicyReject
+}
+
+// ParseDMARCReportURI parses "mailto:address@domain!10m", the size limit is optional.
+func ParseDMARCReportURI(uri string) (DMARCReportURI, error) {
+	reportURI := DMARCReportURI{URI: uri}
+	if bang := strings.LastIndex(uri, "!"); bang > 0 {
+		reportURI.SizeLimit = uri[bang+1:]
+		uri = uri[:bang]
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return reportURI, fmt.Errorf("invalid report URI %q: %v", reportURI.URI, err)
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "mailto":
+		_, domain, ok := strings.Cut(parsed.Opaque, "@")
+		if !ok || len(domain) == 0 {
+			return reportURI, fmt.Errorf("invalid report URI %q: no mail domain", reportURI.URI)
+		}
+		reportURI.Domain = strings.ToLower(domain)
+	case "https", "http":
+		reportURI.Domain = strings.ToLower(parsed.Hostname())
+	default:
+		return reportURI, fmt.Errorf("invalid report URI %q: unsupported scheme", reportURI.URI)
+	}
+	return reportURI, nil
+}
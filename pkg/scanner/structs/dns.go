@@ -3,22 +3,77 @@ package structs
 import "github.com/miekg/dns"
 
 type CombinedDNSRecord struct {
-	Hostname     string       `json:"hostname"`
-	Resolved     bool         `json:"queryTypeResolved"`
-	DNSSECRecord DNSSECRecord `json:"dnssecRecord"`
-	NSRecords    []string     `json:"nsRecords"`
+	Hostname     string         `json:"hostname"`
+	Resolved     bool           `json:"queryTypeResolved"`
+	DNSSECRecord DNSSECRecord   `json:"dnssecRecord"`
+	NSRecords    []string       `json:"nsRecords"`
+	CNAMEChain   []CNAMELink    `json:"cnameChain"` // aliases followed from the hostname, in order
+	Records      []DNSRecordSet `json:"records"`    // one per collected type, TLSA once per port
+}
+
+// DNSRecordSet is the answer to one query with its DNSSEC validation
+type DNSRecordSet struct {
+	Name    string              `json:"name"` // _<port>._tcp.<hostname> for TLSA
+	Type    string              `json:"type"`
+	TTL     uint32              `json:"ttl"` // lowest TTL of the records, 0 without records
+	Records []DNSResourceRecord `json:"records"`
+	DNSSEC  DNSSECRecord        `json:"dnssec"`
+	Error   string              `json:"error"` // NXDOMAIN, SERVFAIL or a network error
+}
+
+type DNSResourceRecord struct {
+	Name string `json:"name"` // owner name, the canonical name when the hostname is an alias
+	TTL  uint32 `json:"ttl"`
+	Data string `json:"data"` // presentation format without the header
+}
+
+type CNAMELink struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	TTL    uint32 `json:"ttl"`
 }
 
 type DNSSECRecord struct {
-	DNSSECExists bool         `json:"dnssecExists"`
-	DNSSECValid  bool         `json:"dnssecValid"`
-	Reason       string       `json:"reason"`
-	SignedZones  []SignedZone `json:"signedZones"`
+	DNSSECExists bool          `json:"dnssecExists"`
+	DNSSECValid  bool          `json:"dnssecValid"`
+	Reason       string        `json:"reason"`
+	SignedZones  []SignedZone  `json:"signedZones"`
+	Denial       *DenialRecord `json:"denial"` // set when the queried name or type does not exist
+}
+
+// Outcomes of validating a negative answer
+const (
+	DenialSecure             = "securely-absent"     // a signed NSEC/NSEC3 proof covers the name or type
+	DenialInsecureDelegation = "insecure-delegation" // the zone is provably unsigned, or an opt-out span covers the name
+	DenialBogus              = "bogus-denial"        // missing, unsigned or unprovable proof in a signed zone
+)
+
+const (
+	DenialNXDomain = "nxdomain"
+	DenialNoData   = "nodata"
+	DenialNSEC     = "nsec"
+	DenialNSEC3    = "nsec3"
+	DenialNone     = "none"
+)
+
+// DenialRecord is the authenticated denial of existence (RFC 4035 section 5.4, RFC 5155 section 8)
+type DenialRecord struct {
+	Status          string `json:"status"`
+	Type            string `json:"type"`  // nxdomain or nodata
+	Proof           string `json:"proof"` // nsec, nsec3 or none
+	Zone            string `json:"zone"`  // signer of the proof, or owner of the unsigned SOA
+	ClosestEncloser string `json:"closestEncloser"`
+	OptOut          bool   `json:"optOut"` // the next closer name falls in an NSEC3 opt-out span
+	NSEC3Algorithm  uint8  `json:"nsec3Algorithm"`
+	NSEC3Iterations uint16 `json:"nsec3Iterations"` // RFC 9276 recommends 0
+	NSEC3Salt       string `json:"nsec3Salt"`       // hex, empty without salt as RFC 9276 recommends
+	Reason          string `json:"reason"`
 }
 
 type RRSet struct {
-	RrSet []dns.RR   `json:"RrSet"`
-	RrSig *dns.RRSIG `json:"RrSig"`
+	RrSet  []dns.RR     `json:"RrSet"`
+	RrSig  *dns.RRSIG   `json:"RrSig"`
+	RrSigs []*dns.RRSIG `json:"-"`
 }
 
 type SignedZone struct {
@@ -0,0 +1,184 @@
+package structs
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"net"
+)
+
+type ValidationLevel string
+
+const (
+	ValidationUnknown      ValidationLevel = "unknown"
+	ValidationDomain       ValidationLevel = "DV"
+	ValidationOrganization ValidationLevel = "OV"
+	ValidationIndividual   ValidationLevel = "IV"
+	ValidationExtended     ValidationLevel = "EV"
+)
+
+const (
+	tlsFeatureStatusRequest          = 5 // RFC 7633, status_request
+	basicConstraintsMaxPathLenNotSet = -1
+)
+
+// CA/Browser Forum reserved policy identifiers (https://cabforum.org/object-registry/)
+var (
+	OIDExtendedValidation     = asn1.ObjectIdentifier{2, 23, 140, 1, 1}
+	OIDDomainValidated        = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}
+	OIDOrganizationValidated  = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 2}
+	OIDIndividualValidated    = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 3}
+	OIDExtensionTLSFeature    = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+	OIDExtensionCTPoison      = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	OIDExtensionNameConstrain = asn1.ObjectIdentifier{2, 5, 29, 30}
+)
+
+type CertificateExtensions struct {
+	Policies                  []PolicyRecord         `json:"policies"`
+	ValidationLevel           ValidationLevel        `json:"validationLevel"` // Highest CA/B validation level asserted
+	OCSPServers               []string               `json:"ocspServers"`     // Authority Information Access
+	IssuingCertificateURLs    []string               `json:"caIssuers"`       // Authority Information Access
+	CRLDistributionPoints     []string               `json:"crlDistributionPoints"`
+	NameConstraints           *NameConstraintsRecord `json:"nameConstraints"` // nil if the extension is absent
+	BasicConstraints          BasicConstraintsRecord `json:"basicConstraints"`
+	SubjectKeyID              string                 `json:"subjectKeyId"`   // Hex encoded
+	AuthorityKeyID            string                 `json:"authorityKeyId"` // Hex encoded
+	MustStaple                bool                   `json:"mustStaple"`
+	PrecertificatePoison      bool                   `json:"precertificatePoison"`
+	UnknownCriticalExtensions []string               `json:"unknownCriticalExtensions"` // Dotted OIDs
+}
+
+type PolicyRecord struct {
+	ObjectIdentifier string          `json:"oid"`
+	Type             ValidationLevel `json:"type"` // unknown for CA specific policies
+}
+
+type BasicConstraintsRecord struct {
+	Present    bool `json:"present"`
+	IsCA       bool `json:"isCA"`
+	MaxPathLen int  `json:"maxPathLen"` // -1 when unconstrained
+}
+
+type NameConstraintsRecord struct {
+	Critical                bool     `json:"critical"`
+	PermittedDNSDomains     []string `json:"permittedDNSDomains"`
+	ExcludedDNSDomains      []string `json:"excludedDNSDomains"`
+	PermittedIPRanges       []string `json:"permittedIPRanges"`
+	ExcludedIPRanges        []string `json:"excludedIPRanges"`
+	PermittedEmailAddresses []string `json:"permittedEmailAddresses"`
+	ExcludedEmailAddresses  []string `json:"excludedEmailAddresses"`
+	PermittedURIDomains     []string `json:"permittedURIDomains"`
+	ExcludedURIDomains      []string `json:"excludedURIDomains"`
+}
+
+func ClassifyPolicy(oid asn1.ObjectIdentifier) ValidationLevel {
+	switch {
+	case oid.Equal(OIDExtendedValidation):
+		return ValidationExtended
+	case oid.Equal(OIDOrganizationValidated):
+		return ValidationOrganization
+	case oid.Equal(OIDIndividualValidated):
+		return ValidationIndividual
+	case oid.Equal(OIDDomainValidated):
+		return ValidationDomain
+	default:
+		return ValidationUnknown
+	}
+}
+
+// validationRank orders validation levels so that the strongest asserted level wins.
+func validationRank(level ValidationLevel) int {
+	switch level {
+	case ValidationExtended:
+		return 3
+	case ValidationOrganization, ValidationIndividual:
+		return 2
+	case ValidationDomain:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func serializeIPNets(ipNets []*net.IPNet) []string {
+	result := make([]string, 0)
+	for _, ipNet := range ipNets {
+		result = append(result, ipNet.String())
+	}
+	return result
+}
+
+// parseMustStaple returns true if the TLS Feature extension requests status_request (RFC 7633).
+func parseMustStaple(value []byte) bool {
+	var features []int
+	if _, err := asn1.Unmarshal(value, &features); err != nil {
+		return false
+	}
+	for _, feature := range features {
+		if feature == tlsFeatureStatusRequest {
+			return true
+		}
+	}
+	return false
+}
+
+func SerializeCertificateExtensions(c *x509.Certificate) CertificateExtensions {
+	extensions := CertificateExtensions{
+		Policies:                  make([]PolicyRecord, 0),
+		ValidationLevel:           ValidationUnknown,
+		OCSPServers:               c.OCSPServer,
+		IssuingCertificateURLs:    c.IssuingCertificateURL,
+		CRLDistributionPoints:     c.CRLDistributionPoints,
+		SubjectKeyID:              hex.EncodeToString(c.SubjectKeyId),
+		AuthorityKeyID:            hex.EncodeToString(c.AuthorityKeyId),
+		UnknownCriticalExtensions: make([]string, 0),
+	}
+
+	for _, oid := range c.PolicyIdentifiers {
+		policyType := ClassifyPolicy(oid)
+		extensions.Policies = append(extensions.Policies, PolicyRecord{ObjectIdentifier: oid.String(), Type: policyType})
+		if validationRank(policyType) > validationRank(extensions.ValidationLevel) {
+			extensions.ValidationLevel = policyType
+		}
+	}
+
+	extensions.BasicConstraints = BasicConstraintsRecord{
+		Present:    c.BasicConstraintsValid,
+		IsCA:       c.IsCA,
+		MaxPathLen: basicConstraintsMaxPathLenNotSet,
+	}
+	if c.BasicConstraintsValid && (c.MaxPathLen > 0 || c.MaxPathLenZero) {
+		extensions.BasicConstraints.MaxPathLen = c.MaxPathLen
+	}
+
+	for _, ext := range c.Extensions {
+		switch {
+		case ext.Id.Equal(OIDExtensionTLSFeature):
+			extensions.MustStaple = parseMustStaple(ext.Value)
+		case ext.Id.Equal(OIDExtensionCTPoison):
+			extensions.PrecertificatePoison = true
+		case ext.Id.Equal(OIDExtensionNameConstrain):
+			extensions.NameConstraints = &NameConstraintsRecord{
+				Critical:                ext.Critical,
+				PermittedDNSDomains:     c.PermittedDNSDomains,
+				ExcludedDNSDomains:      c.ExcludedDNSDomains,
+				PermittedIPRanges:       serializeIPNets(c.PermittedIPRanges),
+				ExcludedIPRanges:        serializeIPNets(c.ExcludedIPRanges),
+				PermittedEmailAddresses: c.PermittedEmailAddresses,
+				ExcludedEmailAddresses:  c.ExcludedEmailAddresses,
+				PermittedURIDomains:     c.PermittedURIDomains,
+				ExcludedURIDomains:      c.ExcludedURIDomains,
+			}
+		}
+	}
+
+	// Extensions captured above are not "unknown" even if crypto/x509 does not process them.
+	for _, oid := range c.UnhandledCriticalExtensions {
+		if oid.Equal(OIDExtensionCTPoison) || oid.Equal(OIDExtensionTLSFeature) {
+			continue
+		}
+		extensions.UnknownCriticalExtensions = append(extensions.UnknownCriticalExtensions, oid.String())
+	}
+
+	return extensions
+}
@@ -1,27 +1,51 @@
 package structs
 
 import (
+	"net"
 	"sort"
 	"strconv"
 	"strings"
 )
 
+// How mail for a domain is handled
+const (
+	MailHandlingExplicitMX = "explicit-mx" // MX records
+	MailHandlingImplicitMX = "implicit-mx" // no MX, delivered to the domain's A/AAAA records (RFC 5321)
+	MailHandlingNullMX     = "null-mx"     // "MX 0 ." declares that the domain accepts no mail (RFC 7505)
+	MailHandlingNone       = "none"        // no MX and no address records
+)
+
 type MailScanCombinedRecord struct {
 	MailHost             string                                  `json:"mailHost"`
+	MailHandling         string                                  `json:"mailHandling"`
+	MXRecordTTL          uint32                                  `json:"mxRecordTTL"`
 	ResolvedMX           []string                                `json:"mxServers"`
 	MXServerPriority     map[string]uint16                       `json:"mxServerPriority"`
 	MXServerReachability map[string]ReachabilitySecurityMetadata `json:"mxServerReachability"`
 	NumResolvedMX        int                                     `json:"numMxServers"`
 	MailServerMetadata   map[string]SMTPMetadata                 `json:"metadata"`
 	MXTLSInformation     map[string]TLSCombinedRecord            `json:"mxTLSInformation"`
+	Endpoints            []MailEndpointRecord                    `json:"endpoints"` // sorted by MX, IP and port
+	MTASTS               *MTASTSRecord                           `json:"mtaSts"`
+	DANE                 map[string]DANERecord                   `json:"dane"` // mx : DANE status of port 25
+	SPF                  *SPFRecord                              `json:"spf"`
+	DMARC                *DMARCRecord                            `json:"dmarc"`
+	TLSRPT               *TLSRPTRecord                           `json:"tlsRpt"`
+	BIMI                 *BIMIRecord                             `json:"bimi"`
+	DKIM                 *DKIMRecord                             `json:"dkim"`
+	MailProvider         *MailProviderRecord                     `json:"mailProvider"` // nil without provider rules
+	ReverseDNS           map[string]map[string]ReverseDNSRecord  `json:"reverseDns"`   // mx : ip : FCrDNS compared with the MX and banner
 }
 
 type SMTPMetadata struct {
 	// private scope
 	host string
 	// public scope
-	Banner       string            `json:"banner"`
-	Capabilities map[string]string `json:"capabilities"`
+	Banner                string             `json:"banner"`
+	Capabilities          map[string]string  `json:"capabilities"`
+	ParsedCapabilities    SMTPCapabilities   `json:"parsedCapabilities"`
+	EncryptedCapabilities *SMTPCapabilities  `json:"encryptedCapabilities"` // EHLO repeated after STARTTLS
+	CapabilityChanges     *CapabilityChanges `json:"capabilityChanges"`     // encrypted compared to plaintext EHLO
 }
 
 func (s *SMTPMetadata) GetHost() string {
@@ -32,9 +56,48 @@ func NewSMTPMetadata(address string) SMTPMetadata {
 	return SMTPMetadata{host: address}
 }
 
+// Connection modes of a mail port, implicit TLS (RFC 8314) or a plaintext session upgraded with STARTTLS
+const (
+	ModeImplicitTLS = "implicit-tls"
+	ModeSTARTTLS    = "starttls"
+	ModeUnknown     = "unknown"
+)
+
 type ReachabilitySecurityMetadata struct {
-	SecurePorts    []int `json:"secure"`
-	ReachablePorts []int `json:"reachable"`
+	SecurePorts    []int                         `json:"secure"`
+	ReachablePorts []int                         `json:"reachable"`
+	PortModes      map[int]string                `json:"portModes"` // port : connection mode
+	IPs            map[string]IPPortReachability `json:"ips"`       // ip : ports, only for MXs scanned per endpoint
+}
+
+type IPPortReachability struct {
+	SecurePorts    []int          `json:"secure"`
+	ReachablePorts []int          `json:"reachable"`
+	PortModes      map[int]string `json:"portModes"`
+}
+
+// MailEndpointRecord is the outcome of scanning one port of one IP of an MX
+type MailEndpointRecord struct {
+	MX         string        `json:"mx"`
+	IP         string        `json:"ip"`
+	Port       int           `json:"port"`
+	Open       bool          `json:"open"`
+	Mode       string        `json:"mode"` // connection mode detected on this IP, empty when closed
+	Metadata   *SMTPMetadata `json:"metadata"`
+	TLSSuccess bool          `json:"tlsSuccess"` // the implicit TLS or STARTTLS handshake completed
+	TLSError   string        `json:"tlsError"`
+}
+
+func SortMailEndpoints(endpoints []MailEndpointRecord) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].MX != endpoints[j].MX {
+			return endpoints[i].MX < endpoints[j].MX
+		}
+		if endpoints[i].IP != endpoints[j].IP {
+			return endpoints[i].IP < endpoints[j].IP
+		}
+		return endpoints[i].Port < endpoints[j].Port
+	})
 }
 
 func identifyAllowedPorts[V SMTPMetadata | TLSCombinedRecord](input map[string]V) map[string]map[int]bool {
@@ -101,11 +164,69 @@ func (m *MailScanCombinedRecord) IdentifyReachableAndSecurePorts() {
 		sort.Ints(securePorts)
 		sort.Ints(reachablePorts)
 
+		portModes := make(map[int]string)
+		for _, port := range securePorts {
+			record := m.MXTLSInformation[net.JoinHostPort(mx, strconv.Itoa(port))]
+			if len(record.ConnectionMode) > 0 {
+				portModes[port] = record.ConnectionMode
+			}
+		}
+
 		response.ReachablePorts = reachablePorts
 		response.SecurePorts = securePorts
+		response.PortModes = portModes
 
 		result[mx] = response
 	}
 
+	m.identifyEndpointReachability(result)
 	m.MXServerReachability = result
 }
+
+// identifyEndpointReachability replaces the host:port view with the per endpoint results for every MX
+// scanned per IP. A port is reachable when open on any IP, and secure when TLS succeeded on any IP.
+// Cached MX data from older scans has no endpoints and keeps the host:port view.
+func (m *MailScanCombinedRecord) identifyEndpointReachability(result map[string]ReachabilitySecurityMetadata) {
+	endpointsByMX := make(map[string][]MailEndpointRecord)
+	for _, endpoint := range m.Endpoints {
+		endpointsByMX[endpoint.MX] = append(endpointsByMX[endpoint.MX], endpoint)
+	}
+
+	for mx, endpoints := range endpointsByMX {
+		reachable, secure := make(map[int]bool), make(map[int]bool)
+		response := ReachabilitySecurityMetadata{PortModes: make(map[int]string), IPs: make(map[string]IPPortReachability)}
+		for _, endpoint := range endpoints {
+			ipReachability, ok := response.IPs[endpoint.IP]
+			if !ok {
+				ipReachability = IPPortReachability{SecurePorts: make([]int, 0), ReachablePorts: make([]int, 0), PortModes: make(map[int]string)}
+			}
+			if endpoint.Open {
+				reachable[endpoint.Port] = true
+				ipReachability.ReachablePorts = append(ipReachability.ReachablePorts, endpoint.Port)
+				if len(endpoint.Mode) > 0 {
+					ipReachability.PortModes[endpoint.Port] = endpoint.Mode
+				}
+			}
+			if endpoint.TLSSuccess {
+				secure[endpoint.Port] = true
+				ipReachability.SecurePorts = append(ipReachability.SecurePorts, endpoint.Port)
+				response.PortModes[endpoint.Port] = endpoint.Mode
+			}
+			sort.Ints(ipReachability.ReachablePorts)
+			sort.Ints(ipReachability.SecurePorts)
+			response.IPs[endpoint.IP] = ipReachability
+		}
+
+		response.ReachablePorts = make([]int, 0)
+		for port := range reachable {
+			response.ReachablePorts = append(response.ReachablePorts, port)
+		}
+		response.SecurePorts = make([]int, 0)
+		for port := range secure {
+			response.SecurePorts = append(response.SecurePorts, port)
+		}
+		sort.Ints(response.ReachablePorts)
+		sort.Ints(response.SecurePorts)
+		result[mx] = response
+	}
+}
@@ -3,6 +3,8 @@ package structs
 type Request struct {
 	Hostname string `json:"hostname"`
 	NoServer bool   `json:"noServer"`
+	Port     string `json:"port,omitempty"`     // defaults to the protocol's port
+	Protocol string `json:"protocol,omitempty"` // upgrade protocol, defaults to TLS
 }
 
 type DNSRequest struct {
@@ -0,0 +1,127 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MTASTSModeEnforce = "enforce"
+	MTASTSModeTesting = "testing"
+	MTASTSModeNone    = "none"
+	MTASTSMaxAge      = 31557600 // one year, the largest max_age allowed by RFC 8461
+)
+
+var ErrMTASTSRecord = errors.New("invalid MTA-STS TXT record")
+
+type MTASTSRecord struct {
+	Domain    string                    `json:"domain"`
+	Enabled   bool                      `json:"enabled"` // a single STSv1 TXT record was found
+	TXTRecord string                    `json:"txtRecord"`
+	ID        string                    `json:"id"`
+	PolicyURL string                    `json:"policyUrl"`
+	Policy    *MTASTSPolicy             `json:"policy"`
+	MXResults map[string]MTASTSMXResult `json:"mxResults"` // mx : policy result
+	Errors    []string                  `json:"errors"`
+}
+
+type MTASTSPolicy struct {
+	Version string   `json:"version"`
+	Mode    string   `json:"mode"`
+	MaxAge  int64    `json:"maxAge"`
+	MX      []string `json:"mx"`
+}
+
+type MTASTSMXResult struct {
+	MatchedPattern     string `json:"matchedPattern"`
+	PatternMatch       bool   `json:"patternMatch"`
+	CertificateChecked bool   `json:"certificateChecked"` // port 25 of the MX was scanned
+	CertificateValid   bool   `json:"certificateValid"`   // every IP presented a trusted certificate for the MX name
+	Compliant          bool   `json:"compliant"`
+}
+
+// ParseMTASTSTXT validates a "v=STSv1; id=..." record and returns the policy id.
+func ParseMTASTSTXT(record string) (string, error) {
+	fields := strings.Split(record, ";")
+	id := ""
+	for i, field := range fields {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch {
+		case i == 0 && (key != "v" || value != "STSv1"):
+			return "", fmt.Errorf("%w: version must come first and be STSv1", ErrMTASTSRecord)
+		case key == "id":
+			id = value
+		}
+	}
+	if len(id) == 0 || len(id) > 32 || strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) >= 0 {
+		return "", fmt.Errorf("%w: id must be 1 to 32 alphanumeric characters", ErrMTASTSRecord)
+	}
+	return id, nil
+}
+
+// ParseMTASTSPolicy parses a policy file (RFC 8461 section 3.2) and returns every problem found.
+func ParseMTASTSPolicy(body string) (MTASTSPolicy, []string) {
+	policy := MTASTSPolicy{MX: make([]string, 0)}
+	problems := make([]string, 0)
+	maxAgeSeen := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			problems = append(problems, fmt.Sprintf("malformed policy line %q", line))
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "version":
+			policy.Version = value
+		case "mode":
+			policy.Mode = value
+		case "max_age":
+			maxAgeSeen = true
+			maxAge, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || maxAge < 0 || maxAge > MTASTSMaxAge {
+				problems = append(problems, fmt.Sprintf("max_age %q is not between 0 and %d", value, MTASTSMaxAge))
+				continue
+			}
+			policy.MaxAge = maxAge
+		case "mx":
+			policy.MX = append(policy.MX, strings.ToLower(value))
+		}
+	}
+
+	if policy.Version != "STSv1" {
+		problems = append(problems, fmt.Sprintf("version %q is not STSv1", policy.Version))
+	}
+	switch policy.Mode {
+	case MTASTSModeEnforce, MTASTSModeTesting:
+		if len(policy.MX) == 0 {
+			problems = append(problems, "policy has no mx patterns")
+		}
+	case MTASTSModeNone:
+	default:
+		problems = append(problems, fmt.Sprintf("mode %q is not enforce, testing or none", policy.Mode))
+	}
+	if !maxAgeSeen {
+		problems = append(problems, "policy has no max_age")
+	}
+	return policy, problems
+}
+
+// MatchMTASTSPattern matches an MX host against an exact or "*." pattern, a wildcard covers exactly one label.
+func MatchMTASTSPattern(pattern string, mx string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	mx = strings.ToLower(strings.TrimSuffix(mx, "."))
+	if strings.HasPrefix(pattern, "*.") {
+		_, parent, ok := strings.Cut(mx, ".")
+		return ok && parent == pattern[2:]
+	}
+	return pattern == mx
+}
@@ -0,0 +1,22 @@
+package structs
+
+type NameMatchType string
+
+const (
+	NameMatchExactSAN       NameMatchType = "exact-san"
+	NameMatchWildcardSAN    NameMatchType = "wildcard-san"
+	NameMatchCommonNameOnly NameMatchType = "cn-only"
+	NameMatchNone           NameMatchType = "none"
+)
+
+type NameMatchRecord struct {
+	Hostname                 string        `json:"hostname"`
+	MatchType                NameMatchType `json:"matchType"`
+	MatchedName              string        `json:"matchedName"`
+	SANCount                 int           `json:"sanCount"`
+	WWWVariant               string        `json:"wwwVariant"` // www. added to or stripped from the hostname
+	WWWVariantCovered        bool          `json:"wwwVariantCovered"`
+	Apex                     string        `json:"apex"` // Registrable domain (eTLD+1) of the hostname
+	ApexCovered              bool          `json:"apexCovered"`
+	SharedRegistrableDomains []string      `json:"sharedRegistrableDomains"` // Other eTLD+1s present in the certificate
+}
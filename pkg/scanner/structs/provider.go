@@ -0,0 +1,29 @@
+package structs
+
+const (
+	ProviderSelfHosted = "self-hosted" // no MX or certificate name matched and every MX is inside the domain's organization
+	ProviderUnknown    = "unknown"     // no MX or certificate name matched and an MX is operated by another organization
+)
+
+// Provider evidence sources
+const (
+	ProviderEvidenceMX                = "mx"
+	ProviderEvidenceBanner            = "banner"
+	ProviderEvidenceCertificateName   = "certificateName"
+	ProviderEvidenceCertificateIssuer = "certificateIssuer"
+)
+
+type MailProviderRecord struct {
+	Provider  string             `json:"provider"`  // highest scoring provider, self-hosted or unknown
+	Providers []string           `json:"providers"` // every provider matched by MX or certificate name, highest score first
+	Scores    map[string]int     `json:"scores"`    // provider : weighted evidence count
+	Evidence  []ProviderEvidence `json:"evidence"`
+}
+
+type ProviderEvidence struct {
+	Provider string `json:"provider"`
+	Source   string `json:"source"`
+	Subject  string `json:"subject"` // MX host, or the host:port that presented the banner or certificate
+	Value    string `json:"value"`   // the matched banner, name or issuer
+	Rule     string `json:"rule"`    // the rule pattern that matched
+}
@@ -0,0 +1,13 @@
+package structs
+
+// ReverseDNSRecord is the PTR and forward-confirmed reverse DNS (FCrDNS) result of one IP
+type ReverseDNSRecord struct {
+	PTRNames         []string `json:"ptrNames"`
+	ConfirmedNames   []string `json:"confirmedNames"`   // PTR names whose A/AAAA records include the IP
+	ForwardConfirmed bool     `json:"forwardConfirmed"` // at least one confirmed name
+	Hostname         string   `json:"hostname"`         // scanned hostname, the MX for mail
+	MatchesHostname  bool     `json:"matchesHostname"`  // a confirmed name is the hostname
+	BannerHostname   string   `json:"bannerHostname"`   // mail only, the name announced in the SMTP greeting
+	MatchesBanner    bool     `json:"matchesBanner"`    // a confirmed name is the banner hostname
+	Error            string   `json:"error"`
+}
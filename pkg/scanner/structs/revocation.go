@@ -0,0 +1,30 @@
+package structs
+
+import "time"
+
+type RevocationStatus string
+
+const (
+	RevocationGood    RevocationStatus = "good"
+	RevocationRevoked RevocationStatus = "revoked"
+	RevocationUnknown RevocationStatus = "unknown"
+)
+
+type RevocationRecord struct {
+	Status    RevocationStatus        `json:"status"`
+	RevokedAt *time.Time              `json:"revokedAt"`
+	Reason    int                     `json:"reason"` // RFC 5280 CRLReason from the OCSP response or the CRL entry
+	OCSP      *RevocationSourceRecord `json:"ocsp"`
+	CRL       *RevocationSourceRecord `json:"crl"`
+	Error     string                  `json:"error"`
+}
+
+type RevocationSourceRecord struct {
+	URL        string           `json:"url"`
+	Status     RevocationStatus `json:"status"`
+	ThisUpdate time.Time        `json:"thisUpdate"`
+	NextUpdate time.Time        `json:"nextUpdate"`
+	RevokedAt  *time.Time       `json:"revokedAt"`
+	Reason     int              `json:"reason"`
+	Error      string           `json:"error"`
+}
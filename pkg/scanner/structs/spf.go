@@ -0,0 +1,269 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SPF results (RFC 7208 section 2.6)
+const (
+	SPFPass      = "pass"
+	SPFFail      = "fail"
+	SPFSoftFail  = "softfail"
+	SPFNeutral   = "neutral"
+	SPFNone      = "none"
+	SPFTempError = "temperror"
+	SPFPermError = "permerror"
+
+	SPFMaxDNSLookups  = 10 // include, a, mx, ptr, exists and redirect
+	SPFMaxVoidLookups = 2  // lookups answered with NXDOMAIN or no records
+)
+
+var ErrSPFSyntax = errors.New("invalid SPF record")
+
+// spfQualifierResults maps a qualifier to the result of a matching mechanism
+var spfQualifierResults = map[string]string{
+	"+": SPFPass,
+	"-": SPFFail,
+	"~": SPFSoftFail,
+	"?": SPFNeutral,
+}
+
+// spfLookupMechanisms count against SPFMaxDNSLookups
+var spfLookupMechanisms = map[string]bool{"include": true, "a": true, "mx": true, "ptr": true, "exists": true}
+
+type SPFRecord struct {
+	Domain              string                 `json:"domain"`
+	Found               bool                   `json:"found"` // a single v=spf1 TXT record was found
+	MultipleRecords     bool                   `json:"multipleRecords"`
+	TXTRecord           string                 `json:"txtRecord"`
+	Policy              *SPFPolicy             `json:"policy"`
+	AllQualifier        string                 `json:"allQualifier"`  // qualifier of the "all" mechanism, empty without one
+	PermissiveAll       bool                   `json:"permissiveAll"` // +all or ?all, any host may send for the domain
+	DNSLookups          int                    `json:"dnsLookups"`    // counted over the whole include and redirect tree
+	VoidLookups         int                    `json:"voidLookups"`
+	LookupLimitExceeded bool                   `json:"lookupLimitExceeded"`
+	Includes            []SPFRecord            `json:"includes"`
+	Redirect            *SPFRecord             `json:"redirect"`
+	MXResults           map[string]SPFIPResult `json:"mxResults"`     // ip : result, for the IPs of every MX
+	DomainResults       map[string]SPFIPResult `json:"domainResults"` // ip : result, for the A/AAAA records of the domain
+	Errors              []string               `json:"errors"`
+}
+
+type SPFIPResult struct {
+	Host        string `json:"host"`
+	Result      string `json:"result"`
+	Pass        bool   `json:"pass"`
+	MatchedTerm string `json:"matchedTerm"` // domain and mechanism that decided the result
+	Error       string `json:"error"`
+}
+
+type SPFPolicy struct {
+	Directives  []SPFDirective `json:"directives"`
+	Modifiers   []SPFModifier  `json:"modifiers"`
+	Redirect    string         `json:"redirect"`
+	Explanation string         `json:"explanation"`
+}
+
+type SPFDirective struct {
+	Qualifier  string `json:"qualifier"`
+	Mechanism  string `json:"mechanism"`
+	DomainSpec string `json:"domainSpec"` // empty when the mechanism defaults to the current domain
+	Network    string `json:"network"`    // ip4 and ip6 only, in CIDR notation
+	IP4Prefix  int    `json:"ip4Prefix"`  // a and mx only
+	IP6Prefix  int    `json:"ip6Prefix"`  // a and mx only
+}
+
+type SPFModifier struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Result is the SPF result of a matching directive
+func (d SPFDirective) Result() string {
+	return spfQualifierResults[d.Qualifier]
+}
+
+// LookupMechanism reports whether the directive counts against SPFMaxDNSLookups
+func (d SPFDirective) LookupMechanism() bool {
+	return spfLookupMechanisms[d.Mechanism]
+}
+
+func (d SPFDirective) String() string {
+	term := d.Qualifier + d.Mechanism
+	if d.Qualifier == "+" {
+		term = d.Mechanism
+	}
+	switch {
+	case len(d.Network) > 0:
+		return term + ":" + d.Network
+	case len(d.DomainSpec) > 0:
+		term += ":" + d.DomainSpec
+	}
+	if d.IP4Prefix != 32 && (d.Mechanism == "a" || d.Mechanism == "mx") {
+		term += "/" + strconv.Itoa(d.IP4Prefix)
+	}
+	if d.IP6Prefix != 128 && (d.Mechanism == "a" || d.Mechanism == "mx") {
+		term += "//" + strconv.Itoa(d.IP6Prefix)
+	}
+	return term
+}
+
+// IsSPFRecord reports whether a TXT record is an SPF version 1 record
+func IsSPFRecord(txt string) bool {
+	lower := strings.ToLower(txt)
+	return lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ")
+}
+
+// ParseSPF parses a "v=spf1 ..." record into its directives and modifiers (RFC 7208 section 4.6.1).
+func ParseSPF(record string) (SPFPolicy, error) {
+	policy := SPFPolicy{Directives: make([]SPFDirective, 0), Modifiers: make([]SPFModifier, 0)}
+	if !IsSPFRecord(record) {
+		return policy, fmt.Errorf("%w: record must start with v=spf1", ErrSPFSyntax)
+	}
+	for _, term := range strings.Fields(record)[1:] {
+		if name, value, ok := cutSPFModifier(term); ok {
+			switch name {
+			case "redirect", "exp":
+				for _, modifier := range policy.Modifiers {
+					if modifier.Name == name {
+						return policy, fmt.Errorf("%w: %s appears more than once", ErrSPFSyntax, name)
+					}
+				}
+				if len(value) == 0 {
+					return policy, fmt.Errorf("%w: %s has no domain", ErrSPFSyntax, name)
+				}
+				if name == "redirect" {
+					policy.Redirect = value
+				} else {
+					policy.Explanation = value
+				}
+			}
+			// Unknown modifiers are ignored
+			policy.Modifiers = append(policy.Modifiers, SPFModifier{Name: name, Value: value})
+			continue
+		}
+		directive, err := parseSPFDirective(term)
+		if err != nil {
+			return policy, err
+		}
+		policy.Directives = append(policy.Directives, directive)
+	}
+	return policy, nil
+}
+
+// cutSPFModifier splits "name=value" when name is a valid modifier name
+func cutSPFModifier(term string) (string, string, bool) {
+	name, value, ok := strings.Cut(term, "=")
+	if !ok || len(name) == 0 || strings.ContainsAny(name, ":/") {
+		return "", "", false
+	}
+	for i, r := range name {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || !(r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')) {
+			return "", "", false
+		}
+	}
+	return strings.ToLower(name), value, true
+}
+
+func parseSPFDirective(term string) (SPFDirective, error) {
+	directive := SPFDirective{Qualifier: "+", IP4Prefix: 32, IP6Prefix: 128}
+	if _, ok := spfQualifierResults[term[:1]]; ok {
+		directive.Qualifier = term[:1]
+		term = term[1:]
+	}
+	end := strings.IndexAny(term, ":/")
+	if end < 0 {
+		end = len(term)
+	}
+	directive.Mechanism = strings.ToLower(term[:end])
+	arguments := term[end:]
+
+	switch directive.Mechanism {
+	case "all":
+		if len(arguments) > 0 {
+			return directive, fmt.Errorf("%w: all takes no arguments", ErrSPFSyntax)
+		}
+	case "include", "exists", "ptr":
+		domain, ok := strings.CutPrefix(arguments, ":")
+		if len(arguments) > 0 && (!ok || len(domain) == 0) {
+			return directive, fmt.Errorf("%w: malformed %s", ErrSPFSyntax, term)
+		}
+		if directive.Mechanism != "ptr" && len(domain) == 0 {
+			return directive, fmt.Errorf("%w: %s requires a domain", ErrSPFSyntax, directive.Mechanism)
+		}
+		directive.DomainSpec = domain
+	case "a", "mx":
+		if strings.HasPrefix(arguments, ":") {
+			end := strings.Index(arguments, "/")
+			if end < 0 {
+				end = len(arguments)
+			}
+			directive.DomainSpec = arguments[1:end]
+			arguments = arguments[end:]
+			if len(directive.DomainSpec) == 0 {
+				return directive, fmt.Errorf("%w: malformed %s", ErrSPFSyntax, term)
+			}
+		}
+		var err error
+		if directive.IP4Prefix, directive.IP6Prefix, err = parseSPFDualCIDR(arguments); err != nil {
+			return directive, fmt.Errorf("%w: %s: %v", ErrSPFSyntax, term, err)
+		}
+	case "ip4", "ip6":
+		address, ok := strings.CutPrefix(arguments, ":")
+		if !ok {
+			return directive, fmt.Errorf("%w: %s requires an address", ErrSPFSyntax, directive.Mechanism)
+		}
+		network, err := parseSPFNetwork(address, directive.Mechanism == "ip6")
+		if err != nil {
+			return directive, fmt.Errorf("%w: %s: %v", ErrSPFSyntax, term, err)
+		}
+		directive.Network = network.String()
+	default:
+		return directive, fmt.Errorf("%w: unknown mechanism %q", ErrSPFSyntax, directive.Mechanism)
+	}
+	return directive, nil
+}
+
+// parseSPFDualCIDR parses the optional "/ip4-cidr" and "//ip6-cidr" suffixes of a and mx
+func parseSPFDualCIDR(arguments string) (int, int, error) {
+	ip4Prefix, ip6Prefix := 32, 128
+	ip4Part, ip6Part, dual := strings.Cut(arguments, "//")
+	if len(ip4Part) > 0 {
+		prefix, err := strconv.Atoi(strings.TrimPrefix(ip4Part, "/"))
+		if !strings.HasPrefix(ip4Part, "/") || err != nil || prefix < 0 || prefix > 32 {
+			return 0, 0, fmt.Errorf("invalid ip4 prefix %q", ip4Part)
+		}
+		ip4Prefix = prefix
+	}
+	if dual {
+		prefix, err := strconv.Atoi(ip6Part)
+		if err != nil || prefix < 0 || prefix > 128 {
+			return 0, 0, fmt.Errorf("invalid ip6 prefix %q", ip6Part)
+		}
+		ip6Prefix = prefix
+	}
+	return ip4Prefix, ip6Prefix, nil
+}
+
+func parseSPFNetwork(address string, ipv6 bool) (*net.IPNet, error) {
+	if !strings.Contains(address, "/") {
+		if ipv6 {
+			address += "/128"
+		} else {
+			address += "/32"
+		}
+	}
+	ip, network, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, err
+	}
+	if (ip.To4() == nil) != ipv6 {
+		return nil, fmt.Errorf("address family does not match")
+	}
+	return network, nil
+}
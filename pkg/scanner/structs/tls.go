@@ -1,16 +1,29 @@
 package structs
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type TLSCombinedRecord struct {
-	Hostname       string                           `json:"hostname"`
-	ResolvedIPs    []string                         `json:"resolvedIPs"`
-	ScannedIPs     []string                         `json:"scannedIPs"`
-	FilteredIPs    []string                         `json:"filteredIPs"`
-	IPv4Count      int                              `json:"ipv4count"`
-	IPv6Count      int                              `json:"ipv6count"`
-	NumUniqueCerts int                              `json:"numUniqueCerts"`
-	Certificates   map[string]CertificateRecord     `json:"certificate"`  // ip : tlsrecord
-	Errors         map[string]string                `json:"errors"`       // ip : error
-	CipherSuites   map[string][]VersionSuitesRecord `json:"cipherSuites"` // ip : []VersionAndCipherSuites
+	Hostname          string                             `json:"hostname"`
+	ResolvedIPs       []string                           `json:"resolvedIPs"`
+	ScannedIPs        []string                           `json:"scannedIPs"`
+	FilteredIPs       []string                           `json:"filteredIPs"`
+	IPv4Count         int                                `json:"ipv4count"`
+	IPv6Count         int                                `json:"ipv6count"`
+	ConnectionMode    string                             `json:"connectionMode"` // implicit-tls or starttls
+	NumUniqueCerts    int                                `json:"numUniqueCerts"`
+	Certificates      map[string]CertificateRecord       `json:"certificate"`       // ip : tlsrecord
+	CertificateChains map[string][]string                `json:"certificateChains"` // ip : []sha256 fingerprint, leaf first
+	NameMatches       map[string]NameMatchRecord         `json:"nameMatches"`       // ip : hostname match against the leaf
+	Errors            map[string]string                  `json:"errors"`            // ip : error
+	CipherSuites      map[string][]VersionSuitesRecord   `json:"cipherSuites"`      // ip : []VersionAndCipherSuites
+	Features          map[string]TLSFeatureRecord        `json:"features"`          // ip : protocol features
+	ServerFingerprint map[string]ServerFingerprintRecord `json:"serverFingerprint"` // ip : JA3S/JA4S per probe
+	ReverseDNS        map[string]ReverseDNSRecord        `json:"reverseDns"`        // ip : PTR and forward confirmation
+	Consistency       ConsistencyRecord                  `json:"consistency"`
 }
 
 type VersionSuitesRecord struct {
@@ -18,3 +31,134 @@ type VersionSuitesRecord struct {
 	IsSupported           bool     `json:"isSupported"`
 	SupportedCipherSuites []uint16 `json:"supportedCipherSuites"`
 }
+
+type FallbackSCSVStatus string
+
+const (
+	FallbackSCSVHonoured      FallbackSCSVStatus = "honoured"       // inappropriate_fallback alert on downgrade
+	FallbackSCSVIgnored       FallbackSCSVStatus = "ignored"        // downgraded handshake accepted
+	FallbackSCSVNotApplicable FallbackSCSVStatus = "not-applicable" // a single version is supported
+	FallbackSCSVUnknown       FallbackSCSVStatus = "unknown"
+)
+
+type TLSFeatureRecord struct {
+	SessionIDAssigned      bool               `json:"sessionIdAssigned"` // ServerHello carries a session ID
+	SessionTicketSupported bool               `json:"sessionTicketSupported"`
+	ResumptionSupported    bool               `json:"resumptionSupported"`             // a cached ticket/PSK session resumed
+	SecureRenegotiation    bool               `json:"secureRenegotiation"`             // RFC 5746
+	InsecureRenegotiation  *bool              `json:"insecureRenegotiation,omitempty"` // a renegotiation without RFC 5746 was accepted, omitted when unknown
+	ExtendedMasterSecret   bool               `json:"extendedMasterSecret"`            // RFC 7627
+	EncryptThenMAC         bool               `json:"encryptThenMac"`                  // RFC 7366
+	FallbackSCSV           FallbackSCSVStatus `json:"fallbackScsv"`                    // RFC 7507
+	Errors                 map[string]string  `json:"errors"`                          // probe : error
+}
+
+type ServerFingerprintRecord struct {
+	Probes []ProbeFingerprint `json:"probes"`
+	Labels []string           `json:"labels"` // product labels matched in the signature database
+}
+
+type ProbeFingerprint struct {
+	Probe      string `json:"probe"`
+	JA3S       string `json:"ja3s"`
+	JA3SString string `json:"ja3sString"`
+	JA4S       string `json:"ja4s"`
+	JA4SRaw    string `json:"ja4sRaw"`
+	Error      string `json:"error"`
+}
+
+// ConsistencyRecord groups the successfully scanned IPs of a hostname by what they serve
+// and flags the IPs that diverge from the majority on any dimension.
+type ConsistencyRecord struct {
+	Consistent      bool                 `json:"consistent"`
+	LeafCertificate ConsistencyDimension `json:"leafCertificate"` // grouped by leaf SHA-256 fingerprint
+	SPKI            ConsistencyDimension `json:"spki"`            // grouped by SPKI SHA-256 hash
+	CipherSuites    ConsistencyDimension `json:"cipherSuites"`    // grouped by version:suite set
+	Versions        ConsistencyDimension `json:"versions"`        // grouped by supported version set
+	DivergentIPs    []string             `json:"divergentIPs"`    // union over all dimensions
+}
+
+type ConsistencyDimension struct {
+	Groups    map[string][]string `json:"groups"` // value : ips
+	Majority  string              `json:"majority"`
+	Divergent []string            `json:"divergent"`
+}
+
+func newConsistencyDimension(values map[string]string) ConsistencyDimension {
+	dimension := ConsistencyDimension{Groups: make(map[string][]string), Divergent: make([]string, 0)}
+	for ip, value := range values {
+		dimension.Groups[value] = append(dimension.Groups[value], ip)
+	}
+	keys := make([]string, 0, len(dimension.Groups))
+	for value, ips := range dimension.Groups {
+		sort.Strings(ips)
+		keys = append(keys, value)
+	}
+	// Largest group wins, ties are broken on the value to keep the output stable.
+	sort.Strings(keys)
+	for _, value := range keys {
+		if len(dimension.Groups[value]) > len(dimension.Groups[dimension.Majority]) {
+			dimension.Majority = value
+		}
+	}
+	for _, value := range keys {
+		if value != dimension.Majority {
+			dimension.Divergent = append(dimension.Divergent, dimension.Groups[value]...)
+		}
+	}
+	sort.Strings(dimension.Divergent)
+	return dimension
+}
+
+func serializeVersionSuites(records []VersionSuitesRecord) (string, string) {
+	versions := make([]string, 0)
+	suites := make([]string, 0)
+	for _, record := range records {
+		if !record.IsSupported {
+			continue
+		}
+		versions = append(versions, fmt.Sprintf("%04x", record.TLSVersion))
+		versionSuites := make([]string, 0, len(record.SupportedCipherSuites))
+		for _, suite := range record.SupportedCipherSuites {
+			versionSuites = append(versionSuites, fmt.Sprintf("%04x", suite))
+		}
+		sort.Strings(versionSuites)
+		suites = append(suites, fmt.Sprintf("%04x:%s", record.TLSVersion, strings.Join(versionSuites, ",")))
+	}
+	sort.Strings(versions)
+	sort.Strings(suites)
+	return strings.Join(versions, ","), strings.Join(suites, "|")
+}
+
+func (t *TLSCombinedRecord) IdentifyConsistency() {
+	leaves := make(map[string]string)
+	spkis := make(map[string]string)
+	versions := make(map[string]string)
+	suites := make(map[string]string)
+	for ip, certificate := range t.Certificates {
+		leaves[ip] = certificate.SHA256Fingerprint
+		spkis[ip] = certificate.SPKISHA256Hash
+		versions[ip], suites[ip] = serializeVersionSuites(t.CipherSuites[ip])
+	}
+
+	consistency := ConsistencyRecord{
+		LeafCertificate: newConsistencyDimension(leaves),
+		SPKI:            newConsistencyDimension(spkis),
+		CipherSuites:    newConsistencyDimension(suites),
+		Versions:        newConsistencyDimension(versions),
+	}
+	divergent := make(map[string]struct{})
+	for _, dimension := range []ConsistencyDimension{consistency.LeafCertificate, consistency.SPKI, consistency.CipherSuites, consistency.Versions} {
+		for _, ip := range dimension.Divergent {
+			divergent[ip] = struct{}{}
+		}
+	}
+	consistency.DivergentIPs = make([]string, 0, len(divergent))
+	for ip := range divergent {
+		consistency.DivergentIPs = append(consistency.DivergentIPs, ip)
+	}
+	sort.Strings(consistency.DivergentIPs)
+	consistency.Consistent = len(consistency.DivergentIPs) == 0
+
+	t.Consistency = consistency
+}
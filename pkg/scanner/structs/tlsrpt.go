@@ -0,0 +1,51 @@
+package structs
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TLSRPTRecord is the SMTP TLS reporting record at _smtp._tls.<domain> (RFC 8460)
+type TLSRPTRecord struct {
+	Domain    string   `json:"domain"`
+	Found     bool     `json:"found"`
+	TXTRecord string   `json:"txtRecord"`
+	RUA       []string `json:"rua"` // mailto: or https: destinations
+	Errors    []string `json:"errors"`
+}
+
+// IsTLSRPTRecord reports whether a TXT record starts with "v=TLSRPTv1"
+func IsTLSRPTRecord(txt string) bool {
+	tags, _ := parseTagList(txt)
+	return len(tags) > 0 && tags[0].Tag == "v" && tags[0].Value == "TLSRPTv1"
+}
+
+// ParseTLSRPT parses a TLS-RPT record into record, which keeps its domain.
+func ParseTLSRPT(record TLSRPTRecord, txt string) TLSRPTRecord {
+	record.TXTRecord = txt
+	record.RUA = make([]string, 0)
+	if record.Errors == nil {
+		record.Errors = make([]string, 0)
+	}
+	tags, problems := parseTagList(txt)
+	record.Errors = append(record.Errors, problems...)
+	for _, tag := range tags {
+		if tag.Tag != "rua" {
+			continue
+		}
+		for _, uri := range strings.Split(tag.Value, ",") {
+			uri = strings.TrimSpace(uri)
+			parsed, err := url.Parse(uri)
+			if err != nil || (parsed.Scheme != "mailto" && parsed.Scheme != "https") {
+				record.Errors = append(record.Errors, fmt.Sprintf("rua destination %q is not a mailto: or https: URI", uri))
+				continue
+			}
+			record.RUA = append(record.RUA, uri)
+		}
+	}
+	if len(record.RUA) == 0 {
+		record.Errors = append(record.Errors, "no valid rua destination")
+	}
+	return record
+}
@@ -0,0 +1,80 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/storage"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func generateTestCertificate(t *testing.T, commonName string, dnsNames []string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestCertificateStoreDeduplicatesAndReloads(t *testing.T) {
+	store, err := storage.NewCertificateStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := generateTestCertificate(t, "store.example", []string{"store.example"})
+
+	fingerprint, err := store.Put(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint != storage.CertificateFingerprint(cert) {
+		t.Errorf("Unexpected fingerprint. %v != %v\n", fingerprint, storage.CertificateFingerprint(cert))
+	}
+	if _, err := store.PutChain([]*x509.Certificate{cert, cert}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(store.DirectoryPath, fingerprint[:2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected exactly one DER and one PEM file, found %d entries\n", len(entries))
+	}
+
+	reloaded, err := store.Get(fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Equal(cert) {
+		t.Errorf("Reloaded certificate does not match the stored certificate\n")
+	}
+	if _, err := store.Get("00" + fingerprint[2:]); err != storage.ErrCertificateNotFound {
+		t.Errorf("Expected ErrCertificateNotFound, got %v\n", err)
+	}
+}
@@ -0,0 +1,84 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/storage"
+	"Scanner/pkg/scanner/structs"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func tlsaFor(t *testing.T, usage uint8, selector uint8, matchingType uint8, cert *x509.Certificate) *dns.TLSA {
+	data, err := dns.CertificateToDANE(selector, matchingType, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dns.TLSA{Usage: usage, Selector: selector, MatchingType: matchingType, Certificate: data}
+}
+
+func TestEvaluateDANE(t *testing.T) {
+	leaf, _ := generateTestCertificate(t, "mx.example.gov", []string{"mx.example.gov"})
+	issuer, _ := generateTestCertificate(t, "Example Issuing CA", nil)
+	other, _ := generateTestCertificate(t, "other.example.gov", []string{"other.example.gov"})
+
+	spki := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	tlsRecord := &structs.TLSCombinedRecord{
+		Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.1": {SHA256Fingerprint: storage.CertificateFingerprint(leaf), SPKISHA256Hash: hex.EncodeToString(spki[:])},
+		},
+		CertificateChains: map[string][]string{
+			"192.0.2.1": {storage.CertificateFingerprint(leaf), storage.CertificateFingerprint(issuer)},
+		},
+	}
+	chains := map[string][]*x509.Certificate{"192.0.2.1:25": {leaf, issuer}}
+	base := structs.DANERecord{MX: "mx.example.gov.", IPMatches: make(map[string]bool)}
+
+	cases := []struct {
+		name     string
+		tlsa     []*dns.TLSA
+		chains   map[string][]*x509.Certificate
+		expected structs.DANEStatus
+	}{
+		{"DANE-EE SPKI SHA-512", []*dns.TLSA{tlsaFor(t, 3, 1, 2, leaf)}, chains, structs.DANEValid},
+		{"DANE-TA certificate", []*dns.TLSA{tlsaFor(t, 2, 0, 1, issuer)}, chains, structs.DANEValid},
+		{"recorded DANE-EE SPKI", []*dns.TLSA{tlsaFor(t, 3, 1, 1, leaf)}, nil, structs.DANEValid},
+		{"recorded DANE-TA certificate", []*dns.TLSA{tlsaFor(t, 2, 0, 1, issuer)}, nil, structs.DANEValid},
+		{"other certificate", []*dns.TLSA{tlsaFor(t, 3, 0, 1, other)}, chains, structs.DANEMismatched},
+		{"DANE-TA must not match the leaf", []*dns.TLSA{tlsaFor(t, 2, 0, 1, leaf)}, chains, structs.DANEMismatched},
+		{"PKIX-EE is unusable", []*dns.TLSA{tlsaFor(t, 1, 0, 1, leaf)}, chains, structs.DANEMismatched},
+	}
+	for _, c := range cases {
+		record := network.EvaluateDANE(base, c.tlsa, tlsRecord, c.chains)
+		if record.Status != c.expected {
+			t.Errorf("%s: expected %s, got %s\n", c.name, c.expected, record.Status)
+		}
+	}
+
+	if record := network.EvaluateDANE(base, []*dns.TLSA{tlsaFor(t, 3, 1, 1, leaf)}, nil, nil); record.Status != structs.DANEUnchecked {
+		t.Errorf("Expected an unscanned port 25 to leave DANE unchecked, got %s\n", record.Status)
+	}
+}
+
+func TestDANELookupStatus(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected structs.DANEStatus
+	}{
+		{&network.DenialError{Denial: structs.DenialRecord{Status: structs.DenialSecure}, Err: network.ErrNoResult}, structs.DANEAbsent},
+		{&network.DenialError{Denial: structs.DenialRecord{Status: structs.DenialBogus}, Err: network.ErrBogusDenial}, structs.DANEBogus},
+		{network.ErrResourceNotSigned, structs.DANEInsecure},
+		{network.ErrTrustAnchorMismatch, structs.DANEBogus},
+		{network.ErrNsNotAvailable, structs.DANEError},
+		{errors.New("read udp 127.0.0.1:53: i/o timeout"), structs.DANEError},
+	}
+	for _, c := range cases {
+		if status := network.DANELookupStatus(c.err); status != c.expected {
+			t.Errorf("%v: expected %s, got %s\n", c.err, c.expected, status)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func rsaDKIMKey(t *testing.T, bits int) string {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestDKIMProbe(t *testing.T) {
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strong := rsaDKIMKey(t, 2048)
+	resolver := staticTXTResolver{
+		"selector1._domainkey.agency.gov": {"v=DKIM1; k=rsa; p=" + strong[:100] + " " + strong[100:]},
+		"google._domainkey.agency.gov":    {"v=DKIM1; k=rsa; t=y:s; p=" + rsaDKIMKey(t, 512)},
+		"k1._domainkey.agency.gov":        {"v=DKIM1; k=ed25519; h=sha256; p=" + base64.StdEncoding.EncodeToString(edKey)},
+		"old._domainkey.agency.gov":       {"v=DKIM1; p="},
+		"bad._domainkey.agency.gov":       {"v=DKIM1; k=rsa; p=bm90IGEga2V5"},
+		"spf._domainkey.agency.gov":       {"v=spf1 -all"},
+	}
+	record := network.NewDKIMProber(resolver, []string{"selector1", "google", "K1", "k1", "old", "bad", "spf", "missing"}).Probe("agency.gov.")
+	if record.SelectorsProbed != 7 || len(record.Keys) != 5 || len(record.Errors) != 0 {
+		t.Fatalf("Unexpected probe result %+v\n", record)
+	}
+
+	if key := record.Keys["selector1"]; key.KeyType != "rsa" || key.KeyBits != 2048 || key.Weak || key.Testing || key.Revoked || len(key.Errors) != 0 {
+		t.Errorf("Unexpected 2048 bit key %+v\n", key)
+	}
+	if key := record.Keys["google"]; key.KeyBits != 512 || !key.Weak || !key.Testing || !key.StrictSubdomains {
+		t.Errorf("Expected a weak key in testing mode %+v\n", key)
+	}
+	if key := record.Keys["k1"]; key.KeyType != "ed25519" || key.KeyBits != 256 || len(key.HashAlgorithms) != 1 || key.Name != "k1._domainkey.agency.gov" {
+		t.Errorf("Unexpected ed25519 key %+v\n", key)
+	}
+	if key := record.Keys["old"]; !key.Revoked {
+		t.Errorf("Expected a revoked key %+v\n", key)
+	}
+	if key := record.Keys["bad"]; len(key.Errors) != 1 || key.KeyBits != 0 {
+		t.Errorf("Expected an unparseable key %+v\n", key)
+	}
+}
+
+func TestLoadDKIMSelectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selectors.txt")
+	if err := os.WriteFile(path, []byte("# selectors\nselector1\n\n  google  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	selectors, err := network.LoadDKIMSelectors(path)
+	if err != nil || len(selectors) != 2 || selectors[1] != "google" {
+		t.Errorf("Unexpected selectors %v %v\n", selectors, err)
+	}
+}
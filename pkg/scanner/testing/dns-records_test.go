@@ -0,0 +1,85 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+var recordsZone = []string{
+	`www.example.gov. 600 IN CNAME edge.example.gov.`,
+	`edge.example.gov. 120 IN CNAME edge.cdn.test.`,
+	`edge.cdn.test. 60 IN A 192.0.2.80`,
+	`example.gov. 3600 IN A 192.0.2.10`,
+	`example.gov. 300 IN A 192.0.2.11`,
+	`example.gov. 3600 IN AAAA 2001:db8::10`,
+	`example.gov. 86400 IN NS ns1.example.gov.`,
+	`example.gov. 3600 IN SOA ns1.example.gov. hostmaster.example.gov. 2024060101 7200 3600 1209600 300`,
+	`example.gov. 3600 IN MX 10 mx1.example.gov.`,
+	`example.gov. 3600 IN CAA 0 issue "letsencrypt.org"`,
+	`example.gov. 3600 IN HTTPS 1 . alpn="h2,h3"`,
+	`_443._tcp.example.gov. 3600 IN TLSA 3 1 1 8cb0fc6c527506a053f4f14c8464bebbd6dede2738d11468dd953d7d6a3021f1`,
+	`loop1.example.gov. 300 IN CNAME loop2.example.gov.`,
+	`loop2.example.gov. 300 IN CNAME loop1.example.gov.`,
+}
+
+func TestDNSRecordCollection(t *testing.T) {
+	collector := network.NewDNSRecordCollector(network.NewMailResolver(startLocalDNSServer(t, recordsZone)), true)
+	validated := make([]string, 0)
+	collector.Validate = func(name string, queryType uint16, reply *dns.Msg) structs.DNSSECRecord {
+		// Validation uses the reply the records were read from
+		if len(reply.Question) != 1 || reply.Question[0].Name != name || reply.Question[0].Qtype != queryType {
+			t.Errorf("Unexpected reply %v for %s %d\n", reply.Question, name, queryType)
+		}
+		validated = append(validated, name+" "+dns.TypeToString[queryType])
+		return structs.DNSSECRecord{Reason: network.ErrResourceNotSigned.Error()}
+	}
+
+	types, err := network.ParseDNSRecordTypes("a, aaaa,NS,SOA,MX,CAA,HTTPS,TXT,TLSA,A")
+	if err != nil || len(types) != 9 {
+		t.Fatalf("Unexpected types %v %v\n", types, err)
+	}
+	records, chain := collector.Collect("example.gov", types)
+	// TLSA is looked up for 443 and 25
+	if len(records) != 10 || len(validated) != 10 || len(chain) != 0 {
+		t.Fatalf("Expected 10 record sets, got %d, %d validated, chain %v\n", len(records), len(validated), chain)
+	}
+	if a := records[0]; a.Name != "example.gov." || a.Type != "A" || len(a.Records) != 2 || a.TTL != 300 || a.Records[0].Data != "192.0.2.10" {
+		t.Errorf("Unexpected A records %+v\n", a)
+	}
+	if soa := records[3]; len(soa.Records) != 1 || soa.Records[0].Data != "ns1.example.gov. hostmaster.example.gov. 2024060101 7200 3600 1209600 300" {
+		t.Errorf("Unexpected SOA records %+v\n", soa)
+	}
+	if caa := records[5]; len(caa.Records) != 1 || caa.Records[0].Data != `0 issue "letsencrypt.org"` {
+		t.Errorf("Unexpected CAA records %+v\n", caa)
+	}
+	if https := records[6]; len(https.Records) != 1 || https.TTL != 3600 {
+		t.Errorf("Unexpected HTTPS records %+v\n", https)
+	}
+	if txt := records[7]; len(txt.Records) != 0 || txt.TTL != 0 || len(txt.Error) != 0 {
+		t.Errorf("Expected no TXT records, got %+v\n", txt)
+	}
+	if tlsa := records[8]; tlsa.Name != "_443._tcp.example.gov." || len(tlsa.Records) != 1 || tlsa.DNSSEC.Reason != network.ErrResourceNotSigned.Error() {
+		t.Errorf("Unexpected TLSA records %+v\n", tlsa)
+	}
+	if tlsa := records[9]; tlsa.Name != "_25._tcp.example.gov." || tlsa.Error != "NXDOMAIN" {
+		t.Errorf("Expected no TLSA records for port 25, got %+v\n", tlsa)
+	}
+
+	records, chain = collector.Collect("www.example.gov.", []uint16{dns.TypeCNAME})
+	if len(chain) != 2 || chain[1].Name != "edge.example.gov." || chain[1].Target != "edge.cdn.test." || chain[1].TTL != 120 {
+		t.Errorf("Unexpected CNAME chain %+v\n", chain)
+	}
+	if len(records) != 1 || len(records[0].Records) != 1 || records[0].Records[0].Data != "edge.example.gov." {
+		t.Errorf("Unexpected CNAME records %+v\n", records)
+	}
+	if _, chain := collector.Collect("loop1.example.gov.", []uint16{dns.TypeCNAME}); len(chain) != 2 {
+		t.Errorf("Expected the CNAME loop to stop at the repeated name, got %+v\n", chain)
+	}
+
+	if _, err := network.ParseDNSRecordTypes("A,BOGUS"); err == nil {
+		t.Errorf("Expected an unknown type to be rejected\n")
+	}
+}
@@ -0,0 +1,133 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// negativeAnswer builds a reply carrying records in its authority section, every NSEC and NSEC3 signed
+// unless unsigned is set
+func negativeAnswer(t *testing.T, rcode int, unsigned bool, records ...string) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Rcode = rcode
+	for _, record := range records {
+		rr := mustRR(t, record)
+		msg.Ns = append(msg.Ns, rr)
+		if rrType := rr.Header().Rrtype; !unsigned && (rrType == dns.TypeNSEC || rrType == dns.TypeNSEC3) {
+			msg.Ns = append(msg.Ns, mustRR(t, fmt.Sprintf("%s 300 IN RRSIG %s 13 3 300 20300101000000 20200101000000 12345 example.gov. AAAA",
+				rr.Header().Name, dns.TypeToString[rrType])))
+		}
+	}
+	return msg
+}
+
+func verifiedProof(*network.RRSet) error { return nil }
+
+const signedSOA = `example.gov. 300 IN SOA ns1.example.gov. hostmaster.example.gov. 1 7200 3600 1209600 300`
+
+func TestNSECDenial(t *testing.T) {
+	apex := `example.gov. 300 IN NSEC a.example.gov. SOA NS DNSKEY RRSIG NSEC`
+	a := `a.example.gov. 300 IN NSEC m.example.gov. A RRSIG NSEC`
+	cases := []struct {
+		name     string
+		qname    string
+		qtype    uint16
+		msg      *dns.Msg
+		expected string
+	}{
+		{"nxdomain", "b.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, false, signedSOA, apex, a), structs.DenialSecure},
+		{"nxdomain without wildcard proof", "b.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, false, signedSOA, a), structs.DenialBogus},
+		{"nodata", "a.example.gov.", dns.TypeTXT, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, a), structs.DenialSecure},
+		{"nodata for a listed type", "a.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, a), structs.DenialBogus},
+		{"empty non-terminal", "b.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA,
+			`a.example.gov. 300 IN NSEC c.b.example.gov. A RRSIG NSEC`), structs.DenialSecure},
+		{"unsigned proof", "a.example.gov.", dns.TypeTXT, negativeAnswer(t, dns.RcodeSuccess, true, signedSOA, a), structs.DenialBogus},
+		{"unsigned zone", "b.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, true, signedSOA), structs.DenialInsecureDelegation},
+	}
+	for _, c := range cases {
+		denial := network.EvaluateDenial(c.qname, c.qtype, c.msg, verifiedProof)
+		if denial.Status != c.expected {
+			t.Errorf("%s: expected %s, got %+v\n", c.name, c.expected, denial)
+		}
+	}
+
+	denial := network.EvaluateDenial("b.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, false, signedSOA, apex, a), verifiedProof)
+	if denial.Type != structs.DenialNXDomain || denial.Proof != structs.DenialNSEC || denial.Zone != "example.gov." || denial.ClosestEncloser != "example.gov." {
+		t.Errorf("Unexpected denial %+v\n", denial)
+	}
+
+	// A signed SOA without proofs, or a proof failing validation, is bogus
+	signed := negativeAnswer(t, dns.RcodeNameError, true, signedSOA)
+	signed.Ns = append(signed.Ns, mustRR(t, `example.gov. 300 IN RRSIG SOA 13 2 300 20300101000000 20200101000000 12345 example.gov. AAAA`))
+	if denial := network.EvaluateDenial("b.example.gov.", dns.TypeA, signed, verifiedProof); denial.Status != structs.DenialBogus {
+		t.Errorf("Expected a signed zone without proof to be bogus, got %+v\n", denial)
+	}
+	failing := func(*network.RRSet) error { return network.ErrTrustAnchorMismatch }
+	if denial := network.EvaluateDenial("a.example.gov.", dns.TypeTXT, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, a), failing); denial.Status != structs.DenialBogus ||
+		!strings.Contains(denial.Reason, network.ErrTrustAnchorMismatch.Error()) {
+		t.Errorf("Expected a proof failing validation to be bogus, got %+v\n", denial)
+	}
+}
+
+// nsec3 returns an NSEC3 record for name whose next hashed owner is the hash of next
+func nsec3(name string, next string, flags int, iterations uint16, salt string, types string) string {
+	hashSalt := salt
+	if salt == "-" {
+		hashSalt = ""
+	}
+	return fmt.Sprintf("%s.example.gov. 300 IN NSEC3 1 %d %d %s %s %s", strings.ToLower(dns.HashName(name, dns.SHA1, iterations, hashSalt)),
+		flags, iterations, salt, dns.HashName(next, dns.SHA1, iterations, hashSalt), types)
+}
+
+func TestNSEC3Denial(t *testing.T) {
+	// A single NSEC3 at the apex covers every other hash
+	apex := nsec3("example.gov.", "example.gov.", 0, 10, "AABB", "SOA NS DNSKEY RRSIG NSEC3PARAM")
+	denial := network.EvaluateDenial("nope.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, false, signedSOA, apex), verifiedProof)
+	if denial.Status != structs.DenialSecure || denial.Proof != structs.DenialNSEC3 || denial.ClosestEncloser != "example.gov." {
+		t.Errorf("Expected the closest encloser proof to hold, got %+v\n", denial)
+	}
+	if denial.NSEC3Iterations != 10 || denial.NSEC3Salt != "aabb" || denial.NSEC3Algorithm != dns.SHA1 {
+		t.Errorf("Expected the NSEC3 parameters to be recorded, got %+v\n", denial)
+	}
+
+	optOut := nsec3("example.gov.", "example.gov.", 1, 0, "-", "SOA NS DNSKEY RRSIG NSEC3PARAM")
+	denial = network.EvaluateDenial("nope.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, false, signedSOA, optOut), verifiedProof)
+	if denial.Status != structs.DenialInsecureDelegation || !denial.OptOut || denial.NSEC3Iterations != 0 || len(denial.NSEC3Salt) != 0 {
+		t.Errorf("Expected the opt-out span to be insecure, got %+v\n", denial)
+	}
+	denial = network.EvaluateDenial("child.example.gov.", dns.TypeDS, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, optOut), verifiedProof)
+	if denial.Status != structs.DenialInsecureDelegation {
+		t.Errorf("Expected a DS inside an opt-out span to be an insecure delegation, got %+v\n", denial)
+	}
+
+	host := nsec3("a.example.gov.", "example.gov.", 0, 0, "-", "A RRSIG")
+	if denial := network.EvaluateDenial("a.example.gov.", dns.TypeTXT, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, host), verifiedProof); denial.Status != structs.DenialSecure {
+		t.Errorf("Expected the NODATA proof to hold, got %+v\n", denial)
+	}
+	if denial := network.EvaluateDenial("a.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, host), verifiedProof); denial.Status != structs.DenialBogus {
+		t.Errorf("Expected a NODATA for a listed type to be bogus, got %+v\n", denial)
+	}
+
+	wildcard := nsec3("*.example.gov.", "example.gov.", 0, 0, "-", "A RRSIG")
+	apexNoSalt := nsec3("example.gov.", "*.example.gov.", 0, 0, "-", "SOA NS DNSKEY RRSIG NSEC3PARAM")
+	if denial := network.EvaluateDenial("nope.example.gov.", dns.TypeA, negativeAnswer(t, dns.RcodeNameError, false, signedSOA, apexNoSalt, wildcard), verifiedProof); denial.Status != structs.DenialBogus {
+		t.Errorf("Expected an existing wildcard to make the NXDOMAIN bogus, got %+v\n", denial)
+	}
+
+	mixed := nsec3("a.example.gov.", "example.gov.", 0, 5, "-", "A RRSIG")
+	if denial := network.EvaluateDenial("a.example.gov.", dns.TypeTXT, negativeAnswer(t, dns.RcodeSuccess, false, signedSOA, host, mixed), verifiedProof); denial.Status != structs.DenialBogus {
+		t.Errorf("Expected mixed NSEC3 parameters to be bogus, got %+v\n", denial)
+	}
+
+	var denialErr *network.DenialError
+	err := error(&network.DenialError{Denial: structs.DenialRecord{Status: structs.DenialBogus}, Err: network.ErrBogusDenial})
+	if !errors.As(err, &denialErr) || !errors.Is(err, network.ErrBogusDenial) || errors.Is(err, network.ErrNoResult) {
+		t.Errorf("Expected the denial error to unwrap to its cause\n")
+	}
+}
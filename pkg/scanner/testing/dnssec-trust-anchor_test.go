@@ -0,0 +1,159 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rootKey generates a root KSK and returns it with a function signing RRsets with it
+func rootKey(t *testing.T) (*dns.DNSKEY, func([]dns.RR) *dns.RRSIG) {
+	key := &dns.DNSKEY{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 172800}, Flags: 257, Protocol: 3, Algorithm: dns.ECDSAP256SHA256}
+	privateKey, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, func(rrSet []dns.RR) *dns.RRSIG {
+		signature := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 172800},
+			KeyTag:     key.KeyTag(),
+			SignerName: ".",
+			Algorithm:  key.Algorithm,
+			Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+			Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+		}
+		if err := signature.Sign(privateKey.(crypto.Signer), rrSet); err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+// signedRoot returns a root zone signed by a freshly generated KSK and a TXT answer signed by it
+func signedRoot(t *testing.T) (network.AuthenticationChain, *network.RRSet, *dns.DNSKEY) {
+	key, sign := rootKey(t)
+	dnskeys := []dns.RR{key}
+	root := network.SignedZone{
+		Zone:         ".",
+		Dnskey:       &network.RRSet{RrSet: dnskeys, RrSig: sign(dnskeys)},
+		Ds:           &network.RRSet{},
+		PubKeyLookup: map[uint16]*dns.DNSKEY{key.KeyTag(): key},
+	}
+	answer := []dns.RR{mustRR(t, `. 300 IN TXT "anchored"`)}
+	return network.AuthenticationChain{DelegationChain: []network.SignedZone{root}}, &network.RRSet{RrSet: answer, RrSig: sign(answer)}, key
+}
+
+func TestTrustAnchorEnforcement(t *testing.T) {
+	defaults := network.RootTrustAnchors
+	t.Cleanup(func() { network.RootTrustAnchors = defaults })
+	chain, answer, key := signedRoot(t)
+
+	// A self-consistent root that is not the IANA root fails
+	if err := chain.Verify(answer); !errors.Is(err, network.ErrTrustAnchorMismatch) {
+		t.Errorf("Expected a spoofed root key to fail against the built-in anchors, got %v\n", err)
+	}
+
+	dsAnchors, err := network.ParseTrustAnchorRecords([]byte(key.ToDS(dns.SHA256).String()))
+	if err != nil || len(dsAnchors) != 1 {
+		t.Fatalf("Unexpected DS anchors %+v %v\n", dsAnchors, err)
+	}
+	// Any one anchor is enough, as during a rollover
+	network.RootTrustAnchors = append(network.DefaultRootTrustAnchors(), dsAnchors...)
+	if err := chain.Verify(answer); err != nil {
+		t.Errorf("Expected the DS anchor to validate, got %v\n", err)
+	}
+
+	network.RootTrustAnchors, err = network.ParseTrustAnchorRecords([]byte(key.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Verify(answer); err != nil {
+		t.Errorf("Expected the DNSKEY anchor to validate, got %v\n", err)
+	}
+
+	if _, err := network.ParseTrustAnchorRecords([]byte(". 300 IN A 192.0.2.1")); err == nil {
+		t.Errorf("Expected A records to be rejected as trust anchors\n")
+	}
+}
+
+func TestTrustAnchorRollover(t *testing.T) {
+	defaults := network.RootTrustAnchors
+	t.Cleanup(func() { network.RootTrustAnchors = defaults })
+	anchored, signAnchored := rootKey(t)
+	standby, signStandby := rootKey(t)
+	var err error
+	if network.RootTrustAnchors, err = network.ParseTrustAnchorRecords([]byte(anchored.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	// The RRset is signed by both keys, the RRSIG of the key that is not anchored comes last
+	dnskeys := []dns.RR{anchored, standby}
+	signatures := []*dns.RRSIG{signAnchored(dnskeys), signStandby(dnskeys)}
+	answer := []dns.RR{mustRR(t, `. 300 IN TXT "rollover"`)}
+	root := network.SignedZone{
+		Zone:         ".",
+		Dnskey:       &network.RRSet{RrSet: dnskeys, RrSig: signatures[1], RrSigs: signatures},
+		Ds:           &network.RRSet{},
+		PubKeyLookup: map[uint16]*dns.DNSKEY{anchored.KeyTag(): anchored, standby.KeyTag(): standby},
+	}
+	chain := network.AuthenticationChain{DelegationChain: []network.SignedZone{root}}
+	if err := chain.Verify(&network.RRSet{RrSet: answer, RrSig: signStandby(answer)}); err != nil {
+		t.Errorf("Expected the RRSIG of the anchored key to validate, got %v\n", err)
+	}
+
+	root.Dnskey = &network.RRSet{RrSet: dnskeys, RrSig: signatures[1], RrSigs: signatures[1:]}
+	chain = network.AuthenticationChain{DelegationChain: []network.SignedZone{root}}
+	if err := chain.Verify(&network.RRSet{RrSet: answer, RrSig: signStandby(answer)}); !errors.Is(err, network.ErrTrustAnchorMismatch) {
+		t.Errorf("Expected a root signed only by the standby key to fail, got %v\n", err)
+	}
+}
+
+func TestLoadRootAnchorsXML(t *testing.T) {
+	defaults := network.RootTrustAnchors
+	t.Cleanup(func() { network.RootTrustAnchors = defaults })
+	chain, answer, key := signedRoot(t)
+	ds := key.ToDS(dns.SHA256)
+
+	keyDigest := func(id string, validity string, keyTag uint16, digest string) string {
+		return fmt.Sprintf(`<KeyDigest id="%s" %s><KeyTag>%d</KeyTag><Algorithm>%d</Algorithm><DigestType>2</DigestType><Digest>%s</Digest></KeyDigest>`,
+			id, validity, keyTag, key.Algorithm, digest)
+	}
+	write := func(name string, digests string) string {
+		path := filepath.Join(t.TempDir(), name)
+		document := `<?xml version="1.0" encoding="UTF-8"?><TrustAnchor id="test" source="test"><Zone>.</Zone>` + digests + `</TrustAnchor>`
+		if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	expired := keyDigest("old", `validFrom="2010-07-15T00:00:00+00:00" validUntil="2019-01-11T00:00:00+00:00"`, ds.KeyTag, ds.Digest)
+	anchors, err := network.LoadTrustAnchors(write("expired.xml", expired))
+	if err != nil || len(anchors) != 1 || anchors[0].ValidUntil.Year() != 2019 {
+		t.Fatalf("Unexpected anchors %+v %v\n", anchors, err)
+	}
+	network.RootTrustAnchors = anchors
+	if err := chain.Verify(answer); !errors.Is(err, network.ErrTrustAnchorMismatch) {
+		t.Errorf("Expected an expired anchor to be ignored, got %v\n", err)
+	}
+
+	current := keyDigest("new", `validFrom="2017-02-02T00:00:00+00:00"`, ds.KeyTag, ds.Digest)
+	network.RootTrustAnchors, err = network.LoadTrustAnchors(write("root-anchors.xml", keyDigest("other", "", 20326, "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D")+current))
+	if err != nil || len(network.RootTrustAnchors) != 2 {
+		t.Fatalf("Unexpected anchors %+v %v\n", network.RootTrustAnchors, err)
+	}
+	if err := chain.Verify(answer); err != nil {
+		t.Errorf("Expected the current anchor to validate, got %v\n", err)
+	}
+
+	if _, err := network.LoadTrustAnchors(write("empty.xml", "")); err == nil {
+		t.Errorf("Expected a file without anchors to be rejected\n")
+	}
+}
@@ -0,0 +1,47 @@
+package testing
+
+import (
+	"Scanner/localtls"
+	"Scanner/pkg/scanner/network"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckCertEVStatus(t *testing.T) {
+	entitledRoot := &x509.Certificate{Raw: []byte("entitled root")}
+	otherRoot := &x509.Certificate{Raw: []byte("other root")}
+	fingerprint := sha256.Sum256(entitledRoot.Raw)
+	registry, err := localtls.ParsePolicyRegistryJSON(strings.NewReader(fmt.Sprintf(`{"policies": [
+		{"oid": "1.2.3.4", "caOwner": "Unrelated CA", "rootFingerprints": ["00"]},
+		{"oid": "2.23.140.1.1", "caOwner": "Example CA", "rootFingerprints": ["%s"]}]}`, hex.EncodeToString(fingerprint[:]))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := localtls.EVPolicyRegistry
+	localtls.EVPolicyRegistry = registry
+	t.Cleanup(func() { localtls.EVPolicyRegistry = defaults })
+
+	leaf := &x509.Certificate{PolicyIdentifiers: []asn1.ObjectIdentifier{{1, 2, 3, 4}, {2, 23, 140, 1, 1}}}
+	// The entitled OID wins over an earlier one, its fields are not mixed with the other OID's
+	information := network.CheckCertEVStatus(leaf, [][]*x509.Certificate{{leaf, entitledRoot}})
+	if !information.IsEVCertType || !information.RootEntitled || information.ObjectIdentifier != "2.23.140.1.1" || information.IssuingOrganization != "Example CA" {
+		t.Errorf("Expected the entitled OID to be reported, got %+v\n", information)
+	}
+
+	// Root data without an entitled root is not EV
+	information = network.CheckCertEVStatus(leaf, [][]*x509.Certificate{{leaf, otherRoot}})
+	if information.IsEVCertType || information.RootEntitled || !information.RootVerifiable || information.ObjectIdentifier != "1.2.3.4" {
+		t.Errorf("Expected an unentitled root to disqualify EV, got %+v\n", information)
+	}
+
+	// OIDs without root data keep counting as EV from the OID alone
+	builtin := &x509.Certificate{PolicyIdentifiers: []asn1.ObjectIdentifier{{2, 16, 756, 1, 89, 1, 2, 1, 1}}}
+	if information := network.CheckCertEVStatus(builtin, nil); !information.IsEVCertType || information.RootVerifiable {
+		t.Errorf("Expected a built-in OID without root data to be EV, got %+v\n", information)
+	}
+}
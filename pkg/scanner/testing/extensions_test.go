@@ -0,0 +1,73 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/structs"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCertificateExtensionSerialization(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustStaple, _ := asn1.Marshal([]int{5})
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ext.example"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{structs.OIDDomainValidated, structs.OIDOrganizationValidated, {1, 2, 3, 4}},
+		OCSPServer:            []string{"http://ocsp.example"},
+		IssuingCertificateURL: []string{"http://ca.example/issuer.crt"},
+		CRLDistributionPoints: []string{"http://ca.example/ca.crl"},
+		SubjectKeyId:          []byte{0xab, 0xcd},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            0,
+		MaxPathLenZero:        true,
+		PermittedDNSDomains:   []string{".gov"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: structs.OIDExtensionTLSFeature, Value: mustStaple},
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5}, Critical: true, Value: []byte{0x05, 0x00}},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extensions := structs.SerializeCertificateExtensions(cert)
+	if extensions.ValidationLevel != structs.ValidationOrganization {
+		t.Errorf("Expected OV validation level, got %v\n", extensions.ValidationLevel)
+	}
+	if len(extensions.Policies) != 3 || extensions.Policies[2].Type != structs.ValidationUnknown {
+		t.Errorf("Unexpected policies %v\n", extensions.Policies)
+	}
+	if !extensions.MustStaple {
+		t.Errorf("Expected must-staple to be detected\n")
+	}
+	if extensions.BasicConstraints.MaxPathLen != 0 || !extensions.BasicConstraints.IsCA {
+		t.Errorf("Unexpected basic constraints %v\n", extensions.BasicConstraints)
+	}
+	if extensions.NameConstraints == nil || extensions.NameConstraints.PermittedDNSDomains[0] != ".gov" {
+		t.Errorf("Expected name constraints to be captured\n")
+	}
+	if extensions.SubjectKeyID != "abcd" {
+		t.Errorf("Unexpected subject key id %v\n", extensions.SubjectKeyID)
+	}
+	if len(extensions.UnknownCriticalExtensions) != 1 || extensions.UnknownCriticalExtensions[0] != "1.2.3.4.5" {
+		t.Errorf("Unexpected unknown critical extensions %v\n", extensions.UnknownCriticalExtensions)
+	}
+}
@@ -0,0 +1,170 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// startFakeSMTPServer serves a minimal ESMTP dialogue on a loopback port, wrapped in TLS from
+// the first byte when implicit is set, otherwise upgraded on STARTTLS. Returns the port.
+func startFakeSMTPServer(t *testing.T, implicit bool) string {
+	cert, key := generateTestCertificate(t, "localhost", []string{"localhost"})
+	cfg := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSMTP(conn, cfg, implicit)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func serveFakeSMTP(conn net.Conn, cfg *tls.Config, implicit bool) {
+	defer conn.Close()
+	upgraded := implicit
+	if implicit {
+		conn = tls.Server(conn, cfg)
+	}
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "220 mx.example.gov ESMTP fake\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			if upgraded {
+				fmt.Fprintf(conn, "250-mx.example.gov\r\n250-SIZE 1000\r\n250-PIPELINING\r\n250 AUTH PLAIN\r\n")
+			} else {
+				fmt.Fprintf(conn, "250-mx.example.gov\r\n250-SIZE 1000\r\n250-AUTH=LOGIN\r\n250 STARTTLS\r\n")
+			}
+		case command == "STARTTLS" && !upgraded:
+			fmt.Fprintf(conn, "220 ready\r\n")
+			conn = tls.Server(conn, cfg)
+			reader = bufio.NewReader(conn)
+			upgraded = true
+		case command == "QUIT":
+			fmt.Fprintf(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprintf(conn, "502 unsupported\r\n")
+		}
+	}
+}
+
+func TestDetectSMTPConnectionMode(t *testing.T) {
+	ips := []net.IP{net.ParseIP("127.0.0.1")}
+
+	startTLSPort := startFakeSMTPServer(t, false)
+	if mode := network.DetectSMTPConnectionMode(ips, "localhost", startTLSPort); mode != structs.ModeSTARTTLS {
+		t.Errorf("Expected STARTTLS, got %s\n", mode)
+	}
+
+	// The implicit port must be tried implicit first, STARTTLS would wait for a banner that never comes
+	implicitPort := startFakeSMTPServer(t, true)
+	portNumber, _ := strconv.Atoi(implicitPort)
+	network.ImplicitTLSPorts[portNumber] = true
+	t.Cleanup(func() { delete(network.ImplicitTLSPorts, portNumber) })
+	if mode := network.DetectSMTPConnectionMode(ips, "localhost", implicitPort); mode != structs.ModeImplicitTLS {
+		t.Errorf("Expected implicit TLS, got %s\n", mode)
+	}
+
+	metadata := network.GetSMTPBannerAndCapabilities(net.JoinHostPort("127.0.0.1", implicitPort), "localhost", structs.ModeImplicitTLS)
+	if !strings.Contains(metadata.Banner, "ESMTP") || metadata.Capabilities["AUTH"] != "PLAIN" {
+		t.Errorf("Unexpected implicit TLS metadata %+v\n", metadata)
+	}
+}
+
+func TestReachabilityReportsPortModes(t *testing.T) {
+	record := structs.MailScanCombinedRecord{
+		MailServerMetadata: map[string]structs.SMTPMetadata{
+			"mx.example.gov:25":  {},
+			"mx.example.gov:465": {},
+		},
+		MXTLSInformation: map[string]structs.TLSCombinedRecord{
+			"mx.example.gov:25":  {ConnectionMode: structs.ModeSTARTTLS},
+			"mx.example.gov:465": {ConnectionMode: structs.ModeImplicitTLS},
+		},
+	}
+	record.IdentifyReachableAndSecurePorts()
+	modes := record.MXServerReachability["mx.example.gov"].PortModes
+	if modes[25] != structs.ModeSTARTTLS || modes[465] != structs.ModeImplicitTLS {
+		t.Errorf("Unexpected port modes %v\n", modes)
+	}
+}
+
+func TestDetectMailEndpointsPerIP(t *testing.T) {
+	startTLSPort, _ := strconv.Atoi(startFakeSMTPServer(t, false))
+	// Reserve a port and release it so nothing listens on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	smtpPorts := network.SMTPPorts
+	network.SMTPPorts = []int{startTLSPort, closedPort}
+	t.Cleanup(func() { network.SMTPPorts = smtpPorts })
+
+	endpoints := network.DetectMailEndpoints("localhost", []net.IP{net.ParseIP("127.0.0.1")})
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected one endpoint per port, got %+v\n", endpoints)
+	}
+	for _, endpoint := range endpoints {
+		switch endpoint.Port {
+		case startTLSPort:
+			if !endpoint.Open || endpoint.Mode != structs.ModeSTARTTLS {
+				t.Errorf("Unexpected open endpoint %+v\n", endpoint)
+			}
+		case closedPort:
+			if endpoint.Open || endpoint.Mode != "" {
+				t.Errorf("Unexpected closed endpoint %+v\n", endpoint)
+			}
+		}
+	}
+}
+
+func TestReachabilityFromEndpoints(t *testing.T) {
+	record := structs.MailScanCombinedRecord{
+		Endpoints: []structs.MailEndpointRecord{
+			{MX: "mx.example.gov", IP: "192.0.2.1", Port: 25, Open: true, Mode: structs.ModeSTARTTLS, TLSSuccess: true},
+			{MX: "mx.example.gov", IP: "192.0.2.1", Port: 465, Open: false},
+			{MX: "mx.example.gov", IP: "192.0.2.2", Port: 25, Open: true, Mode: structs.ModeUnknown, TLSError: "timeout"},
+			{MX: "mx.example.gov", IP: "192.0.2.2", Port: 465, Open: true, Mode: structs.ModeImplicitTLS, TLSSuccess: true},
+		},
+		// Host:port data is superseded by the endpoints
+		MailServerMetadata: map[string]structs.SMTPMetadata{"mx.example.gov:587": {}},
+	}
+	record.IdentifyReachableAndSecurePorts()
+	reachability := record.MXServerReachability["mx.example.gov"]
+	if !reflect.DeepEqual(reachability.ReachablePorts, []int{25, 465}) || !reflect.DeepEqual(reachability.SecurePorts, []int{25, 465}) {
+		t.Errorf("Unexpected MX reachability %+v\n", reachability)
+	}
+	second := reachability.IPs["192.0.2.2"]
+	if !reflect.DeepEqual(second.SecurePorts, []int{465}) || second.PortModes[25] != structs.ModeUnknown {
+		t.Errorf("Unexpected per IP reachability %+v\n", second)
+	}
+	if first := reachability.IPs["192.0.2.1"]; !reflect.DeepEqual(first.ReachablePorts, []int{25}) {
+		t.Errorf("Unexpected per IP reachability %+v\n", first)
+	}
+}
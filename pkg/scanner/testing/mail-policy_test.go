@@ -0,0 +1,77 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"reflect"
+	"testing"
+)
+
+func TestDMARCPolicy(t *testing.T) {
+	resolver := staticTXTResolver{
+		"_dmarc.agency.gov":                     {"v=DMARC1; p=reject; sp=quarantine; pct=50; adkim=s; rua=mailto:dmarc@agency.gov,mailto:reports@vendor.test!10m; ruf=mailto:ruf@other.test; fo=1:d"},
+		"agency.gov._report._dmarc.vendor.test": {"v=DMARC1"},
+		"_dmarc.example.gov":                    {"v=DMARC1; p=none; sp=reject"},
+		"_dmarc.twice.gov":                      {"v=DMARC1; p=none", "v=DMARC1; p=reject"},
+		"_dmarc.broken.gov":                     {"v=DMARC1; p=block; pct=150; rua=ftp://x"},
+	}
+	checker := network.NewMailPolicyChecker(resolver)
+
+	record := checker.DMARC("agency.gov.")
+	if !record.Found || record.RecordDomain != "agency.gov" || record.Policy != structs.DMARCPolicyReject || record.SubdomainPolicy != structs.DMARCPolicyQuarantine {
+		t.Fatalf("Unexpected record %+v\n", record)
+	}
+	if record.Percentage != 50 || record.Enforced || record.DKIMAlignment != "s" || record.SPFAlignment != "r" || !reflect.DeepEqual(record.FailureOptions, []string{"1", "d"}) || len(record.Errors) != 0 {
+		t.Errorf("Unexpected tags %+v\n", record)
+	}
+	if len(record.AggregateReports) != 2 || len(record.FailureReports) != 1 {
+		t.Fatalf("Unexpected report destinations %+v %+v\n", record.AggregateReports, record.FailureReports)
+	}
+	if own := record.AggregateReports[0]; own.External || !own.Authorized {
+		t.Errorf("Expected the own domain to be authorized, got %+v\n", own)
+	}
+	if vendor := record.AggregateReports[1]; !vendor.External || !vendor.Authorized || vendor.SizeLimit != "10m" || vendor.AuthorizationRecord != "agency.gov._report._dmarc.vendor.test" {
+		t.Errorf("Expected the vendor to have authorized the domain, got %+v\n", vendor)
+	}
+	if other := record.FailureReports[0]; !other.External || other.Authorized {
+		t.Errorf("Expected an unauthorized external destination, got %+v\n", other)
+	}
+
+	// Subdomains fall back to the organizational domain and get its subdomain policy
+	record = checker.DMARC("mail.example.gov")
+	if !record.Found || record.RecordDomain != "example.gov" || record.AppliedPolicy != structs.DMARCPolicyReject || !record.Enforced {
+		t.Errorf("Unexpected fallback record %+v\n", record)
+	}
+	if record := checker.DMARC("twice.gov"); record.Found || len(record.Errors) != 1 {
+		t.Errorf("Expected multiple records to be ignored, got %+v\n", record)
+	}
+	if record := checker.DMARC("broken.gov"); !record.Found || record.Policy != structs.DMARCPolicyNone || record.Percentage != 100 || len(record.Errors) != 4 {
+		t.Errorf("Expected syntax errors with default values, got %+v\n", record)
+	}
+	if record := checker.DMARC("missing.gov"); record.Found {
+		t.Errorf("Unexpected record %+v\n", record)
+	}
+}
+
+func TestTLSRPTAndBIMI(t *testing.T) {
+	resolver := staticTXTResolver{
+		"_smtp._tls.agency.gov":    {"v=TLSRPTv1; rua=mailto:tlsrpt@agency.gov,https://reports.agency.gov/v1"},
+		"_smtp._tls.broken.gov":    {"v=TLSRPTv1; rua=ftp://reports.broken.gov"},
+		"default._bimi.agency.gov": {"v=BIMI1; l=https://agency.gov/logo.svg; a=https://agency.gov/vmc.pem"},
+		"default._bimi.broken.gov": {"v=BIMI1; l=; a="},
+	}
+	checker := network.NewMailPolicyChecker(resolver)
+
+	if record := checker.TLSRPT("agency.gov"); !record.Found || len(record.RUA) != 2 || len(record.Errors) != 0 {
+		t.Errorf("Unexpected TLS-RPT record %+v\n", record)
+	}
+	if record := checker.TLSRPT("broken.gov"); !record.Found || len(record.RUA) != 0 || len(record.Errors) != 2 {
+		t.Errorf("Expected an invalid destination, got %+v\n", record)
+	}
+	if record := checker.BIMI("agency.gov"); !record.Found || record.Location != "https://agency.gov/logo.svg" || record.Authority != "https://agency.gov/vmc.pem" || record.Declined {
+		t.Errorf("Unexpected BIMI record %+v\n", record)
+	}
+	if record := checker.BIMI("broken.gov"); !record.Found || !record.Declined {
+		t.Errorf("Expected a declination record, got %+v\n", record)
+	}
+}
@@ -0,0 +1,73 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyMailProvider(t *testing.T) {
+	rules, err := network.LoadMailProviderRules("../../../" + network.DEFAULT_PROVIDER_RULES_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A Proofpoint gateway in front of Microsoft 365
+	mxHosts := []string{"mx0a-001.pphosted.com.", "mx0b-001.pphosted.com."}
+	metadata := map[string]structs.SMTPMetadata{
+		"mx0a-001.pphosted.com.:25": {Banner: "220 mx0a-001.pphosted.com ESMTP mfa-m0001"},
+	}
+	mxTLS := map[string]structs.TLSCombinedRecord{
+		"mx0a-001.pphosted.com.:25": {Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.1": {CommonName: "*.pphosted.com", AlternateNames: []string{"*.pphosted.com", "pphosted.com"}},
+			"192.0.2.2": {CommonName: "*.pphosted.com", AlternateNames: []string{"*.pphosted.com"}},
+		}},
+		"relay.agency.gov.:587": {Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.3": {CommonName: "agency-gov.mail.protection.outlook.com"},
+		}},
+	}
+	record := rules.Classify("agency.gov", mxHosts, metadata, mxTLS)
+	if record.Provider != "Proofpoint" || !reflect.DeepEqual(record.Providers, []string{"Proofpoint", "Microsoft 365"}) {
+		t.Errorf("Unexpected providers %v %v\n", record.Provider, record.Providers)
+	}
+	// two MX names, two distinct certificate names on one port, one name at the relay
+	if record.Scores["Proofpoint"] != 2*3+2*2 || record.Scores["Microsoft 365"] != 2 || len(record.Evidence) != 5 {
+		t.Errorf("Unexpected scores %v and evidence %+v\n", record.Scores, record.Evidence)
+	}
+
+	// A banner corroborates a certificate name but does not attribute the mail on its own
+	banner := map[string]structs.SMTPMetadata{"mx.agency.gov.:25": {Banner: "220 mx.google.com ESMTP x1si"}}
+	if record := rules.Classify("agency.gov", []string{"mx.agency.gov."}, banner, nil); record.Provider != structs.ProviderSelfHosted || len(record.Providers) != 0 || record.Evidence[0].Source != structs.ProviderEvidenceBanner {
+		t.Errorf("Expected a banner alone to be recorded but not attributed, got %+v\n", record)
+	}
+	googleTLS := map[string]structs.TLSCombinedRecord{
+		"mx.agency.gov.:25": {Certificates: map[string]structs.CertificateRecord{"192.0.2.4": {CommonName: "mx.google.com"}}},
+	}
+	if record := rules.Classify("agency.gov", []string{"mx.agency.gov."}, banner, googleTLS); record.Provider != "Google Workspace" || record.Scores["Google Workspace"] != 2+2 {
+		t.Errorf("Expected a corroborated certificate match, got %+v\n", record)
+	}
+
+	// On-premises Exchange behind a Google Trust Services certificate
+	exchange := map[string]structs.SMTPMetadata{"mail.agency.gov.:25": {Banner: "220 mail.agency.gov Microsoft ESMTP MAIL Service ready at Mon, 19 Oct 2026 10:00:00 +0000"}}
+	gtsTLS := map[string]structs.TLSCombinedRecord{
+		"mail.agency.gov.:25": {Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.5": {CommonName: "mail.agency.gov", Issuer: "CN=WR3,O=Google Trust Services,C=US"},
+		}},
+	}
+	corroborating := &network.MailProviderRules{Providers: []network.MailProviderRule{
+		{Name: "Microsoft 365", MX: []string{"mail.protection.outlook.com"}, Banners: []string{"Microsoft ESMTP MAIL Service"}},
+		{Name: "Google Workspace", MX: []string{"google.com"}, Issuers: []string{"O=Google Trust Services"}},
+	}}
+	for _, providerRules := range []*network.MailProviderRules{rules, corroborating} {
+		if record := providerRules.Classify("agency.gov", []string{"mail.agency.gov."}, exchange, gtsTLS); record.Provider != structs.ProviderSelfHosted || len(record.Providers) != 0 {
+			t.Errorf("Expected on-premises Exchange to be self-hosted, got %+v\n", record)
+		}
+	}
+	if record := rules.Classify("agency.gov", []string{"mail.agency.gov.", "backup.mail.agency.gov."}, nil, nil); record.Provider != structs.ProviderSelfHosted {
+		t.Errorf("Expected a self-hosted domain, got %+v\n", record)
+	}
+	if record := rules.Classify("agency.gov", []string{"mail.agency.gov.", "mx.isp.example."}, nil, nil); record.Provider != structs.ProviderUnknown {
+		t.Errorf("Expected an unknown provider, got %+v\n", record)
+	}
+}
@@ -0,0 +1,95 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticTXTResolver answers TXT lookups from a map, missing names do not exist
+type staticTXTResolver map[string][]string
+
+func (r staticTXTResolver) LookupTXT(name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, network.ErrNXDomain
+	}
+	return records, nil
+}
+
+// policyClient sends every request to the test server regardless of the requested host
+func policyClient(server *httptest.Server) *http.Client {
+	client := server.Client()
+	transport := client.Transport.(*http.Transport)
+	// httptest certificates cover example.com and its subdomains
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return client
+}
+
+func TestMTASTSPolicyEvaluation(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "mta-sts.example.com" || r.URL.Path != "/.well-known/mta-sts.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("version: STSv1\r\nmode: enforce\r\nmx: mx1.example.com\r\nmx: *.backup.example.com\r\nmax_age: 604800\r\n"))
+	}))
+	defer server.Close()
+
+	resolver := staticTXTResolver{"_mta-sts.example.com": {"v=spf1 -all", "v=STSv1; id=20240101T000000"}}
+	mxTLS := map[string]structs.TLSCombinedRecord{
+		"mx1.example.com.:25": {Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.1": {Status: structs.StatusRecord{Valid: true}},
+		}},
+		"a.backup.example.com.:25": {Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.2": {Status: structs.StatusRecord{Valid: true}},
+			"192.0.2.3": {Status: structs.StatusRecord{Valid: false, Err: "x509: certificate has expired"}},
+		}},
+	}
+	mxHosts := []string{"mx1.example.com.", "a.backup.example.com.", "mx.other.gov."}
+
+	record := network.NewMTASTSChecker(resolver, policyClient(server)).Check("example.com.", mxHosts, mxTLS)
+	if !record.Enabled || record.ID != "20240101T000000" || record.Policy == nil {
+		t.Fatalf("Unexpected record %+v\n", record)
+	}
+	if record.Policy.Mode != structs.MTASTSModeEnforce || record.Policy.MaxAge != 604800 || len(record.Policy.MX) != 2 {
+		t.Errorf("Unexpected policy %+v\n", record.Policy)
+	}
+	if result := record.MXResults["mx1.example.com."]; !result.Compliant {
+		t.Errorf("Expected mx1 to comply %+v\n", result)
+	}
+	if result := record.MXResults["a.backup.example.com."]; !result.PatternMatch || result.CertificateValid || result.Compliant {
+		t.Errorf("Expected the backup MX certificate to fail %+v\n", result)
+	}
+	if result := record.MXResults["mx.other.gov."]; result.PatternMatch || result.CertificateChecked {
+		t.Errorf("Expected mx.other.gov to match no pattern %+v\n", result)
+	}
+	if len(record.Errors) != 2 {
+		t.Errorf("Expected a certificate and a pattern error, got %v\n", record.Errors)
+	}
+
+	absent := network.NewMTASTSChecker(staticTXTResolver{}, policyClient(server)).Check("example.com", mxHosts, mxTLS)
+	if absent.Enabled || len(absent.Errors) != 0 {
+		t.Errorf("Expected no policy %+v\n", absent)
+	}
+}
+
+func TestParseMTASTSPolicyErrors(t *testing.T) {
+	_, problems := structs.ParseMTASTSPolicy("version: STSv2\nmode: strict\nmax_age: 99999999999\n")
+	if len(problems) != 3 {
+		t.Errorf("Expected version, mode and max_age problems, got %v\n", problems)
+	}
+	if _, err := structs.ParseMTASTSTXT("id=abc; v=STSv1"); err == nil {
+		t.Errorf("Expected the version to be required first\n")
+	}
+	if structs.MatchMTASTSPattern("*.example.gov", "a.b.example.gov") || !structs.MatchMTASTSPattern("*.example.gov", "MX.example.gov.") {
+		t.Errorf("Unexpected wildcard matching\n")
+	}
+}
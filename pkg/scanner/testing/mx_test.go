@@ -0,0 +1,72 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func mustRR(t *testing.T, record string) dns.RR {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func TestParseMXReply(t *testing.T) {
+	reply := new(dns.Msg)
+	reply.Answer = []dns.RR{
+		mustRR(t, "example.gov. 300 IN MX 20 mx2.example.gov."),
+		mustRR(t, "example.gov. 300 IN MX 30 ."),
+		mustRR(t, "example.gov. 3600 IN MX 10 mx1.example.gov."),
+	}
+	servers, priority, nullMX, ttl := network.ParseMXReply(reply)
+	if nullMX || ttl != 300 {
+		t.Errorf("Unexpected null MX %v or TTL %d\n", nullMX, ttl)
+	}
+	if !reflect.DeepEqual(servers, []string{"mx1.example.gov.", "mx2.example.gov."}) || priority["mx2.example.gov."] != 20 {
+		t.Errorf("Unexpected MX hosts %v %v\n", servers, priority)
+	}
+
+	reply.Answer = []dns.RR{mustRR(t, "nomail.example.gov. 86400 IN MX 0 .")}
+	if servers, _, nullMX, ttl := network.ParseMXReply(reply); !nullMX || len(servers) != 0 || ttl != 86400 {
+		t.Errorf("Expected a Null MX, got %v %v %d\n", servers, nullMX, ttl)
+	}
+
+	// NODATA, the negative caching TTL is the lower of the SOA TTL and minimum
+	reply.Answer = nil
+	reply.Ns = []dns.RR{mustRR(t, "example.gov. 900 IN SOA ns1.example.gov. hostmaster.example.gov. 1 7200 3600 1209600 300")}
+	if servers, _, nullMX, ttl := network.ParseMXReply(reply); nullMX || len(servers) != 0 || ttl != 300 {
+		t.Errorf("Unexpected NODATA result %v %v %d\n", servers, nullMX, ttl)
+	}
+}
+
+func TestResolveMailHandling(t *testing.T) {
+	resolver := network.NewMailResolver(startLocalDNSServer(t, []string{
+		`example.gov. 300 IN MX 20 mx2.example.gov.`,
+		`example.gov. 300 IN MX 10 mx1.example.gov.`,
+		`implicit.example.gov. 300 IN A 192.0.2.25`,
+		`nomail.example.gov. 300 IN MX 0 .`,
+	}))
+
+	resolution, err := resolver.ResolveMailHandling("example.gov")
+	if err != nil || resolution.MailHandling != structs.MailHandlingExplicitMX ||
+		!reflect.DeepEqual(resolution.Servers, []string{"mx1.example.gov.", "mx2.example.gov."}) {
+		t.Errorf("Expected the MX hosts in preference order, got %+v %v\n", resolution, err)
+	}
+	resolution, err = resolver.ResolveMailHandling("implicit.example.gov")
+	if err != nil || resolution.MailHandling != structs.MailHandlingImplicitMX || !reflect.DeepEqual(resolution.Servers, []string{"implicit.example.gov."}) {
+		t.Errorf("Expected an implicit MX, got %+v %v\n", resolution, err)
+	}
+	if resolution, err := resolver.ResolveMailHandling("nomail.example.gov"); err != nil || resolution.MailHandling != structs.MailHandlingNullMX {
+		t.Errorf("Expected a Null MX, got %+v %v\n", resolution, err)
+	}
+	if _, err := resolver.ResolveMailHandling("missing.example.gov"); !errors.Is(err, network.ErrNXDomain) {
+		t.Errorf("Expected NXDOMAIN, got %v\n", err)
+	}
+}
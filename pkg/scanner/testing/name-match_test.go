@@ -0,0 +1,47 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"testing"
+)
+
+func TestMatchHostname(t *testing.T) {
+	cert, _ := generateTestCertificate(t, "portal.agency.gov", []string{"*.agency.gov", "agency.gov", "services.other.gov", "shared.example.com"})
+
+	match := network.MatchHostname("portal.agency.gov.", cert)
+	if match.MatchType != structs.NameMatchWildcardSAN || match.MatchedName != "*.agency.gov" {
+		t.Errorf("Expected a wildcard SAN match, got %v %v\n", match.MatchType, match.MatchedName)
+	}
+	if match.WWWVariantCovered || !match.ApexCovered || match.Apex != "agency.gov" {
+		t.Errorf("Expected only the apex to be covered, got %+v\n", match)
+	}
+	if len(match.SharedRegistrableDomains) != 2 ||
+		match.SharedRegistrableDomains[0] != "example.com" ||
+		match.SharedRegistrableDomains[1] != "other.gov" {
+		t.Errorf("Unexpected shared registrable domains %v\n", match.SharedRegistrableDomains)
+	}
+
+	match = network.MatchHostname("agency.gov", cert)
+	if match.MatchType != structs.NameMatchExactSAN || !match.WWWVariantCovered || match.WWWVariant != "www.agency.gov" {
+		t.Errorf("Expected an exact match with the www variant covered, got %+v\n", match)
+	}
+
+	// A wildcard only covers a single label.
+	match = network.MatchHostname("a.b.agency.gov", cert)
+	if match.MatchType != structs.NameMatchNone {
+		t.Errorf("Expected no match for a multi label wildcard expansion, got %v\n", match.MatchType)
+	}
+
+	cnOnly, _ := generateTestCertificate(t, "legacy.agency.gov", nil)
+	match = network.MatchHostname("legacy.agency.gov", cnOnly)
+	if match.MatchType != structs.NameMatchCommonNameOnly || match.ApexCovered {
+		t.Errorf("Expected a CN only match without apex coverage, got %+v\n", match)
+	}
+
+	// The CN is ignored once SANs are present
+	cnWithSANs, _ := generateTestCertificate(t, "legacy.agency.gov", []string{"other.agency.gov"})
+	if match = network.MatchHostname("legacy.agency.gov", cnWithSANs); match.MatchType != structs.NameMatchNone {
+		t.Errorf("Expected no match from the CN of a certificate with SANs, got %+v\n", match)
+	}
+}
@@ -0,0 +1,73 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"net"
+	"reflect"
+	"testing"
+)
+
+var reverseZone = []string{
+	"10.2.0.192.in-addr.arpa. 300 IN PTR mx1.agency.gov.",
+	"mx1.agency.gov. 300 IN A 192.0.2.10",
+	"11.2.0.192.in-addr.arpa. 300 IN PTR claimed.agency.gov.",
+	"11.2.0.192.in-addr.arpa. 300 IN PTR host-11.isp.example.",
+	"claimed.agency.gov. 300 IN A 198.51.100.1",
+	"host-11.isp.example. 300 IN A 192.0.2.11",
+	"5.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa. 300 IN PTR mx6.agency.gov.",
+	"mx6.agency.gov. 300 IN AAAA 2001:db8::25",
+}
+
+func TestCheckReverseDNS(t *testing.T) {
+	resolver := network.NewMailResolver(startLocalDNSServer(t, reverseZone))
+
+	record := network.CheckReverseDNS(resolver, net.ParseIP("192.0.2.10"), "mx1.agency.gov.")
+	if !record.ForwardConfirmed || !record.MatchesHostname || !reflect.DeepEqual(record.ConfirmedNames, []string{"mx1.agency.gov."}) {
+		t.Errorf("Expected a confirmed PTR for the MX, got %+v\n", record)
+	}
+	// Only the name resolving back to the IP is confirmed
+	record = network.CheckReverseDNS(resolver, net.ParseIP("192.0.2.11"), "claimed.agency.gov")
+	if len(record.PTRNames) != 2 || !reflect.DeepEqual(record.ConfirmedNames, []string{"host-11.isp.example."}) || record.MatchesHostname {
+		t.Errorf("Unexpected confirmation %+v\n", record)
+	}
+	if record := network.CheckReverseDNS(resolver, net.ParseIP("2001:db8::25"), "mx6.agency.gov"); !record.ForwardConfirmed || !record.MatchesHostname {
+		t.Errorf("Expected a confirmed IPv6 PTR, got %+v\n", record)
+	}
+	if record := network.CheckReverseDNS(resolver, net.ParseIP("192.0.2.99"), "mx1.agency.gov"); record.ForwardConfirmed || len(record.PTRNames) != 0 || len(record.Error) != 0 {
+		t.Errorf("Expected no PTR records, got %+v\n", record)
+	}
+}
+
+func TestMailReverseDNS(t *testing.T) {
+	resolver := network.NewMailResolver(startLocalDNSServer(t, reverseZone))
+	mxHosts := []string{"mx1.agency.gov.", "relay.agency.gov.", "host-11.isp.example."}
+	mxIPs := map[string][]net.IP{
+		"mx1.agency.gov.":      {net.ParseIP("192.0.2.10")},
+		"relay.agency.gov.":    {net.ParseIP("192.0.2.11")},
+		"host-11.isp.example.": {net.ParseIP("192.0.2.11")},
+	}
+	// The scan of mx1 recorded a stale result, it is reused instead of a new lookup
+	mxTLS := map[string]structs.TLSCombinedRecord{
+		"mx1.agency.gov.:25": {ReverseDNS: map[string]structs.ReverseDNSRecord{
+			"192.0.2.10": {ConfirmedNames: []string{"mx1.agency.gov."}, ForwardConfirmed: true, Hostname: "mx1.agency.gov.", MatchesHostname: true},
+		}},
+	}
+	endpoints := []structs.MailEndpointRecord{
+		{MX: "mx1.agency.gov.", IP: "192.0.2.10", Port: 587, Metadata: &structs.SMTPMetadata{Banner: "submission.agency.gov ESMTP"}},
+		{MX: "mx1.agency.gov.", IP: "192.0.2.10", Port: 25, Metadata: &structs.SMTPMetadata{Banner: "mx1.agency.gov ESMTP Postfix\nsecond line"}},
+		{MX: "relay.agency.gov.", IP: "192.0.2.11", Port: 25, Metadata: &structs.SMTPMetadata{Banner: "relay.agency.gov ready"}},
+	}
+
+	records := network.MailReverseDNS(resolver, mxHosts, mxIPs, mxTLS, endpoints)
+	if record := records["mx1.agency.gov."]["192.0.2.10"]; record.BannerHostname != "mx1.agency.gov" || !record.MatchesBanner || !record.MatchesHostname {
+		t.Errorf("Unexpected MX record %+v\n", record)
+	}
+	if record := records["relay.agency.gov."]["192.0.2.11"]; record.Hostname != "relay.agency.gov." || !record.ForwardConfirmed || record.MatchesHostname || record.MatchesBanner {
+		t.Errorf("Unexpected relay record %+v\n", record)
+	}
+	// The IP shared with the relay is compared with each MX
+	if record := records["host-11.isp.example."]["192.0.2.11"]; record.Hostname != "host-11.isp.example." || !record.MatchesHostname {
+		t.Errorf("Unexpected shared IP record %+v\n", record)
+	}
+}
@@ -0,0 +1,138 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRevocationCheckerAgainstLocalResponder(t *testing.T) {
+	issuer, issuerKey := generateTestCertificate(t, "Test Issuing CA", nil)
+	revokedSerial := big.NewInt(4242)
+	var crlDownloads int32
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	reason, _ := asn1.Marshal(asn1.Enumerated(ocsp.Superseded))
+	createCRL := func(nextUpdate time.Time) []byte {
+		crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: time.Now().Add(-2 * time.Hour),
+			NextUpdate: nextUpdate,
+			RevokedCertificates: []pkix.RevokedCertificate{
+				{SerialNumber: revokedSerial, RevocationTime: time.Now().Add(-time.Minute),
+					Extensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 21}, Value: reason}}},
+			},
+		}, issuer, issuerKey)
+		if err != nil {
+			t.Error(err)
+		}
+		return crl
+	}
+	mux.HandleFunc("/crl", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&crlDownloads, 1)
+		w.Write(createCRL(time.Now().Add(time.Hour)))
+	})
+	mux.HandleFunc("/expired.crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(createCRL(time.Now().Add(-time.Hour)))
+	})
+	ocspHandler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request, err := ocsp.ParseRequest(body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: request.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if r.URL.Path == "/stale-ocsp" {
+			template.ThisUpdate = time.Now().Add(-2 * time.Hour)
+			template.NextUpdate = time.Now().Add(-time.Hour)
+		}
+		if request.SerialNumber.Cmp(revokedSerial) == 0 {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now().Add(-time.Minute)
+			template.RevocationReason = ocsp.KeyCompromise
+		}
+		response, err := ocsp.CreateResponse(issuer, issuer, template, issuerKey)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write(response)
+	}
+	mux.HandleFunc("/ocsp", ocspHandler)
+	mux.HandleFunc("/stale-ocsp", ocspHandler)
+
+	issueLeafWith := func(serial *big.Int, ocspServers []string, crl string) *x509.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber:          serial,
+			Subject:               pkix.Name{CommonName: "leaf.example"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			OCSPServer:            ocspServers,
+			CRLDistributionPoints: []string{server.URL + crl},
+		}, issuer, &key.PublicKey, issuerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(der)
+		return leaf
+	}
+	issueLeaf := func(serial *big.Int) *x509.Certificate {
+		return issueLeafWith(serial, []string{server.URL + "/ocsp"}, "/crl")
+	}
+
+	checker := network.NewRevocationChecker(server.Client())
+
+	revoked := checker.Check(issueLeaf(revokedSerial), issuer)
+	if revoked.Status != structs.RevocationRevoked || revoked.Reason != ocsp.KeyCompromise {
+		t.Errorf("Expected a revoked status, got %+v\n", revoked)
+	}
+	if revoked.CRL == nil || revoked.CRL.Status != structs.RevocationRevoked || revoked.CRL.Reason != ocsp.Superseded {
+		t.Errorf("Expected the CRL to list the certificate, got %+v\n", revoked.CRL)
+	}
+	if crlOnly := checker.Check(issueLeafWith(revokedSerial, nil, "/crl"), issuer); crlOnly.Status != structs.RevocationRevoked || crlOnly.Reason != ocsp.Superseded {
+		t.Errorf("Expected the CRL reason to be reported, got %+v\n", crlOnly)
+	}
+	stale := checker.Check(issueLeafWith(revokedSerial, []string{server.URL + "/stale-ocsp"}, "/expired.crl"), issuer)
+	if stale.Status != structs.RevocationUnknown || stale.OCSP == nil || stale.OCSP.Error != network.ErrOCSPExpired.Error() {
+		t.Errorf("Expected a stale OCSP response to be unknown, got %+v\n", stale)
+	}
+	expired := checker.Check(issueLeafWith(big.NewInt(7), nil, "/expired.crl"), issuer)
+	if expired.Status != structs.RevocationUnknown || expired.CRL == nil || expired.CRL.Error != network.ErrCRLExpired.Error() {
+		t.Errorf("Expected an expired CRL to be unknown, got %+v\n", expired)
+	}
+
+	leaf, intermediates := checker.CheckChain([]*x509.Certificate{issueLeaf(big.NewInt(7)), issuer}, nil)
+	if leaf.Status != structs.RevocationGood || leaf.OCSP.Status != structs.RevocationGood || leaf.CRL.Status != structs.RevocationGood {
+		t.Errorf("Expected a good status, got %+v\n", leaf)
+	}
+	if len(intermediates) != 1 || intermediates[0].Status != structs.RevocationUnknown {
+		t.Errorf("Expected the self-signed issuer to have no revocation sources, got %+v\n", intermediates)
+	}
+
+	if downloads := atomic.LoadInt32(&crlDownloads); downloads != 1 {
+		t.Errorf("Expected the CRL to be downloaded once, downloaded %d times\n", downloads)
+	}
+}
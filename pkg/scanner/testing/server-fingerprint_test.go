@@ -0,0 +1,72 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerFingerprintHashes(t *testing.T) {
+	hello := &network.ServerHello{
+		Version:           tls.VersionTLS12,
+		NegotiatedVersion: tls.VersionTLS13,
+		CipherSuite:       tls.TLS_AES_128_GCM_SHA256,
+		Extensions:        []uint16{network.ExtensionSupportedVersions, network.ExtensionKeyShare},
+	}
+	ja3sString, _ := network.JA3S(hello)
+	if ja3sString != "771,4865,43-51" {
+		t.Errorf("Unexpected JA3S string %s\n", ja3sString)
+	}
+	raw, ja4s := network.JA4S(hello)
+	if raw != "t130200_1301_002b,0033" {
+		t.Errorf("Unexpected raw JA4S %s\n", raw)
+	}
+	if ja4s != "t130200_1301_a56c5b993250" {
+		t.Errorf("Unexpected JA4S %s\n", ja4s)
+	}
+
+	hello = &network.ServerHello{Version: tls.VersionTLS12, NegotiatedVersion: tls.VersionTLS12, CipherSuite: 0xc02f, ALPN: "h2"}
+	if _, ja4s := network.JA4S(hello); ja4s != "t1200h2_c02f_000000000000" {
+		t.Errorf("Unexpected JA4S without extensions %s\n", ja4s)
+	}
+}
+
+func TestFingerprintServerMatchesSignatures(t *testing.T) {
+	port := startLocalTLSServer(t, &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13})
+	ip := net.ParseIP("127.0.0.1")
+
+	record := network.FingerprintServer(ip, "localhost", port, "TLS", nil)
+	if len(record.Probes) != len(network.FingerprintProbes) {
+		t.Fatalf("Expected one fingerprint per probe, got %d\n", len(record.Probes))
+	}
+	var tls13 string
+	for _, probe := range record.Probes {
+		if probe.Error != "" || len(probe.JA3S) != 32 {
+			t.Fatalf("Unexpected fingerprint %+v\n", probe)
+		}
+		if probe.Probe == "tls13" {
+			tls13 = probe.JA4S
+		}
+	}
+	if !strings.HasPrefix(tls13, "t13") {
+		t.Fatalf("Expected TLS 1.3 to be negotiated by the tls13 probe, got %s\n", tls13)
+	}
+
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	db := `{"signatures": [{"ja4s": "` + tls13 + `", "probe": "tls13", "label": "Go crypto/tls"}, {"ja3s": "00000000000000000000000000000000", "label": "Other"}]}`
+	if err := os.WriteFile(path, []byte(db), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	signatures, err := network.LoadServerSignatures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record = network.FingerprintServer(ip, "localhost", port, "TLS", signatures)
+	if len(record.Labels) != 1 || record.Labels[0] != "Go crypto/tls" {
+		t.Errorf("Unexpected labels %v\n", record.Labels)
+	}
+}
@@ -0,0 +1,49 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseEHLOCapabilities(t *testing.T) {
+	capabilities := structs.ParseEHLOCapabilities([]string{
+		"SIZE 35882577", "8BITMIME", "AUTH PLAIN LOGIN XOAUTH2", "AUTH=LOGIN", "ENHANCEDSTATUSCODES",
+		"PIPELINING", "CHUNKING", "SMTPUTF8", "DSN", "REQUIRETLS", "STARTTLS",
+	}, false)
+	if !capabilities.SizeAdvertised || capabilities.SizeLimit != 35882577 {
+		t.Errorf("Unexpected SIZE %v %v\n", capabilities.SizeAdvertised, capabilities.SizeLimit)
+	}
+	if !reflect.DeepEqual(capabilities.AuthMechanisms, []string{"PLAIN", "LOGIN", "XOAUTH2"}) {
+		t.Errorf("Unexpected AUTH mechanisms %v\n", capabilities.AuthMechanisms)
+	}
+	if !capabilities.PlaintextAuthBeforeTLS || !capabilities.StartTLS {
+		t.Errorf("Expected plaintext AUTH before STARTTLS to be flagged\n")
+	}
+	if !capabilities.EightBitMIME || !capabilities.Pipelining || !capabilities.Chunking ||
+		!capabilities.SMTPUTF8 || !capabilities.DSN || !capabilities.RequireTLS {
+		t.Errorf("Missing extensions %+v\n", capabilities)
+	}
+
+	if encrypted := structs.ParseEHLOCapabilities([]string{"AUTH PLAIN"}, true); encrypted.PlaintextAuthBeforeTLS {
+		t.Errorf("AUTH PLAIN over TLS must not be flagged\n")
+	}
+}
+
+func TestSMTPCapabilitiesAfterSTARTTLS(t *testing.T) {
+	port := startFakeSMTPServer(t, false)
+	metadata := network.GetSMTPBannerAndCapabilities(net.JoinHostPort("127.0.0.1", port), "localhost", structs.ModeSTARTTLS)
+
+	if metadata.ParsedCapabilities.Encrypted || !metadata.ParsedCapabilities.PlaintextAuthBeforeTLS {
+		t.Errorf("Unexpected plaintext capabilities %+v\n", metadata.ParsedCapabilities)
+	}
+	if metadata.EncryptedCapabilities == nil || !metadata.EncryptedCapabilities.Pipelining {
+		t.Fatalf("Expected the EHLO response over TLS, got %+v\n", metadata.EncryptedCapabilities)
+	}
+	expected := structs.CapabilityChanges{Added: []string{"AUTH PLAIN", "PIPELINING"}, Removed: []string{"AUTH LOGIN", "STARTTLS"}}
+	if !reflect.DeepEqual(*metadata.CapabilityChanges, expected) {
+		t.Errorf("Unexpected capability changes %+v\n", *metadata.CapabilityChanges)
+	}
+}
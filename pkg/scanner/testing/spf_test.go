@@ -0,0 +1,185 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// startLocalDNSServer serves the records over UDP on localhost, names without records are NXDOMAIN
+func startLocalDNSServer(t *testing.T, records []string) string {
+	zone := make(map[string][]dns.RR)
+	for _, record := range records {
+		rr := mustRR(t, record)
+		name := strings.ToLower(rr.Header().Name)
+		zone[name] = append(zone[name], rr)
+	}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, query *dns.Msg) {
+		reply := new(dns.Msg)
+		reply.SetReply(query)
+		question := query.Question[0]
+		rrs, ok := zone[strings.ToLower(question.Name)]
+		if !ok {
+			reply.Rcode = dns.RcodeNameError
+		}
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == question.Qtype {
+				reply.Answer = append(reply.Answer, rr)
+			}
+		}
+		w.WriteMsg(reply)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+var spfZone = []string{
+	`example.gov. 300 IN TXT "v=spf1 mx ip4:198.51.100.0/24 include:_spf.provider.test ~all"`,
+	`example.gov. 300 IN TXT "google-site-verification=abc"`,
+	`example.gov. 300 IN MX 10 mx1.example.gov.`,
+	`example.gov. 300 IN A 203.0.113.5`,
+	`mx1.example.gov. 300 IN A 192.0.2.10`,
+	`_spf.provider.test. 300 IN TXT "v=spf1 ip6:2001:db8::/32 exists:%{i}._spf.provider.test -all"`,
+	`192.0.2.77._spf.provider.test. 300 IN A 127.0.0.2`,
+	`open.gov. 300 IN TXT "v=spf1 a +all"`,
+	`open.gov. 300 IN A 203.0.113.9`,
+	`twice.gov. 300 IN TXT "v=spf1 -all"`,
+	`twice.gov. 300 IN TXT "v=spf1 ip4:192.0.2.1 -all"`,
+	`voids.gov. 300 IN TXT "v=spf1 a:nx1.voids.gov a:nx2.voids.gov a:nx3.voids.gov -all"`,
+	`redirect.gov. 300 IN TXT "v=spf1 redirect=example.gov"`,
+	`toomany.gov. 300 IN TXT "v=spf1 a:h.test a:h.test a:h.test a:h.test a:h.test a:h.test include:more.test -all"`,
+	`more.test. 300 IN TXT "v=spf1 a:h.test a:h.test a:h.test a:h.test ip4:192.0.2.200 -all"`,
+	`h.test. 300 IN A 192.0.2.250`,
+	`fanout.gov. 300 IN TXT "v=spf1 include:wide.test include:wide.test include:wide.test include:wide.test -all"`,
+	`wide.test. 300 IN TXT "v=spf1 include:hosts.test include:hosts.test include:hosts.test include:hosts.test -all"`,
+	`hosts.test. 300 IN TXT "v=spf1 a:h.test a:h.test a:h.test a:h.test -all"`,
+}
+
+func TestSPFCheck(t *testing.T) {
+	checker := network.NewSPFChecker(network.NewMailResolver(startLocalDNSServer(t, spfZone)))
+
+	record := checker.Check("example.gov", map[string][]net.IP{"mx1.example.gov.": {net.ParseIP("192.0.2.10")}})
+	if !record.Found || record.MultipleRecords || record.AllQualifier != "~" || record.PermissiveAll {
+		t.Errorf("Unexpected SPF record %+v\n", record)
+	}
+	// mx and include, exists in the include depends on the connecting IP and is counted but not followed
+	if record.DNSLookups != 3 || record.VoidLookups != 0 || len(record.Includes) != 1 || !record.Includes[0].Found {
+		t.Errorf("Unexpected expansion %d lookups, %d void, includes %+v\n", record.DNSLookups, record.VoidLookups, record.Includes)
+	}
+	if result := record.MXResults["192.0.2.10"]; !result.Pass || result.Host != "mx1.example.gov." || result.MatchedTerm != "example.gov mx" {
+		t.Errorf("Expected the MX to pass, got %+v\n", result)
+	}
+	if result := record.DomainResults["203.0.113.5"]; result.Result != structs.SPFSoftFail {
+		t.Errorf("Expected the domain IP to soft fail, got %+v\n", result)
+	}
+
+	cases := []struct {
+		ip       string
+		domain   string
+		expected string
+	}{
+		{"198.51.100.7", "example.gov", structs.SPFPass},
+		{"2001:db8::25", "example.gov", structs.SPFPass},
+		{"192.0.2.77", "example.gov", structs.SPFPass}, // exists with the %{i} macro
+		{"2001:db9::25", "example.gov", structs.SPFSoftFail},
+		{"198.51.100.7", "redirect.gov", structs.SPFPass},
+		{"192.0.2.1", "twice.gov", structs.SPFPermError},
+		{"192.0.2.1", "voids.gov", structs.SPFPermError},
+		{"192.0.2.200", "toomany.gov", structs.SPFPermError},
+		{"192.0.2.1", "missing.gov", structs.SPFNone},
+	}
+	for _, c := range cases {
+		if result := checker.Evaluate(net.ParseIP(c.ip), c.domain); result.Result != c.expected {
+			t.Errorf("%s for %s: expected %s, got %+v\n", c.ip, c.domain, c.expected, result)
+		}
+	}
+
+	if record := checker.Check("open.gov", nil); !record.PermissiveAll || record.AllQualifier != "+" || !record.DomainResults["203.0.113.9"].Pass {
+		t.Errorf("Expected +all to be reported, got %+v\n", record)
+	}
+	if record := checker.Check("twice.gov", nil); record.Found || !record.MultipleRecords {
+		t.Errorf("Expected multiple SPF records to be reported, got %+v\n", record)
+	}
+	if record := checker.Check("voids.gov", nil); record.VoidLookups != 3 {
+		t.Errorf("Expected 3 void lookups, got %d\n", record.VoidLookups)
+	}
+	if record := checker.Check("toomany.gov", nil); record.DNSLookups != 11 || !record.LookupLimitExceeded {
+		t.Errorf("Expected 11 lookups over the limit, got %d %v\n", record.DNSLookups, record.LookupLimitExceeded)
+	}
+	// 84 lookups when fully expanded, the expansion stops at the cap
+	if record := checker.Check("fanout.gov", nil); record.DNSLookups != 50 || !record.LookupLimitExceeded || record.AllQualifier != "-" {
+		t.Errorf("Expected the expansion to stop at 50 lookups, got %d %v %+v\n", record.DNSLookups, record.LookupLimitExceeded, record.Errors)
+	}
+}
+
+func TestParseSPF(t *testing.T) {
+	policy, err := structs.ParseSPF("v=spf1 -a:mail.example.gov/24//64 ?mx/28 ip6:2001:DB8::/32 exp=explain.example.gov redirect=_spf.example.gov unknown=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Directives) != 3 || policy.Redirect != "_spf.example.gov" || policy.Explanation != "explain.example.gov" || len(policy.Modifiers) != 3 {
+		t.Fatalf("Unexpected policy %+v\n", policy)
+	}
+	a := policy.Directives[0]
+	if a.Qualifier != "-" || a.Mechanism != "a" || a.DomainSpec != "mail.example.gov" || a.IP4Prefix != 24 || a.IP6Prefix != 64 || a.Result() != structs.SPFFail {
+		t.Errorf("Unexpected a directive %+v\n", a)
+	}
+	if mx := policy.Directives[1]; mx.String() != "?mx/28" {
+		t.Errorf("Unexpected mx directive %s\n", mx)
+	}
+	if ip6 := policy.Directives[2]; ip6.Network != "2001:db8::/32" {
+		t.Errorf("Unexpected ip6 directive %+v\n", ip6)
+	}
+
+	for _, record := range []string{
+		"v=spf10 -all",
+		"v=spf1 ip4:192.0.2.300",
+		"v=spf1 ip4:2001:db8::1",
+		"v=spf1 include",
+		"v=spf1 a/33",
+		"v=spf1 all:example.gov",
+		"v=spf1 foo:example.gov",
+		"v=spf1 redirect=a.example.gov redirect=b.example.gov",
+	} {
+		if _, err := structs.ParseSPF(record); !errors.Is(err, structs.ErrSPFSyntax) {
+			t.Errorf("Expected %q to be rejected, got %v\n", record, err)
+		}
+	}
+}
+
+func TestExpandSPFMacros(t *testing.T) {
+	cases := []struct {
+		spec     string
+		ip       string
+		expected string
+	}{
+		{"%{ir}.%{v}._spf.%{d}", "192.0.2.3", "3.2.0.192.in-addr._spf.example.gov"},
+		{"%{d2}", "192.0.2.3", "example.gov"},
+		{"%{l-}.%{o}", "192.0.2.3", "postmaster.example.gov"},
+		{"%{ir}.%{v}", "2001:db8::cb01", "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6"},
+		{"%%%_%-", "192.0.2.3", "% %20"},
+	}
+	for _, c := range cases {
+		expanded, err := network.ExpandSPFMacros(c.spec, net.ParseIP(c.ip), "example.gov", "postmaster@example.gov")
+		if err != nil || expanded != c.expected {
+			t.Errorf("%s: expected %s, got %s %v\n", c.spec, c.expected, expanded, err)
+		}
+	}
+	if _, err := network.ExpandSPFMacros("%{x}", net.ParseIP("192.0.2.3"), "example.gov", "postmaster@example.gov"); !errors.Is(err, network.ErrSPFMacro) {
+		t.Errorf("Expected an unknown macro letter to fail, got %v\n", err)
+	}
+}
@@ -29,3 +29,49 @@ func TestRequestSerializationAndDeserialization(t *testing.T) {
 		t.Errorf("Object data mismatch. %v != %v\n", query, expectedQuery)
 	}
 }
+
+func TestTLSConsistencyFlagsDivergentIPs(t *testing.T) {
+	modern := []structs.VersionSuitesRecord{
+		{TLSVersion: 0x0301, IsSupported: false},
+		{TLSVersion: 0x0303, IsSupported: true, SupportedCipherSuites: []uint16{0xc02f, 0xc030}},
+		{TLSVersion: 0x0304, IsSupported: true, SupportedCipherSuites: []uint16{0x1301}},
+	}
+	legacy := []structs.VersionSuitesRecord{
+		{TLSVersion: 0x0301, IsSupported: true, SupportedCipherSuites: []uint16{0xc013}},
+		{TLSVersion: 0x0303, IsSupported: true, SupportedCipherSuites: []uint16{0xc030, 0xc02f}},
+		{TLSVersion: 0x0304, IsSupported: true, SupportedCipherSuites: []uint16{0x1301}},
+	}
+	record := structs.TLSCombinedRecord{
+		Certificates: map[string]structs.CertificateRecord{
+			"192.0.2.1":   {SHA256Fingerprint: "current", SPKISHA256Hash: "key"},
+			"192.0.2.2":   {SHA256Fingerprint: "current", SPKISHA256Hash: "key"},
+			"192.0.2.3":   {SHA256Fingerprint: "stale", SPKISHA256Hash: "key"},
+			"2001:db8::1": {SHA256Fingerprint: "current", SPKISHA256Hash: "key"},
+		},
+		CipherSuites: map[string][]structs.VersionSuitesRecord{
+			"192.0.2.1":   modern,
+			"192.0.2.2":   modern,
+			"192.0.2.3":   modern,
+			"2001:db8::1": legacy,
+		},
+	}
+	record.IdentifyConsistency()
+
+	if record.Consistency.Consistent {
+		t.Errorf("Expected an inconsistent deployment\n")
+	}
+	if record.Consistency.LeafCertificate.Majority != "current" ||
+		len(record.Consistency.LeafCertificate.Divergent) != 1 ||
+		record.Consistency.LeafCertificate.Divergent[0] != "192.0.2.3" {
+		t.Errorf("Unexpected leaf certificate grouping %+v\n", record.Consistency.LeafCertificate)
+	}
+	if len(record.Consistency.SPKI.Divergent) != 0 {
+		t.Errorf("Expected a single SPKI group %+v\n", record.Consistency.SPKI)
+	}
+	if len(record.Consistency.Versions.Divergent) != 1 || record.Consistency.Versions.Divergent[0] != "2001:db8::1" {
+		t.Errorf("Unexpected version grouping %+v\n", record.Consistency.Versions)
+	}
+	if len(record.Consistency.DivergentIPs) != 2 {
+		t.Errorf("Unexpected divergent IPs %v\n", record.Consistency.DivergentIPs)
+	}
+}
@@ -0,0 +1,338 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"Scanner/pkg/scanner/structs"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// startLocalTLSServer serves TLS on a loopback port until the test ends and returns the port.
+// A certificate for localhost is generated unless cfg has one.
+func startLocalTLSServer(t *testing.T, cfg *tls.Config) string {
+	if len(cfg.Certificates) == 0 {
+		cert, key := generateTestCertificate(t, "localhost", []string{"localhost"})
+		cfg.Certificates = []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func TestProbeTLSFeaturesAgainstLocalServer(t *testing.T) {
+	port := startLocalTLSServer(t, &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13})
+
+	features := network.ProbeTLSFeatures(net.ParseIP("127.0.0.1"), "localhost", port, "TLS",
+		[]uint16{tls.VersionTLS12, tls.VersionTLS13})
+	if len(features.Errors) != 0 {
+		t.Fatalf("Unexpected probe errors %v\n", features.Errors)
+	}
+	if !features.SecureRenegotiation || features.InsecureRenegotiation == nil || *features.InsecureRenegotiation {
+		t.Errorf("Expected RFC 5746 support\n")
+	}
+	if !features.ExtendedMasterSecret || !features.SessionTicketSupported || !features.ResumptionSupported {
+		t.Errorf("Unexpected session features %+v\n", features)
+	}
+	if features.FallbackSCSV != structs.FallbackSCSVHonoured {
+		t.Errorf("Expected TLS_FALLBACK_SCSV to be honoured, got %v\n", features.FallbackSCSV)
+	}
+}
+
+func TestReadServerHelloReportsAlerts(t *testing.T) {
+	port := startLocalTLSServer(t, &tls.Config{MinVersion: tls.VersionTLS13})
+
+	_, err := network.RawHandshake(net.ParseIP("127.0.0.1"), "localhost", port, "TLS", network.ClientHelloSpec{
+		ServerName:   "localhost",
+		Version:      tls.VersionTLS12,
+		CipherSuites: network.DefaultProbeCipherSuites,
+	})
+	if _, ok := err.(network.HandshakeAlertError); !ok {
+		t.Errorf("Expected a handshake alert from a TLS 1.3 only server, got %v\n", err)
+	}
+
+	hello, err := network.RawHandshake(net.ParseIP("127.0.0.1"), "localhost", port, "TLS", network.ClientHelloSpec{
+		ServerName:        "localhost",
+		Version:           tls.VersionTLS12,
+		CipherSuites:      network.DefaultProbeCipherSuites,
+		SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+		ALPN:              []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.NegotiatedVersion != tls.VersionTLS13 || !hello.HasExtension(network.ExtensionKeyShare) {
+		t.Errorf("Expected a TLS 1.3 ServerHello, got %+v\n", hello)
+	}
+}
+
+// startRenegotiationServer completes TLS 1.2 handshakes without RFC 5746, using
+// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, then answers a renegotiating ClientHello with a
+// ServerHello when accept is set and with a no_renegotiation warning otherwise.
+func startRenegotiationServer(t *testing.T, accept bool) string {
+	cert, key := generateTestCertificate(t, "localhost", []string{"localhost"})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serveRenegotiation(conn, cert, key, accept)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func serveRenegotiation(conn net.Conn, cert *x509.Certificate, key *ecdsa.PrivateKey, accept bool) error {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, clientHello, err := readTestRecord(conn)
+	if err != nil {
+		return err
+	}
+	clientRandom := clientHello[6:38]
+	serverRandom := make([]byte, 32)
+	rand.Read(serverRandom)
+	transcript := append([]byte{}, clientHello...)
+
+	// no extensions, in particular no renegotiation_info
+	serverHello := append(append([]byte{3, 3}, serverRandom...), 0, 0xc0, 0x2b, 0)
+	privateKey, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	params := append([]byte{3, 0, 29, 32}, privateKey.PublicKey().Bytes()...)
+	digest := sha256.Sum256(append(append(append([]byte{}, clientRandom...), serverRandom...), params...))
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	flight := testHandshake(2, serverHello)
+	flight = append(flight, testHandshake(11, testVector(3, testVector(3, cert.Raw)))...)
+	flight = append(flight, testHandshake(12, append(append(params, 4, 3), testVector(2, signature)...))...)
+	flight = append(flight, testHandshake(14, nil)...)
+	transcript = append(transcript, flight...)
+	writeTestRecord(conn, 22, flight)
+
+	_, clientKeyExchange, err := readTestRecord(conn)
+	if err != nil || len(clientKeyExchange) != 37 {
+		return errors.New("unexpected ClientKeyExchange")
+	}
+	transcript = append(transcript, clientKeyExchange...)
+	clientPublicKey, err := ecdh.X25519().NewPublicKey(clientKeyExchange[5:])
+	if err != nil {
+		return err
+	}
+	preMasterSecret, _ := privateKey.ECDH(clientPublicKey)
+	masterSecret := testPRF(preMasterSecret, "master secret", append(append([]byte{}, clientRandom...), serverRandom...), 48)
+	keyBlock := testPRF(masterSecret, "key expansion", append(append([]byte{}, serverRandom...), clientRandom...), 40)
+	client := newTestGCM(keyBlock[0:16], keyBlock[32:36])
+	server := newTestGCM(keyBlock[16:32], keyBlock[36:40])
+
+	if recordType, _, err := readTestRecord(conn); err != nil || recordType != 20 {
+		return errors.New("expected ChangeCipherSpec")
+	}
+	_, fragment, err := readTestRecord(conn)
+	if err != nil {
+		return err
+	}
+	finished, err := client.open(22, fragment)
+	transcriptHash := sha256.Sum256(transcript)
+	if err != nil || !bytes.Equal(finished, testHandshake(20, testPRF(masterSecret, "client finished", transcriptHash[:], 12))) {
+		return errors.New("client Finished mismatch")
+	}
+	transcript = append(transcript, finished...)
+	transcriptHash = sha256.Sum256(transcript)
+	writeTestRecord(conn, 20, []byte{1})
+	writeTestRecord(conn, 22, server.seal(22, testHandshake(20, testPRF(masterSecret, "server finished", transcriptHash[:], 12))))
+
+	_, fragment, err = readTestRecord(conn)
+	if err != nil {
+		return err
+	}
+	if renegotiation, err := client.open(22, fragment); err != nil || len(renegotiation) == 0 || renegotiation[0] != 1 {
+		return errors.New("expected a renegotiating ClientHello")
+	}
+	if accept {
+		serverRandom := make([]byte, 32)
+		rand.Read(serverRandom)
+		writeTestRecord(conn, 22, server.seal(22, testHandshake(2, append(append([]byte{3, 3}, serverRandom...), 0, 0xc0, 0x2b, 0))))
+	} else {
+		writeTestRecord(conn, 21, server.seal(21, []byte{1, network.AlertNoRenegotiation}))
+	}
+	_, err = io.Copy(io.Discard, conn)
+	return err
+}
+
+func readTestRecord(conn net.Conn) (uint8, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, nil, err
+	}
+	fragment := make([]byte, binary.BigEndian.Uint16(header[3:]))
+	_, err := io.ReadFull(conn, fragment)
+	return header[0], fragment, err
+}
+
+func writeTestRecord(conn net.Conn, recordType uint8, payload []byte) {
+	conn.Write(append([]byte{recordType, 3, 3}, testVector(2, payload)...))
+}
+
+func testVector(lengthBytes int, data []byte) []byte {
+	out := make([]byte, 0)
+	for i := lengthBytes - 1; i >= 0; i-- {
+		out = append(out, byte(len(data)>>(8*i)))
+	}
+	return append(out, data...)
+}
+
+func testHandshake(messageType uint8, body []byte) []byte {
+	return append([]byte{messageType}, testVector(3, body)...)
+}
+
+// testPRF is the TLS 1.2 PRF with SHA-256
+func testPRF(secret []byte, label string, seed []byte, length int) []byte {
+	seed = append([]byte(label), seed...)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(seed)
+	a := mac.Sum(nil)
+	result := make([]byte, 0)
+	for len(result) < length {
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(seed)
+		result = mac.Sum(result)
+		mac.Reset()
+		mac.Write(a)
+		a = mac.Sum(nil)
+	}
+	return result[:length]
+}
+
+type testGCM struct {
+	aead    cipher.AEAD
+	fixedIV []byte
+	seq     uint64
+}
+
+func newTestGCM(key []byte, fixedIV []byte) *testGCM {
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	return &testGCM{aead: aead, fixedIV: fixedIV}
+}
+
+func (g *testGCM) additionalData(recordType uint8, length int) []byte {
+	data := binary.BigEndian.AppendUint64(nil, g.seq)
+	g.seq++
+	return append(data, recordType, 3, 3, byte(length>>8), byte(length))
+}
+
+func (g *testGCM) seal(recordType uint8, payload []byte) []byte {
+	explicitNonce := binary.BigEndian.AppendUint64(nil, g.seq)
+	nonce := append(append([]byte{}, g.fixedIV...), explicitNonce...)
+	return g.aead.Seal(explicitNonce, nonce, payload, g.additionalData(recordType, len(payload)))
+}
+
+func (g *testGCM) open(recordType uint8, fragment []byte) ([]byte, error) {
+	if len(fragment) < 8+g.aead.Overhead() {
+		return nil, errors.New("short record")
+	}
+	nonce := append(append([]byte{}, g.fixedIV...), fragment[:8]...)
+	return g.aead.Open(nil, nonce, fragment[8:], g.additionalData(recordType, len(fragment)-8-g.aead.Overhead()))
+}
+
+func TestProbeInsecureRenegotiation(t *testing.T) {
+	for _, accept := range []bool{true, false} {
+		port := startRenegotiationServer(t, accept)
+		insecure, err := network.ProbeInsecureRenegotiation(net.ParseIP("127.0.0.1"), "localhost", port, "TLS", tls.VersionTLS12)
+		if err != nil || insecure != accept {
+			t.Errorf("Expected insecure renegotiation %v, got %v %v\n", accept, insecure, err)
+		}
+	}
+
+	port := startRenegotiationServer(t, true)
+	features := network.ProbeTLSFeatures(net.ParseIP("127.0.0.1"), "localhost", port, "TLS", []uint16{tls.VersionTLS12})
+	if features.SecureRenegotiation || features.InsecureRenegotiation == nil || !*features.InsecureRenegotiation {
+		t.Errorf("Expected insecure renegotiation to be reported, got %+v\n", features)
+	}
+}
+
+// crypto/tls refuses a renegotiating ClientHello with an unexpected_message alert, the handshakes
+// cover the CBC record layer, the TLS 1.0 PRF and RSA key exchange of the probe.
+func TestProbeInsecureRenegotiationAgainstCryptoTLS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &rsaKey.PublicKey, rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaCertificate := []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: rsaKey}}
+
+	cases := []struct {
+		version      uint16
+		suite        uint16
+		certificates []tls.Certificate
+	}{
+		{tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, nil},
+		{tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA, nil},
+		{tls.VersionTLS11, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA, nil},
+		{tls.VersionTLS10, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA, nil},
+		{tls.VersionTLS12, tls.TLS_RSA_WITH_AES_128_GCM_SHA256, rsaCertificate},
+		{tls.VersionTLS10, tls.TLS_RSA_WITH_AES_128_CBC_SHA, rsaCertificate},
+	}
+	for _, c := range cases {
+		port := startLocalTLSServer(t, &tls.Config{
+			MinVersion:   c.version,
+			MaxVersion:   c.version,
+			CipherSuites: []uint16{c.suite},
+			Certificates: c.certificates,
+		})
+		insecure, err := network.ProbeInsecureRenegotiation(net.ParseIP("127.0.0.1"), "localhost", port, "TLS", c.version)
+		if err != nil || insecure {
+			t.Errorf("Expected %s with %s to refuse renegotiation, got %v %v\n", tls.VersionName(c.version), tls.CipherSuiteName(c.suite), insecure, err)
+		}
+	}
+}
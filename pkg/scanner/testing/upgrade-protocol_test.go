@@ -0,0 +1,167 @@
+package testing
+
+import (
+	"Scanner/pkg/scanner/network"
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startFakeUpgradeServer runs the plaintext dialogue on every connection and, when it returns
+// true, a TLS handshake. Returns the loopback port.
+func startFakeUpgradeServer(t *testing.T, dialogue func(conn net.Conn, reader *bufio.Reader) bool) string {
+	cert, key := generateTestCertificate(t, "localhost", []string{"localhost"})
+	cfg := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if !dialogue(conn, bufio.NewReader(conn)) {
+					return
+				}
+				tlsConn := tls.Server(conn, cfg)
+				if tlsConn.Handshake() == nil {
+					io.Copy(io.Discard, tlsConn)
+				}
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+// expectLine reads one line and reports whether it starts with prefix
+func expectLine(reader *bufio.Reader, prefix string) bool {
+	line, err := reader.ReadString('\n')
+	return err == nil && strings.HasPrefix(strings.ToUpper(line), prefix)
+}
+
+var fakeUpgradeDialogues = map[string]func(conn net.Conn, reader *bufio.Reader) bool{
+	"SMTP": func(conn net.Conn, reader *bufio.Reader) bool {
+		fmt.Fprintf(conn, "220 mx.example.gov ESMTP\r\n")
+		if !expectLine(reader, "EHLO") {
+			return false
+		}
+		fmt.Fprintf(conn, "250-mx.example.gov\r\n250 STARTTLS\r\n")
+		if !expectLine(reader, "STARTTLS") {
+			return false
+		}
+		fmt.Fprintf(conn, "220 ready\r\n")
+		return true
+	},
+	"IMAP": func(conn net.Conn, reader *bufio.Reader) bool {
+		fmt.Fprintf(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+		if !expectLine(reader, "A001 STARTTLS") {
+			return false
+		}
+		fmt.Fprintf(conn, "* CAPABILITY IMAP4rev1\r\na001 OK Begin TLS negotiation now\r\n")
+		return true
+	},
+	"POP3": func(conn net.Conn, reader *bufio.Reader) bool {
+		fmt.Fprintf(conn, "+OK POP3 ready\r\n")
+		if !expectLine(reader, "STLS") {
+			return false
+		}
+		fmt.Fprintf(conn, "+OK Begin TLS\r\n")
+		return true
+	},
+	"FTP": func(conn net.Conn, reader *bufio.Reader) bool {
+		fmt.Fprintf(conn, "220-Welcome\r\n220 FTP ready\r\n")
+		if !expectLine(reader, "AUTH TLS") {
+			return false
+		}
+		fmt.Fprintf(conn, "234 AUTH TLS successful\r\n")
+		return true
+	},
+	"XMPP": func(conn net.Conn, reader *bufio.Reader) bool {
+		header := make([]byte, 0)
+		for !bytes.Contains(header, []byte("<stream:stream")) || !bytes.HasSuffix(header, []byte(">")) {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return false
+			}
+			header = append(header, b)
+		}
+		fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream from='localhost' id='1' version='1.0' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'>"+
+			"<stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+		request := make([]byte, len("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"))
+		if _, err := io.ReadFull(reader, request); err != nil || !bytes.HasPrefix(request, []byte("<starttls")) {
+			return false
+		}
+		fmt.Fprintf(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+		return true
+	},
+	"LDAP": func(conn net.Conn, reader *bufio.Reader) bool {
+		request := make([]byte, 31)
+		if _, err := io.ReadFull(reader, request); err != nil || !bytes.Contains(request, []byte("1.3.6.1.4.1.1466.20037")) {
+			return false
+		}
+		// extendedResp { resultCode success, matchedDN "", diagnosticMessage "" }
+		conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+		return true
+	},
+	"POSTGRES": func(conn net.Conn, reader *bufio.Reader) bool {
+		request := make([]byte, 8)
+		if _, err := io.ReadFull(reader, request); err != nil || !bytes.Equal(request, []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}) {
+			return false
+		}
+		conn.Write([]byte("S"))
+		return true
+	},
+}
+
+func TestUpgradeProtocolsReachTLS(t *testing.T) {
+	for name, dialogue := range fakeUpgradeDialogues {
+		port := startFakeUpgradeServer(t, dialogue)
+		hello, err := network.RawHandshake(net.ParseIP("127.0.0.1"), "localhost", port, name, network.ClientHelloSpec{
+			ServerName:   "localhost",
+			Version:      tls.VersionTLS12,
+			CipherSuites: network.DefaultProbeCipherSuites,
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error %v\n", name, err)
+			continue
+		}
+		if hello.NegotiatedVersion != tls.VersionTLS12 {
+			t.Errorf("%s: unexpected version %x\n", name, hello.NegotiatedVersion)
+		}
+	}
+}
+
+func TestUpgradeProtocolRefusals(t *testing.T) {
+	port := startFakeUpgradeServer(t, func(conn net.Conn, reader *bufio.Reader) bool {
+		io.ReadFull(reader, make([]byte, 8))
+		conn.Write([]byte("N"))
+		return false
+	})
+	_, err := network.RawHandshake(net.ParseIP("127.0.0.1"), "localhost", port, "POSTGRES", network.ClientHelloSpec{
+		Version:      tls.VersionTLS12,
+		CipherSuites: network.DefaultProbeCipherSuites,
+	})
+	if !errors.Is(err, network.ErrUpgradeRefused) {
+		t.Errorf("Expected the SSLRequest refusal to be reported, got %v\n", err)
+	}
+
+	if _, err := network.GetUpgradeProtocol("gopher"); !errors.Is(err, network.ErrUnknownProtocol) {
+		t.Errorf("Expected an unknown protocol error, got %v\n", err)
+	}
+	protocol, err := network.GetUpgradeProtocol("imaps")
+	if err != nil || protocol.DefaultPort() != "993" {
+		t.Errorf("Unexpected IMAPS protocol %v %v\n", protocol, err)
+	}
+}